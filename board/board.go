@@ -1,10 +1,61 @@
 package board
 
 import (
+	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
+	"sort"
+	"sync"
 )
 
+var _panels = make(map[string]func() interface{})
+var _lock sync.RWMutex
+
+// 登记面板，每次访问时调用data获取面板内容，以JSON形式展示；同名面板将被替换
+func Register(name string, data func() interface{}) {
+	_lock.Lock()
+	defer _lock.Unlock()
+	_panels[name] = data
+}
+
+func Unregister(name string) {
+	_lock.Lock()
+	defer _lock.Unlock()
+	delete(_panels, name)
+}
+
+// 各面板的当前内容
+func Snapshot() map[string]interface{} {
+	_lock.RLock()
+	defer _lock.RUnlock()
+	snapshot := make(map[string]interface{}, len(_panels))
+	for name, data := range _panels {
+		snapshot[name] = data()
+	}
+	return snapshot
+}
+
+// 以?format=json访问时直接返回各面板的JSON
 func DashBoard(w http.ResponseWriter, r *http.Request) {
+	snapshot := Snapshot()
+	if r.URL.Query().Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshot)
+		return
+	}
 	fmt.Fprintln(w, "<h1>Hello Silvernode-Go!</h1>")
+	names := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := json.MarshalIndent(snapshot[name], "", "  ")
+		if err != nil {
+			data = []byte(err.Error())
+		}
+		fmt.Fprintln(w, "<h2>"+html.EscapeString(name)+"</h2>")
+		fmt.Fprintln(w, "<pre>"+html.EscapeString(string(data))+"</pre>")
+	}
 }
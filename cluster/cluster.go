@@ -10,70 +10,185 @@ const (
 	Consul string = "consul"
 	Etcd          = "etcd"
 	Nacos         = "nacos"
+	Redis         = "redis"
+	Memory        = "memory" // 进程内共享的内存注册中心
+	Static        = "static" // 基于yaml文件的注册中心
 )
 
 type IRegistry interface {
 	RegNodeInfo(nodeInfo *ctx.NodeInfo) error
+	UnRegNodeInfo(nodeInfo *ctx.NodeInfo) error
 	GetNodeById(nodeId string) (*ctx.NodeInfo, error)
 	SelectNodesByName(name string) ([]*ctx.NodeInfo, error)
 	CheckNodeSig(nodeId string, sig string) (bool, error)
 	SetConfig(key string, val interface{}) error
 	GetConfig(key string, ref interface{}) error
 	DelConfig(key string) error
+	// 监听指定名称的节点变更，首次通知包含当前已注册的全部节点，返回取消监听的函数
+	WatchNodes(name string, handler WatchHandler) (func(), error)
+}
+
+// 注册中心中不存在对应的节点，区别于访问注册中心时的临时错误
+type NotFoundError struct {
+	nodeId string
+}
+
+func NewNotFoundError(nodeId string) error {
+	return &NotFoundError{nodeId: nodeId}
+}
+
+func (err *NotFoundError) Error() string {
+	return "查找不到对应的节点信息:" + err.nodeId
+}
+
+// GetNodeById返回的错误是否表示节点确实不存在
+func IsNotFound(err error) bool {
+	_, ok := err.(*NotFoundError)
+	return ok
 }
 
 func CreateRegistry(Type string) (IRegistry, error) {
+	return CreateRegistryWithConf(Type, ctx.CoreConf())
+}
+
+// 依据指定的应用配置创建注册中心实例
+func CreateRegistryWithConf(Type string, conf *ctx.AppConf) (IRegistry, error) {
 	if Type == Consul {
 		consul := NewConsulIns()
-		if err := consul.Install(); err != nil {
+		if err := consul.InstallWithConf(conf); err != nil {
 			return nil, err
 		}
 		return consul, nil
 	}
 	if Type == Etcd {
 		etcd := NewEtcdIns()
-		if err := etcd.Install(); err != nil {
+		if err := etcd.InstallWithConf(conf); err != nil {
 			return nil, err
 		}
 		return etcd, nil
 	}
 	if Type == Nacos {
 		nacos := NewNacosIns()
-		if err := nacos.Install(); err != nil {
+		if err := nacos.InstallWithConf(conf); err != nil {
 			return nil, err
 		}
 		return nacos, nil
 	}
+	if Type == Redis {
+		redis := NewRedisIns()
+		if err := redis.InstallWithConf(conf); err != nil {
+			return nil, err
+		}
+		return redis, nil
+	}
+	if Type == Memory {
+		return _memoryIns, nil
+	}
+	if Type == Static {
+		static := NewStaticIns()
+		if err := static.InstallWithConf(conf); err != nil {
+			return nil, err
+		}
+		return static, nil
+	}
 	return nil, errutil.New("错误的注册中心类型:" + Type)
 }
 
+var _memoryIns *MemoryIns = NewMemoryIns()
+
+// 进程内共享的内存注册中心，cluster.type为memory的节点均使用该实例
+func MemoryInsShared() *MemoryIns {
+	return _memoryIns
+}
+
+const RESYNC_INTERVAL int = 30000 // 定期全量同步后端节点的间隔(毫秒)，用于弥补监听期间可能遗漏的变更
+
 type ClusterParam struct {
-	SelfInfo   *ctx.NodeInfo
-	OnScanning func(otherInfos []*ctx.NodeInfo, err error)
+	SelfInfo       *ctx.NodeInfo
+	OnScanning     func(otherInfos []*ctx.NodeInfo, err error) // 发现后端节点
+	OnLeaving      func(otherInfos []*ctx.NodeInfo)            // 后端节点下线
+	ResyncInterval int                                         // 全量同步的间隔(毫秒)，缺省为RESYNC_INTERVAL
+}
+
+type Cluster struct {
+	registry IRegistry
+	param    *ClusterParam
+	service  process.Service
+	unwatch  []func()
 }
 
-var _service process.Service
-var _registry IRegistry
-var _param *ClusterParam
+func NewCluster(registry IRegistry, param *ClusterParam) *Cluster {
+	c := new(Cluster)
+	c.registry = registry
+	c.param = param
+	return c
+}
 
-func Serve(registry IRegistry, param *ClusterParam) error {
-	_registry = registry
-	_param = param
-	if err := registry.RegNodeInfo(param.SelfInfo); err != nil {
+func (c *Cluster) Serve() error {
+	if err := c.registry.RegNodeInfo(c.param.SelfInfo); err != nil {
 		return err
 	}
-	if len(param.SelfInfo.BackEnds) > 0 {
-		_service = process.SpawnS()
-		_service.Start(nodeScanning, nil)
+	if len(c.param.SelfInfo.BackEnds) > 0 {
+		for _, backend := range c.param.SelfInfo.BackEnds {
+			unwatch, err := c.registry.WatchNodes(backend, c.onWatching)
+			if err != nil {
+				c.cancelWatching()
+				return errutil.Extend("监听后端节点变更失败:"+backend, err)
+			}
+			c.unwatch = append(c.unwatch, unwatch)
+		}
+		interval := RESYNC_INTERVAL
+		if c.param.ResyncInterval > 0 {
+			interval = c.param.ResyncInterval
+		}
+		c.service = process.SpawnS()
+		c.service.StartTick(c.nodeScanning, interval, nil)
 	}
 	return nil
 }
 
-func nodeScanning() {
-	for _, backend := range _param.SelfInfo.BackEnds {
-		otherInfos, err := _registry.SelectNodesByName(backend)
-		_param.OnScanning(otherInfos, err)
-		process.Sleep(2000)
+func (c *Cluster) Stop() error {
+	c.cancelWatching()
+	if c.service != nil {
+		c.service.Terminate()
+		c.service = nil
+	}
+	return c.registry.UnRegNodeInfo(c.param.SelfInfo)
+}
+
+func (c *Cluster) onWatching(added []*ctx.NodeInfo, removed []*ctx.NodeInfo) {
+	if len(removed) > 0 && c.param.OnLeaving != nil {
+		c.param.OnLeaving(removed)
+	}
+	if len(added) > 0 {
+		c.param.OnScanning(added, nil)
+	}
+}
+
+func (c *Cluster) cancelWatching() {
+	for _, unwatch := range c.unwatch {
+		unwatch()
+	}
+	c.unwatch = nil
+}
+
+func (c *Cluster) nodeScanning() {
+	for _, backend := range c.param.SelfInfo.BackEnds {
+		otherInfos, err := c.registry.SelectNodesByName(backend)
+		c.param.OnScanning(otherInfos, err)
+	}
+}
+
+var _cluster *Cluster
+
+func Serve(registry IRegistry, param *ClusterParam) error {
+	_cluster = NewCluster(registry, param)
+	return _cluster.Serve()
+}
+
+func Stop() error {
+	if _cluster == nil {
+		return nil
 	}
-	process.Sleep(1000)
+	return _cluster.Stop()
 }
@@ -1,7 +1,10 @@
 package cluster
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/silvernodes/silvernode-go/ctx"
@@ -30,7 +33,11 @@ func NewConsulIns() *ConsulIns {
 }
 
 func (c *ConsulIns) Install() error {
-	if err := ctx.CoreConf().GetConfDatas("cluster.consul", c.info); err != nil {
+	return c.InstallWithConf(ctx.CoreConf())
+}
+
+func (c *ConsulIns) InstallWithConf(conf *ctx.AppConf) error {
+	if err := conf.GetConfDatas("cluster.consul", c.info); err != nil {
 		return errutil.Extend("Consul注册中心配置信息加载失败", err)
 	}
 	config := api.DefaultConfig()
@@ -48,7 +55,7 @@ func (c *ConsulIns) Install() error {
 }
 
 func (c *ConsulIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
-	_, ip, _, err := netutil.ParseUrlInfo(nodeInfo.EndPoints[0])
+	_, ip, _, err := netutil.ParseUrlInfo(nodeInfo.MainEndPoint())
 	if err != nil {
 		return errutil.Extend("解析节点注册信息发生错误", err)
 	}
@@ -79,10 +86,19 @@ func (c *ConsulIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
 	}
 	return nil
 }
+func (c *ConsulIns) UnRegNodeInfo(nodeInfo *ctx.NodeInfo) error {
+	if err := c.client.Agent().ServiceDeregister(nodeInfo.NodeId); err != nil {
+		return errutil.Extend("节点注销时发生错误", err)
+	}
+	return nil
+}
 func (c *ConsulIns) GetNodeById(nodeId string) (*ctx.NodeInfo, error) {
 	q := &api.QueryOptions{}
 	svc, _, err := c.client.Agent().Service(nodeId, q)
 	if err != nil {
+		if status, ok := err.(api.StatusError); ok && status.Code == http.StatusNotFound {
+			return nil, NewNotFoundError(nodeId)
+		}
 		return nil, errutil.Extend("获取节点信息发生错误:"+nodeId, err)
 	}
 	str, exists := svc.Meta["info"]
@@ -157,3 +173,53 @@ func (c *ConsulIns) DelConfig(key string) error {
 	}
 	return nil
 }
+func (c *ConsulIns) WatchNodes(name string, handler WatchHandler) (func(), error) {
+	index, infos, err := c.healthyNodes(name, 0, context.Background())
+	if err != nil {
+		return nil, errutil.Extend("监听节点信息发生错误:"+name, err)
+	}
+	w := NewNodeWatcher(handler)
+	w.Reset(infos)
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	go func() {
+		for watchCtx.Err() == nil {
+			// 阻塞查询，服务列表变化或等待超时后返回
+			index2, infos, err := c.healthyNodes(name, index, watchCtx)
+			if err != nil {
+				time.Sleep(time.Second)
+				continue
+			}
+			if index2 < index { // 索引回退时重新开始
+				index2 = 0
+			}
+			index = index2
+			w.Reset(infos)
+		}
+	}()
+	return func() {
+		watchCancel()
+		w.Stop()
+	}, nil
+}
+
+// 查询通过健康检查的节点，index不为0时阻塞至列表变化
+func (c *ConsulIns) healthyNodes(name string, index uint64, watchCtx context.Context) (uint64, []*ctx.NodeInfo, error) {
+	q := &api.QueryOptions{WaitIndex: index, WaitTime: time.Minute}
+	entries, meta, err := c.client.Health().Service(name, c.info.NameSpace, true, q.WithContext(watchCtx))
+	if err != nil {
+		return 0, nil, err
+	}
+	nodeInfos := make([]*ctx.NodeInfo, 0, len(entries))
+	for _, entry := range entries {
+		str, exists := entry.Service.Meta["info"]
+		if !exists {
+			continue
+		}
+		nodeInfo := ctx.NewNodeInfo()
+		if err := nodeInfo.Unmarshal(str); err != nil {
+			continue
+		}
+		nodeInfos = append(nodeInfos, nodeInfo)
+	}
+	return meta.LastIndex, nodeInfos, nil
+}
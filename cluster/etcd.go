@@ -25,6 +25,8 @@ type EtcdIns struct {
 	info      *EtcdInfo
 	client    *clientv3.Client
 	rwTimeout time.Duration
+	leaseId   clientv3.LeaseID
+	keepAlive context.CancelFunc
 }
 
 func NewEtcdIns() *EtcdIns {
@@ -35,7 +37,11 @@ func NewEtcdIns() *EtcdIns {
 }
 
 func (e *EtcdIns) Install() error {
-	if err := ctx.CoreConf().GetConfDatas("cluster.etcd", e.info); err != nil {
+	return e.InstallWithConf(ctx.CoreConf())
+}
+
+func (e *EtcdIns) InstallWithConf(conf *ctx.AppConf) error {
+	if err := conf.GetConfDatas("cluster.etcd", e.info); err != nil {
 		return errutil.Extend("Etcd注册中心配置信息加载失败", err)
 	}
 	endpoints := []string{e.info.IpAddress + ":" + fmt.Sprint(e.info.Port)}
@@ -76,16 +82,19 @@ func (e *EtcdIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
 	}
 	leaseId := leaseGrantResp.ID
 	//启动自动续租
-	if keepChan, err := lease.KeepAlive(context.TODO(), leaseId); err != nil {
+	keepCtx, keepCancel := context.WithCancel(context.Background())
+	if keepChan, err := lease.KeepAlive(keepCtx, leaseId); err != nil {
+		keepCancel()
 		return errutil.Extend("启用Etcd自动续租失败", err)
 	} else {
 		//处理续租应答的协程
 		go func() {
-			for {
-				<-keepChan
+			for range keepChan {
 			}
 		}()
 	}
+	e.leaseId = leaseId
+	e.keepAlive = keepCancel
 	ctx, cancel := context.WithTimeout(context.Background(), e.rwTimeout)
 	_, err2 := e.client.Put(ctx, e.svcPath()+nodeInfo.Name+"/"+nodeInfo.NodeId, str, clientv3.WithLease(leaseId))
 	cancel()
@@ -94,6 +103,28 @@ func (e *EtcdIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
 	}
 	return nil
 }
+func (e *EtcdIns) UnRegNodeInfo(nodeInfo *ctx.NodeInfo) error {
+	if e.keepAlive != nil {
+		e.keepAlive()
+		e.keepAlive = nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.rwTimeout)
+	_, err := e.client.Delete(ctx, e.svcPath()+nodeInfo.Name+"/"+nodeInfo.NodeId)
+	cancel()
+	if err != nil {
+		return errutil.Extend("节点注销时发生错误", err)
+	}
+	if e.leaseId != 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), e.rwTimeout)
+		_, err := e.client.Revoke(ctx, e.leaseId)
+		cancel()
+		if err != nil {
+			return errutil.Extend("释放Etcd设备租约失败", err)
+		}
+		e.leaseId = 0
+	}
+	return nil
+}
 func (e *EtcdIns) GetNodeById(nodeId string) (*ctx.NodeInfo, error) {
 	nodeName := ctx.GetNodeNameFromId(nodeId)
 	path := e.svcPath() + nodeName + "/" + nodeId
@@ -113,7 +144,7 @@ func (e *EtcdIns) GetNodeById(nodeId string) (*ctx.NodeInfo, error) {
 			return nodeInfo, nil
 		}
 	}
-	return nil, errutil.New("查找不到对应的节点信息:" + nodeId)
+	return nil, NewNotFoundError(nodeId)
 }
 func (e *EtcdIns) SelectNodesByName(name string) ([]*ctx.NodeInfo, error) {
 	path := e.svcPath() + name + "/"
@@ -186,3 +217,67 @@ func (e *EtcdIns) DelConfig(key string) error {
 	}
 	return nil
 }
+func (e *EtcdIns) WatchNodes(name string, handler WatchHandler) (func(), error) {
+	path := e.svcPath() + name + "/"
+	rev, infos, err := e.snapshot(path)
+	if err != nil {
+		return nil, errutil.Extend("监听节点信息发生错误:"+name, err)
+	}
+	w := NewNodeWatcher(handler)
+	w.Reset(infos)
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	go func() {
+		for watchCtx.Err() == nil {
+			wch := e.client.Watch(clientv3.WithRequireLeader(watchCtx), path, clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+			for resp := range wch {
+				if resp.Err() != nil {
+					break
+				}
+				for _, ev := range resp.Events {
+					nodeId := strings.TrimPrefix(string(ev.Kv.Key), path)
+					if ev.Type == clientv3.EventTypeDelete {
+						w.Delete(nodeId)
+						continue
+					}
+					nodeInfo := ctx.NewNodeInfo()
+					if err := nodeInfo.Unmarshal(string(ev.Kv.Value)); err == nil {
+						w.Put(nodeInfo)
+					}
+				}
+				rev = resp.Header.Revision
+			}
+			// 监听中断(如版本已被压缩或与集群失联)，重新全量同步后继续监听
+			for watchCtx.Err() == nil {
+				time.Sleep(time.Second)
+				if rev2, infos, err := e.snapshot(path); err == nil {
+					rev = rev2
+					w.Reset(infos)
+					break
+				}
+			}
+		}
+	}()
+	return func() {
+		watchCancel()
+		w.Stop()
+	}, nil
+}
+
+// 获取指定前缀下的全部节点及当前版本号
+func (e *EtcdIns) snapshot(path string) (int64, []*ctx.NodeInfo, error) {
+	c, cancel := context.WithTimeout(context.Background(), e.rwTimeout)
+	resp, err := e.client.Get(c, path, clientv3.WithPrefix())
+	cancel()
+	if err != nil {
+		return 0, nil, err
+	}
+	nodeInfos := make([]*ctx.NodeInfo, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		nodeInfo := ctx.NewNodeInfo()
+		if err := nodeInfo.Unmarshal(string(kv.Value)); err != nil {
+			continue
+		}
+		nodeInfos = append(nodeInfos, nodeInfo)
+	}
+	return resp.Header.Revision, nodeInfos, nil
+}
@@ -0,0 +1,146 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/utils/netutil"
+
+	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/embed"
+)
+
+type testEtcd struct {
+	cfg    *embed.Config
+	server *embed.Etcd
+}
+
+// 在进程内启动单节点etcd
+func newTestEtcd(t *testing.T) (*EtcdIns, *testEtcd) {
+	t.Helper()
+	client, _ := url.Parse(localUrl(t))
+	peer, _ := url.Parse(localUrl(t))
+	te := &testEtcd{cfg: embed.NewConfig()}
+	te.cfg.Dir = t.TempDir()
+	te.cfg.LCUrls, te.cfg.ACUrls = []url.URL{*client}, []url.URL{*client}
+	te.cfg.LPUrls, te.cfg.APUrls = []url.URL{*peer}, []url.URL{*peer}
+	te.cfg.InitialCluster = te.cfg.InitialClusterFromName(te.cfg.Name)
+	te.start(t)
+	t.Cleanup(func() {
+		te.server.Close()
+	})
+
+	e := NewEtcdIns()
+	e.info.NameSpace = "/silvernode-test"
+	var err error
+	e.client, err = clientv3.New(clientv3.Config{Endpoints: []string{client.String()}, DialTimeout: time.Second * 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		e.client.Close()
+	})
+	return e, te
+}
+
+func localUrl(t *testing.T) string {
+	t.Helper()
+	port, err := netutil.GetAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", port)
+}
+
+func (te *testEtcd) start(t *testing.T) {
+	t.Helper()
+	server, err := embed.StartEtcd(te.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-server.Server.ReadyNotify():
+	case <-time.After(time.Second * 10):
+		t.Fatal("等待etcd启动超时")
+	}
+	te.server = server
+}
+
+func TestEtcdWatchNodes(t *testing.T) {
+	e, _ := newTestEtcd(t)
+	if err := e.RegNodeInfo(testNode("logic#1", "sig-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RegNodeInfo(testNode("gate#1", "sig-g")); err != nil {
+		t.Fatal(err)
+	}
+	diffs := watchDiffs(t, e, "logic")
+	expectDiff(t, diffs, []string{"logic#1/sig-1"}, []string{})
+
+	second := NewEtcdIns() // 每个实例仅持有一个租约
+	second.info, second.client = e.info, e.client
+	if err := second.RegNodeInfo(testNode("logic#2", "sig-2")); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{"logic#2/sig-2"}, []string{})
+	if err := second.RegNodeInfo(testNode("logic#2", "sig-3")); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{"logic#2/sig-3"}, []string{"logic#2/sig-2"})
+	if err := e.UnRegNodeInfo(testNode("logic#1", "sig-1")); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{}, []string{"logic#1/sig-1"})
+	expectNoDiff(t, diffs)
+
+	if info, err := e.GetNodeById("logic#1"); !IsNotFound(err) {
+		t.Fatalf("注销后应查找不到节点: %v %v", info, err)
+	}
+}
+
+// 监听因版本被压缩而中断后，重新全量同步并继续监听
+func TestEtcdWatchResnapshot(t *testing.T) {
+	e, te := newTestEtcd(t)
+	diffs := watchDiffs(t, e, "logic")
+	expectNoDiff(t, diffs)
+
+	// 仅开放另一地址重启，监听方断线期间发生变更并压缩版本
+	watched := te.cfg.LCUrls
+	hidden, _ := url.Parse(localUrl(t))
+	te.server.Close()
+	te.cfg.LCUrls, te.cfg.ACUrls = []url.URL{*hidden}, []url.URL{*hidden}
+	te.start(t)
+	other, err := clientv3.New(clientv3.Config{Endpoints: []string{hidden.String()}, DialTimeout: time.Second * 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	missed := NewEtcdIns()
+	missed.info, missed.client = e.info, other
+	if err := missed.RegNodeInfo(testNode("logic#1", "sig-1")); err != nil {
+		t.Fatal(err)
+	}
+	c, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	resp, err := other.Put(c, "/compacted", "") // 压缩的版本须晚于注册时的版本
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Compact(c, resp.Header.Revision, clientv3.WithCompactPhysical()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复原地址后，监听方无法从原版本继续监听，重新同步后继续接收增量变更
+	te.server.Close()
+	te.cfg.LCUrls = []url.URL{watched[0], *hidden}
+	te.cfg.ACUrls = te.cfg.LCUrls
+	te.start(t)
+	expectDiff(t, diffs, []string{"logic#1/sig-1"}, []string{})
+	if err := missed.UnRegNodeInfo(testNode("logic#1", "sig-1")); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{}, []string{"logic#1/sig-1"})
+}
@@ -0,0 +1,159 @@
+package cluster
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/yamlutil"
+)
+
+// 基于内存的注册中心，供同一进程内的节点共享，适用于单进程部署及测试
+type MemoryIns struct {
+	nodes    map[string]string
+	configs  map[string]string
+	watchers map[string]map[*NodeWatcher]bool
+	sync.RWMutex
+}
+
+func NewMemoryIns() *MemoryIns {
+	m := new(MemoryIns)
+	m.nodes = make(map[string]string)
+	m.configs = make(map[string]string)
+	m.watchers = make(map[string]map[*NodeWatcher]bool)
+	return m
+}
+
+func (m *MemoryIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
+	str, err := nodeInfo.Marshal()
+	if err != nil {
+		return errutil.Extend("节点注册并序列化时发生错误", err)
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.nodes[nodeInfo.NodeId] = str
+	for w := range m.watchers[nodeInfo.Name] { // 仅登记变更，通知在监听者自身的协程中执行
+		info := ctx.NewNodeInfo()
+		if err := info.Unmarshal(str); err == nil {
+			w.Put(info)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryIns) UnRegNodeInfo(nodeInfo *ctx.NodeInfo) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.nodes, nodeInfo.NodeId)
+	for w := range m.watchers[nodeInfo.Name] {
+		w.Delete(nodeInfo.NodeId)
+	}
+	return nil
+}
+
+func (m *MemoryIns) GetNodeById(nodeId string) (*ctx.NodeInfo, error) {
+	m.RLock()
+	str, exists := m.nodes[nodeId]
+	m.RUnlock()
+	if !exists {
+		return nil, NewNotFoundError(nodeId)
+	}
+	nodeInfo := ctx.NewNodeInfo()
+	if err := nodeInfo.Unmarshal(str); err != nil {
+		return nil, errutil.Extend("解析节点meta信息发生错误:"+nodeId, err)
+	}
+	return nodeInfo, nil
+}
+
+func (m *MemoryIns) SelectNodesByName(name string) ([]*ctx.NodeInfo, error) {
+	m.RLock()
+	ids := make([]string, 0, len(m.nodes))
+	for id := range m.nodes {
+		if ctx.GetNodeNameFromId(id) == name {
+			ids = append(ids, id)
+		}
+	}
+	m.RUnlock()
+	sort.Strings(ids)
+	nodeInfos := make([]*ctx.NodeInfo, 0, len(ids))
+	for _, id := range ids {
+		nodeInfo, err := m.GetNodeById(id)
+		if err != nil {
+			continue // 遍历期间被注销的节点
+		}
+		nodeInfos = append(nodeInfos, nodeInfo)
+	}
+	return nodeInfos, nil
+}
+
+func (m *MemoryIns) CheckNodeSig(nodeId string, sig string) (bool, error) {
+	nodeInfo, err := m.GetNodeById(nodeId)
+	if err != nil {
+		return false, errutil.Extend("节点验签失败:"+nodeId+"<--->"+sig, err)
+	}
+	return nodeInfo.Sig == sig, nil
+}
+
+func (m *MemoryIns) SetConfig(key string, val interface{}) error {
+	str, err := yamlutil.Marshal(val)
+	if err != nil {
+		return errutil.Extend("对象写入配置中心时序列化出错", err)
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.configs[key] = str
+	return nil
+}
+
+func (m *MemoryIns) GetConfig(key string, ref interface{}) error {
+	m.RLock()
+	str, exists := m.configs[key]
+	m.RUnlock()
+	if !exists {
+		return errutil.New("查找不到对应的Key:" + key)
+	}
+	if err := yamlutil.Unmarshal(str, ref); err != nil {
+		return errutil.Extend("对象从配置中心读取反序列化时出错", err)
+	}
+	return nil
+}
+
+func (m *MemoryIns) DelConfig(key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.configs, key)
+	return nil
+}
+
+func (m *MemoryIns) WatchNodes(name string, handler WatchHandler) (func(), error) {
+	w := NewNodeWatcher(handler)
+	m.Lock()
+	defer m.Unlock()
+	ids := make([]string, 0, len(m.nodes))
+	for id := range m.nodes {
+		if ctx.GetNodeNameFromId(id) == name {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	nodeInfos := make([]*ctx.NodeInfo, 0, len(ids))
+	for _, id := range ids {
+		nodeInfo := ctx.NewNodeInfo()
+		if err := nodeInfo.Unmarshal(m.nodes[id]); err != nil {
+			return nil, errutil.Extend("解析节点meta信息发生错误:"+id, err)
+		}
+		nodeInfos = append(nodeInfos, nodeInfo)
+	}
+	w.Reset(nodeInfos)
+	if _, exists := m.watchers[name]; !exists {
+		m.watchers[name] = make(map[*NodeWatcher]bool)
+	}
+	m.watchers[name][w] = true
+	return func() {
+		m.Lock()
+		defer m.Unlock()
+		delete(m.watchers[name], w)
+		w.Stop()
+	}, nil
+}
@@ -0,0 +1,97 @@
+package cluster
+
+import (
+	"testing"
+)
+
+type testConf struct {
+	MaxPlayers int
+	Rooms      []string
+}
+
+func TestMemoryRegister(t *testing.T) {
+	m := NewMemoryIns()
+	info := testNode("logic#1", "sig-1")
+	if err := m.RegNodeInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RegNodeInfo(testNode("logic#2", "sig-2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RegNodeInfo(testNode("gate#1", "sig-g")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.GetNodeById(info.NodeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Sig != info.Sig || got.MainEndPoint() != info.MainEndPoint() {
+		t.Fatalf("节点信息不符: %+v", got)
+	}
+	got.Sig = "changed" // 返回的是副本
+	if ok, err := m.CheckNodeSig(info.NodeId, "sig-1"); err != nil || !ok {
+		t.Fatalf("验签失败: %v %v", ok, err)
+	}
+	if ok, _ := m.CheckNodeSig(info.NodeId, "sig-x"); ok {
+		t.Fatal("错误的签名不应通过验证")
+	}
+	infos, err := m.SelectNodesByName("logic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := nodeIds(infos); len(ids) != 2 || ids[0] != "logic#1/sig-1" || ids[1] != "logic#2/sig-2" {
+		t.Fatalf("筛选结果不符: %v", ids)
+	}
+
+	if err := m.UnRegNodeInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetNodeById(info.NodeId); !IsNotFound(err) {
+		t.Fatalf("注销后应查找不到节点: %v", err)
+	}
+	if ok, err := m.CheckNodeSig(info.NodeId, "sig-1"); ok || err == nil {
+		t.Fatalf("注销后不应通过验证: %v %v", ok, err)
+	}
+	if infos, _ := m.SelectNodesByName("logic"); len(infos) != 1 {
+		t.Fatalf("注销后筛选结果不符: %v", nodeIds(infos))
+	}
+}
+
+func TestMemoryConfig(t *testing.T) {
+	m := NewMemoryIns()
+	if err := m.SetConfig("lobby.conf", &testConf{MaxPlayers: 100, Rooms: []string{"a", "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	conf := new(testConf)
+	if err := m.GetConfig("lobby.conf", conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.MaxPlayers != 100 || len(conf.Rooms) != 2 {
+		t.Fatalf("读取的配置不符: %+v", conf)
+	}
+	if err := m.DelConfig("lobby.conf"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.GetConfig("lobby.conf", conf); err == nil {
+		t.Fatal("删除后不应读取到配置")
+	}
+}
+
+// cluster.type为memory的节点共享同一实例
+func TestMemoryShared(t *testing.T) {
+	reg, err := CreateRegistry(Memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg != IRegistry(MemoryInsShared()) {
+		t.Fatal("应返回进程内共享的实例")
+	}
+	info := testNode("shared#1", "sig-s")
+	if err := reg.RegNodeInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	defer reg.UnRegNodeInfo(info)
+	if _, err := MemoryInsShared().GetNodeById(info.NodeId); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,8 @@
 package cluster
 
 import (
+	"strings"
+
 	"github.com/silvernodes/silvernode-go/ctx"
 	"github.com/silvernodes/silvernode-go/utils/errutil"
 	"github.com/silvernodes/silvernode-go/utils/netutil"
@@ -10,6 +12,7 @@ import (
 	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
 	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
 	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/model"
 	"github.com/nacos-group/nacos-sdk-go/vo"
 )
 
@@ -34,7 +37,11 @@ func NewNacosIns() *NacosIns {
 }
 
 func (n *NacosIns) Install() error {
-	if err := ctx.CoreConf().GetConfDatas("cluster.nacos", n.info); err != nil {
+	return n.InstallWithConf(ctx.CoreConf())
+}
+
+func (n *NacosIns) InstallWithConf(conf *ctx.AppConf) error {
+	if err := conf.GetConfDatas("cluster.nacos", n.info); err != nil {
 		return errutil.Extend("Nacos注册中心配置信息加载失败", err)
 	}
 
@@ -79,7 +86,7 @@ func (n *NacosIns) Install() error {
 }
 
 func (n *NacosIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
-	_, ip, _, err := netutil.ParseUrlInfo(nodeInfo.EndPoints[0])
+	_, ip, _, err := netutil.ParseUrlInfo(nodeInfo.MainEndPoint())
 	if err != nil {
 		return errutil.Extend("解析节点注册信息发生错误", err)
 	}
@@ -109,6 +116,26 @@ func (n *NacosIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
 	}
 	return nil
 }
+func (n *NacosIns) UnRegNodeInfo(nodeInfo *ctx.NodeInfo) error {
+	_, ip, _, err := netutil.ParseUrlInfo(nodeInfo.MainEndPoint())
+	if err != nil {
+		return errutil.Extend("解析节点注册信息发生错误", err)
+	}
+	p := vo.DeregisterInstanceParam{
+		Ip:          ip,
+		Port:        nodeInfo.MainPort,
+		ServiceName: nodeInfo.Name,
+		GroupName:   n.info.NameSpace,
+		Ephemeral:   false,
+	}
+	success, err := n.client.DeregisterInstance(p)
+	if err != nil {
+		return errutil.Extend("节点注销时发生错误", err)
+	} else if !success {
+		return errutil.New("节点注销失败")
+	}
+	return nil
+}
 func (n *NacosIns) GetNodeById(nodeId string) (*ctx.NodeInfo, error) {
 	p := vo.SelectInstancesParam{
 		ServiceName: ctx.GetNodeNameFromId(nodeId),
@@ -117,6 +144,9 @@ func (n *NacosIns) GetNodeById(nodeId string) (*ctx.NodeInfo, error) {
 	}
 	svcs, err := n.client.SelectInstances(p)
 	if err != nil {
+		if strings.Contains(err.Error(), "instance list is empty") { // 该名称下已无可用节点
+			return nil, NewNotFoundError(nodeId)
+		}
 		return nil, errutil.Extend("获取节点信息发生错误:"+nodeId, err)
 	}
 	for _, svc := range svcs {
@@ -136,7 +166,7 @@ func (n *NacosIns) GetNodeById(nodeId string) (*ctx.NodeInfo, error) {
 			return nodeInfo, nil
 		}
 	}
-	return nil, errutil.New("未能找到对应的节点信息:" + nodeId)
+	return nil, NewNotFoundError(nodeId)
 }
 func (n *NacosIns) SelectNodesByName(name string) ([]*ctx.NodeInfo, error) {
 	p := vo.SelectInstancesParam{
@@ -206,3 +236,40 @@ func (n *NacosIns) DelConfig(key string) error {
 	}
 	return nil
 }
+func (n *NacosIns) WatchNodes(name string, handler WatchHandler) (func(), error) {
+	infos, err := n.SelectNodesByName(name)
+	if err != nil {
+		return nil, errutil.Extend("监听节点信息发生错误:"+name, err)
+	}
+	w := NewNodeWatcher(handler)
+	w.Reset(infos)
+	p := &vo.SubscribeParam{
+		ServiceName: name,
+		GroupName:   n.info.NameSpace,
+		SubscribeCallback: func(services []model.SubscribeService, err error) {
+			if err != nil {
+				return
+			}
+			nodeInfos := make([]*ctx.NodeInfo, 0, len(services))
+			for _, svc := range services {
+				str, exists := svc.Metadata["info"]
+				if !exists || !svc.Healthy || !svc.Enable {
+					continue
+				}
+				nodeInfo := ctx.NewNodeInfo()
+				if err := nodeInfo.Unmarshal(str); err != nil {
+					continue
+				}
+				nodeInfos = append(nodeInfos, nodeInfo)
+			}
+			w.Reset(nodeInfos)
+		},
+	}
+	if err := n.client.Subscribe(p); err != nil {
+		return nil, errutil.Extend("订阅节点变更发生错误:"+name, err)
+	}
+	return func() {
+		n.client.Unsubscribe(p)
+		w.Stop()
+	}, nil
+}
@@ -0,0 +1,316 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/yamlutil"
+)
+
+type RedisInfo struct {
+	NameSpace string
+	IpAddress string
+	Port      uint64
+	Username  string
+	Password  string
+	DB        int
+}
+
+// 基于redis的注册中心
+// 节点信息保存在带有过期时间的key中并定期续期，同名节点的id记录在集合中
+// 节点变更通过pub/sub通知，若redis开启了过期事件的keyspace通知(notify-keyspace-events包含Ex)，异常下线的节点也能被及时感知
+type RedisIns struct {
+	info      *RedisInfo
+	client    *redis.Client
+	rwTimeout time.Duration
+	ttl       time.Duration
+	keepAlive map[string]context.CancelFunc
+	sync.Mutex
+}
+
+func NewRedisIns() *RedisIns {
+	r := new(RedisIns)
+	r.info = new(RedisInfo)
+	r.rwTimeout = 3 * time.Second
+	r.ttl = 5 * time.Second
+	r.keepAlive = make(map[string]context.CancelFunc)
+	return r
+}
+
+func (r *RedisIns) Install() error {
+	return r.InstallWithConf(ctx.CoreConf())
+}
+
+func (r *RedisIns) InstallWithConf(conf *ctx.AppConf) error {
+	if err := conf.GetConfDatas("cluster.redis", r.info); err != nil {
+		return errutil.Extend("Redis注册中心配置信息加载失败", err)
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     r.info.IpAddress + ":" + fmt.Sprint(r.info.Port),
+		Username: r.info.Username,
+		Password: r.info.Password,
+		DB:       r.info.DB,
+	})
+	return r.InstallWithClient(r.info.NameSpace, client)
+}
+
+// 使用已创建的客户端装载，可指向本地redis-server或进程内的模拟服务
+func (r *RedisIns) InstallWithClient(namespace string, client *redis.Client) error {
+	r.info.NameSpace = namespace
+	r.info.DB = client.Options().DB
+	c, cancel := context.WithTimeout(context.Background(), r.rwTimeout)
+	defer cancel()
+	if err := client.Ping(c).Err(); err != nil {
+		return errutil.Extend("Redis注册中心装载错误", err)
+	}
+	r.client = client
+	return nil
+}
+
+func (r *RedisIns) nodeKey(name string, nodeId string) string {
+	return r.info.NameSpace + ":Services:" + name + ":" + nodeId
+}
+
+func (r *RedisIns) setKey(name string) string {
+	return r.info.NameSpace + ":Names:" + name
+}
+
+func (r *RedisIns) kvsKey(key string) string {
+	return r.info.NameSpace + ":KeyValues:" + key
+}
+
+func (r *RedisIns) channel(name string) string {
+	return r.info.NameSpace + ":Events:" + name
+}
+
+func (r *RedisIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
+	str, err := nodeInfo.Marshal()
+	if err != nil {
+		return errutil.Extend("节点注册并序列化时发生错误", err)
+	}
+	if err := r.putNode(nodeInfo.Name, nodeInfo.NodeId, str); err != nil {
+		return errutil.Extend("节点注册时发生错误", err)
+	}
+	//启动自动续期，key已过期(如与redis失联)时重新写入
+	keepCtx, keepCancel := context.WithCancel(context.Background())
+	r.Lock()
+	if cancel, exists := r.keepAlive[nodeInfo.NodeId]; exists {
+		cancel()
+	}
+	r.keepAlive[nodeInfo.NodeId] = keepCancel
+	r.Unlock()
+	go func() {
+		ticker := time.NewTicker(r.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-keepCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			c, cancel := context.WithTimeout(keepCtx, r.rwTimeout)
+			ok, err := r.client.Expire(c, r.nodeKey(nodeInfo.Name, nodeInfo.NodeId), r.ttl).Result()
+			cancel()
+			if err == nil && !ok {
+				if err := r.putNode(nodeInfo.Name, nodeInfo.NodeId, str); err != nil {
+					errutil.ReportError(errutil.Extend("Redis注册中心重新写入过期节点失败:"+nodeInfo.NodeId, err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *RedisIns) putNode(name string, nodeId string, str string) error {
+	c, cancel := context.WithTimeout(context.Background(), r.rwTimeout)
+	defer cancel()
+	pipe := r.client.TxPipeline()
+	pipe.Set(c, r.nodeKey(name, nodeId), str, r.ttl)
+	pipe.SAdd(c, r.setKey(name), nodeId)
+	pipe.Publish(c, r.channel(name), "+"+nodeId)
+	_, err := pipe.Exec(c)
+	return err
+}
+
+func (r *RedisIns) UnRegNodeInfo(nodeInfo *ctx.NodeInfo) error {
+	r.Lock()
+	if cancel, exists := r.keepAlive[nodeInfo.NodeId]; exists {
+		cancel()
+		delete(r.keepAlive, nodeInfo.NodeId)
+	}
+	r.Unlock()
+	c, cancel := context.WithTimeout(context.Background(), r.rwTimeout)
+	defer cancel()
+	pipe := r.client.TxPipeline()
+	pipe.Del(c, r.nodeKey(nodeInfo.Name, nodeInfo.NodeId))
+	pipe.SRem(c, r.setKey(nodeInfo.Name), nodeInfo.NodeId)
+	pipe.Publish(c, r.channel(nodeInfo.Name), "-"+nodeInfo.NodeId)
+	if _, err := pipe.Exec(c); err != nil {
+		return errutil.Extend("节点注销时发生错误", err)
+	}
+	return nil
+}
+
+func (r *RedisIns) GetNodeById(nodeId string) (*ctx.NodeInfo, error) {
+	c, cancel := context.WithTimeout(context.Background(), r.rwTimeout)
+	str, err := r.client.Get(c, r.nodeKey(ctx.GetNodeNameFromId(nodeId), nodeId)).Result()
+	cancel()
+	if err == redis.Nil {
+		return nil, NewNotFoundError(nodeId)
+	} else if err != nil {
+		return nil, errutil.Extend("获取节点信息发生错误:"+nodeId, err)
+	}
+	nodeInfo := ctx.NewNodeInfo()
+	if err := nodeInfo.Unmarshal(str); err != nil {
+		return nil, errutil.Extend("解析节点meta信息发生错误:"+nodeId, err)
+	}
+	return nodeInfo, nil
+}
+
+func (r *RedisIns) SelectNodesByName(name string) ([]*ctx.NodeInfo, error) {
+	c, cancel := context.WithTimeout(context.Background(), r.rwTimeout)
+	defer cancel()
+	ids, err := r.client.SMembers(c, r.setKey(name)).Result()
+	if err != nil {
+		return nil, errutil.Extend("筛选节点信息发生错误:"+name, err)
+	}
+	nodeInfos := make([]*ctx.NodeInfo, 0, len(ids))
+	if len(ids) == 0 {
+		return nodeInfos, nil
+	}
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, r.nodeKey(name, id))
+	}
+	vals, err := r.client.MGet(c, keys...).Result()
+	if err != nil {
+		return nil, errutil.Extend("筛选节点信息发生错误:"+name, err)
+	}
+	expired := make([]interface{}, 0)
+	for i, val := range vals {
+		str, ok := val.(string)
+		if !ok { // key已过期，节点未正常注销
+			expired = append(expired, ids[i])
+			continue
+		}
+		nodeInfo := ctx.NewNodeInfo()
+		if err := nodeInfo.Unmarshal(str); err != nil {
+			return nil, errutil.Extend("解析节点meta信息发生错误:"+ids[i], err)
+		}
+		nodeInfos = append(nodeInfos, nodeInfo)
+	}
+	if len(expired) > 0 {
+		r.client.SRem(c, r.setKey(name), expired...)
+	}
+	return nodeInfos, nil
+}
+
+func (r *RedisIns) CheckNodeSig(nodeId string, sig string) (bool, error) {
+	nodeInfo, err := r.GetNodeById(nodeId)
+	if err != nil {
+		return false, errutil.Extend("节点验签失败:"+nodeId+"<--->"+sig, err)
+	}
+	return nodeInfo.Sig == sig, nil
+}
+
+func (r *RedisIns) SetConfig(key string, val interface{}) error {
+	str, err := yamlutil.Marshal(val)
+	if err != nil {
+		return errutil.Extend("对象写入配置中心时序列化出错", err)
+	}
+	c, cancel := context.WithTimeout(context.Background(), r.rwTimeout)
+	defer cancel()
+	if err := r.client.Set(c, r.kvsKey(key), str, 0).Err(); err != nil {
+		return errutil.Extend("对象写入配置中心时出错", err)
+	}
+	return nil
+}
+
+func (r *RedisIns) GetConfig(key string, ref interface{}) error {
+	c, cancel := context.WithTimeout(context.Background(), r.rwTimeout)
+	str, err := r.client.Get(c, r.kvsKey(key)).Result()
+	cancel()
+	if err == redis.Nil {
+		return errutil.New("查找不到对应的Key:" + key)
+	} else if err != nil {
+		return errutil.Extend("对象从配置中心读取时出错", err)
+	}
+	if err := yamlutil.Unmarshal(str, ref); err != nil {
+		return errutil.Extend("对象从配置中心读取反序列化时出错", err)
+	}
+	return nil
+}
+
+func (r *RedisIns) DelConfig(key string) error {
+	c, cancel := context.WithTimeout(context.Background(), r.rwTimeout)
+	defer cancel()
+	if err := r.client.Del(c, r.kvsKey(key)).Err(); err != nil {
+		return errutil.Extend("对象从配置中心删除时出错", err)
+	}
+	return nil
+}
+
+// 订阅节点变更频道及过期事件，并按ttl定期全量同步，以感知未开启keyspace通知时异常下线的节点
+func (r *RedisIns) WatchNodes(name string, handler WatchHandler) (func(), error) {
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	prefix := r.nodeKey(name, "")
+	sub := r.client.PSubscribe(watchCtx, r.channel(name), fmt.Sprintf("__keyspace@%d__:%s*", r.info.DB, prefix))
+	c, cancel := context.WithTimeout(watchCtx, r.rwTimeout)
+	_, err := sub.Receive(c) // 等待订阅生效，避免遗漏订阅期间的变更
+	cancel()
+	if err != nil {
+		sub.Close()
+		watchCancel()
+		return nil, errutil.Extend("订阅节点变更发生错误:"+name, err)
+	}
+	infos, err := r.SelectNodesByName(name)
+	if err != nil {
+		sub.Close()
+		watchCancel()
+		return nil, errutil.Extend("监听节点信息发生错误:"+name, err)
+	}
+	w := NewNodeWatcher(handler)
+	w.Reset(infos)
+	resync := func() {
+		if infos, err := r.SelectNodesByName(name); err == nil {
+			w.Reset(infos)
+		}
+	}
+	go func() {
+		ticker := time.NewTicker(r.ttl)
+		defer ticker.Stop()
+		ch := sub.Channel()
+		for {
+			select {
+			case <-watchCtx.Done():
+				return
+			case <-ticker.C:
+				resync()
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				if msg.Channel == r.channel(name) && strings.HasPrefix(msg.Payload, "+") {
+					if nodeInfo, err := r.GetNodeById(msg.Payload[1:]); err == nil {
+						w.Put(nodeInfo)
+					}
+				} else if msg.Channel == r.channel(name) && strings.HasPrefix(msg.Payload, "-") {
+					w.Delete(msg.Payload[1:])
+				} else if msg.Payload == "expired" || msg.Payload == "del" {
+					w.Delete(strings.TrimPrefix(msg.Channel, fmt.Sprintf("__keyspace@%d__:%s", r.info.DB, prefix)))
+				}
+			}
+		}
+	}()
+	return func() {
+		watchCancel()
+		sub.Close()
+		w.Stop()
+	}, nil
+}
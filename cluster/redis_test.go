@@ -0,0 +1,243 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/silvernodes/silvernode-go/ctx"
+)
+
+const testTTL = time.Second // EXPIRE以秒为单位
+
+// 缺省使用进程内的miniredis，设置REDIS_ADDR后改为连接真实的redis-server
+// miniredis不支持keyspace通知，过期事件由测试手动发布
+func newTestRedis(t *testing.T) (*RedisIns, *miniredis.Miniredis) {
+	t.Helper()
+	var mini *miniredis.Miniredis
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		mini = miniredis.RunT(t)
+		addr = mini.Addr()
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	r := NewRedisIns()
+	r.ttl = testTTL
+	namespace := fmt.Sprintf("silvernode-test-%d", time.Now().UnixNano())
+	if err := r.InstallWithClient(namespace, client); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.Lock()
+		for _, cancel := range r.keepAlive {
+			cancel()
+		}
+		r.Unlock()
+		if mini == nil {
+			c := context.Background()
+			if keys, err := client.Keys(c, namespace+":*").Result(); err == nil && len(keys) > 0 {
+				client.Del(c, keys...)
+			}
+		}
+		client.Close()
+	})
+	return r, mini
+}
+
+// 模拟节点key过期：miniredis以快进时间实现，真实redis直接删除
+func expireNode(t *testing.T, r *RedisIns, mini *miniredis.Miniredis, info *ctx.NodeInfo) {
+	t.Helper()
+	if mini != nil {
+		mini.FastForward(r.ttl + time.Millisecond)
+		if mini.Exists(r.nodeKey(info.Name, info.NodeId)) {
+			t.Fatal("节点key未过期:" + info.NodeId)
+		}
+		return
+	}
+	if err := r.client.Del(context.Background(), r.nodeKey(info.Name, info.NodeId)).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedisRegister(t *testing.T) {
+	r, _ := newTestRedis(t)
+	info := testNode("logic#1", "sig-1")
+	if err := r.RegNodeInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.GetNodeById(info.NodeId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Sig != info.Sig || got.MainEndPoint() != info.MainEndPoint() {
+		t.Fatalf("节点信息不符: %+v", got)
+	}
+	if ok, err := r.CheckNodeSig(info.NodeId, "sig-1"); err != nil || !ok {
+		t.Fatalf("验签失败: %v %v", ok, err)
+	}
+	if ok, _ := r.CheckNodeSig(info.NodeId, "sig-x"); ok {
+		t.Fatal("错误的签名不应通过验证")
+	}
+	infos, err := r.SelectNodesByName("logic")
+	if err != nil || len(infos) != 1 || infos[0].NodeId != info.NodeId {
+		t.Fatalf("筛选结果不符: %v %v", infos, err)
+	}
+	if ttl := r.client.TTL(context.Background(), r.nodeKey(info.Name, info.NodeId)).Val(); ttl <= 0 || ttl > r.ttl {
+		t.Fatalf("节点key的过期时间不符: %v", ttl)
+	}
+
+	if err := r.UnRegNodeInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.GetNodeById(info.NodeId); err == nil {
+		t.Fatal("注销后不应查找到节点")
+	}
+	if n := r.client.SCard(context.Background(), r.setKey("logic")).Val(); n != 0 {
+		t.Fatalf("注销后集合中残留%d个节点", n)
+	}
+}
+
+func TestRedisKeepAlive(t *testing.T) {
+	r, mini := newTestRedis(t)
+	info := testNode("logic#1", "sig-1")
+	if err := r.RegNodeInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	key := r.nodeKey(info.Name, info.NodeId)
+
+	// 续期后过期时间被重置
+	if mini != nil {
+		mini.FastForward(r.ttl / 2)
+		waitUntil(t, time.Second*3, func() bool {
+			return mini.TTL(key) > r.ttl/2
+		}, "节点key未被续期")
+	}
+
+	// key过期(如与redis失联)后重新写入
+	expireNode(t, r, mini, info)
+	waitUntil(t, time.Second*3, func() bool {
+		_, err := r.GetNodeById(info.NodeId)
+		return err == nil
+	}, "过期的节点未被重新写入")
+	if infos, _ := r.SelectNodesByName("logic"); len(infos) != 1 {
+		t.Fatalf("重新写入后筛选结果不符: %v", infos)
+	}
+
+	// 注销后停止续期
+	if err := r.UnRegNodeInfo(info); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(r.ttl)
+	if _, err := r.GetNodeById(info.NodeId); err == nil {
+		t.Fatal("注销后节点不应被重新写入")
+	}
+}
+
+func TestRedisSelectCleansExpired(t *testing.T) {
+	r, mini := newTestRedis(t)
+	alive := testNode("logic#1", "sig-1")
+	crashed := testNode("logic#2", "sig-2")
+	if err := r.RegNodeInfo(alive); err != nil {
+		t.Fatal(err)
+	}
+	str, _ := crashed.Marshal()
+	if err := r.putNode(crashed.Name, crashed.NodeId, str); err != nil { // 不续期，模拟异常下线的节点
+		t.Fatal(err)
+	}
+	if mini != nil {
+		mini.FastForward(r.ttl + time.Millisecond)
+		waitUntil(t, time.Second*3, func() bool { // 等待续期恢复存活节点
+			return mini.Exists(r.nodeKey(alive.Name, alive.NodeId))
+		}, "存活节点未被重新写入")
+	} else {
+		expireNode(t, r, mini, crashed)
+	}
+
+	infos, err := r.SelectNodesByName("logic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].NodeId != alive.NodeId {
+		t.Fatalf("筛选结果不符: %v", infos)
+	}
+	members := r.client.SMembers(context.Background(), r.setKey("logic")).Val()
+	if len(members) != 1 || members[0] != alive.NodeId {
+		t.Fatalf("过期节点未从集合中清理: %v", members)
+	}
+}
+
+func TestRedisWatchNodes(t *testing.T) {
+	r, _ := newTestRedis(t)
+	first := testNode("logic#1", "sig-1")
+	if err := r.RegNodeInfo(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RegNodeInfo(testNode("gate#1", "sig-g")); err != nil { // 其他名称的节点不应被通知
+		t.Fatal(err)
+	}
+
+	diffs := make(chan nodeDiff, 16)
+	stop, err := r.WatchNodes("logic", func(added []*ctx.NodeInfo, removed []*ctx.NodeInfo) {
+		diffs <- nodeDiff{added: nodeIds(added), removed: nodeIds(removed)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	expectDiff(t, diffs, []string{"logic#1/sig-1"}, []string{})
+
+	// pub/sub通知的注册、重新注册及注销
+	second := testNode("logic#2", "sig-2")
+	if err := r.RegNodeInfo(second); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{"logic#2/sig-2"}, []string{})
+	if err := r.RegNodeInfo(testNode("logic#2", "sig-3")); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{"logic#2/sig-3"}, []string{"logic#2/sig-2"})
+	if err := r.UnRegNodeInfo(first); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{}, []string{"logic#1/sig-1"})
+
+	// keyspace过期通知
+	c := context.Background()
+	crashed := testNode("logic#3", "sig-4")
+	str, _ := crashed.Marshal()
+	if err := r.putNode(crashed.Name, crashed.NodeId, str); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{"logic#3/sig-4"}, []string{})
+	key := r.nodeKey(crashed.Name, crashed.NodeId)
+	r.client.Del(c, key)
+	if err := r.client.Publish(c, fmt.Sprintf("__keyspace@%d__:%s", r.info.DB, key), "expired").Err(); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, nil, []string{"logic#3/sig-4"})
+
+	// 未收到任何通知时由定期全量同步发现
+	silent := testNode("logic#4", "sig-5")
+	str, _ = silent.Marshal()
+	if err := r.client.Set(c, r.nodeKey(silent.Name, silent.NodeId), str, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+	r.client.SAdd(c, r.setKey(silent.Name), silent.NodeId)
+	expectDiff(t, diffs, []string{"logic#4/sig-5"}, []string{})
+	r.client.Del(c, r.nodeKey(silent.Name, silent.NodeId))
+	expectDiff(t, diffs, []string{}, []string{"logic#4/sig-5"})
+
+	stop()
+	if err := r.RegNodeInfo(testNode("logic#5", "sig-6")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-diffs:
+		t.Fatalf("停止监听后不应再收到通知: %v", got)
+	case <-time.After(r.ttl * 2):
+	}
+}
@@ -0,0 +1,170 @@
+package cluster
+
+import (
+	"os"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/process"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/fileutil"
+	"github.com/silvernodes/silvernode-go/utils/yamlutil"
+)
+
+type StaticInfo struct {
+	Path     string // 节点及共享配置所在的yaml文件
+	Interval int    // 检查文件变化的间隔(毫秒)，缺省为2000
+}
+
+// 静态文件的内容
+type StaticFile struct {
+	Nodes   []*ctx.NodeInfo
+	Configs map[string]interface{}
+}
+
+// 基于yaml文件的注册中心，文件中声明的节点及配置在文件变化后自动更新
+// 运行期间注册的节点及写入的配置仅保存在内存中，不会回写文件
+// 文件中的节点可通过sig指定身份识别码，节点注册时将沿用该值；未指定时仅凭节点id信任该节点
+type StaticIns struct {
+	*MemoryIns
+	info    *StaticInfo
+	modTime time.Time
+	nodes   map[string]*ctx.NodeInfo // 文件中声明的节点
+	configs map[string]string        // 文件中声明的配置
+	service process.Service
+}
+
+func NewStaticIns() *StaticIns {
+	s := new(StaticIns)
+	s.MemoryIns = NewMemoryIns()
+	s.info = new(StaticInfo)
+	s.nodes = make(map[string]*ctx.NodeInfo)
+	s.configs = make(map[string]string)
+	return s
+}
+
+func (s *StaticIns) Install() error {
+	return s.InstallWithConf(ctx.CoreConf())
+}
+
+func (s *StaticIns) InstallWithConf(conf *ctx.AppConf) error {
+	if err := conf.GetConfDatas("cluster.static", s.info); err != nil {
+		return errutil.Extend("Static注册中心配置信息加载失败", err)
+	}
+	return s.InstallWithFile(s.info.Path, s.info.Interval)
+}
+
+// 直接指定文件路径装载
+func (s *StaticIns) InstallWithFile(path string, interval int) error {
+	s.info.Path = path
+	s.info.Interval = interval
+	if s.info.Interval <= 0 {
+		s.info.Interval = 2000
+	}
+	if err := s.reload(); err != nil {
+		return errutil.Extend("Static注册中心装载错误", err)
+	}
+	s.service = process.SpawnS()
+	s.service.StartTick(func() {
+		if err := s.reload(); err != nil {
+			errutil.ReportError(errutil.Extend("Static注册中心重新加载文件失败:"+s.info.Path, err))
+		}
+	}, s.info.Interval, nil)
+	return nil
+}
+
+// 停止监视文件变化
+func (s *StaticIns) Uninstall() {
+	if s.service != nil {
+		s.service.Terminate()
+		s.service = nil
+	}
+}
+
+// 文件发生变化时重新加载，并将差异同步至内存中
+func (s *StaticIns) reload() error {
+	stat, err := os.Stat(s.info.Path)
+	if err != nil {
+		return err
+	}
+	if stat.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	text, err := fileutil.LoadFile(s.info.Path)
+	if err != nil {
+		return err
+	}
+	file := new(StaticFile)
+	if err := yamlutil.Unmarshal(text, file); err != nil {
+		return err
+	}
+	nodes := make(map[string]*ctx.NodeInfo)
+	for _, nodeInfo := range file.Nodes {
+		if nodeInfo.NodeId == "" || nodeInfo.Name == "" {
+			return errutil.New("静态节点缺少nodeid或name")
+		}
+		nodes[nodeInfo.NodeId] = nodeInfo
+	}
+	configs := make(map[string]string)
+	for k, v := range file.Configs {
+		str, err := yamlutil.Marshal(v)
+		if err != nil {
+			return errutil.Extend("序列化静态配置发生错误:"+k, err)
+		}
+		configs[k] = str
+	}
+
+	for nodeId, old := range s.nodes {
+		if _, exists := nodes[nodeId]; !exists {
+			s.MemoryIns.UnRegNodeInfo(old)
+		}
+	}
+	for nodeId, nodeInfo := range nodes {
+		if old, exists := s.nodes[nodeId]; exists && s.sameNode(old, nodeInfo) {
+			continue
+		}
+		if err := s.MemoryIns.RegNodeInfo(nodeInfo); err != nil {
+			return err
+		}
+	}
+	for k := range s.configs {
+		if _, exists := configs[k]; !exists {
+			s.MemoryIns.DelConfig(k)
+		}
+	}
+	s.MemoryIns.Lock()
+	for k, str := range configs {
+		s.MemoryIns.configs[k] = str
+	}
+	s.nodes = nodes
+	s.configs = configs
+	s.MemoryIns.Unlock()
+	s.modTime = stat.ModTime()
+	return nil
+}
+
+func (s *StaticIns) sameNode(a *ctx.NodeInfo, b *ctx.NodeInfo) bool {
+	strA, errA := a.Marshal()
+	strB, errB := b.Marshal()
+	return errA == nil && errB == nil && strA == strB
+}
+
+func (s *StaticIns) RegNodeInfo(nodeInfo *ctx.NodeInfo) error {
+	s.MemoryIns.RLock()
+	declared, exists := s.nodes[nodeInfo.NodeId]
+	s.MemoryIns.RUnlock()
+	if exists && declared.Sig != "" {
+		nodeInfo.Sig = declared.Sig // 沿用文件中指定的身份识别码，以便其他进程验证
+	}
+	return s.MemoryIns.RegNodeInfo(nodeInfo)
+}
+
+func (s *StaticIns) CheckNodeSig(nodeId string, sig string) (bool, error) {
+	s.MemoryIns.RLock()
+	declared, exists := s.nodes[nodeId]
+	s.MemoryIns.RUnlock()
+	if exists && declared.Sig == "" {
+		return true, nil
+	}
+	return s.MemoryIns.CheckNodeSig(nodeId, sig)
+}
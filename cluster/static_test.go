@@ -0,0 +1,184 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testStaticFile = `
+nodes:
+  - nodeid: lobby#1
+    name: lobby
+    endpoints: [tcp://127.0.0.1:33100]
+    sig: sig-lobby
+  - nodeid: lobby#2
+    name: lobby
+    endpoints: [tcp://127.0.0.1:33101]
+configs:
+  lobby.conf:
+    maxplayers: 100
+    rooms: [a, b]
+`
+
+// 写入文件并推后修改时间，确保与上次加载时的修改时间不同
+func writeStatic(t *testing.T, path string, text string) {
+	t.Helper()
+	stat, statErr := os.Stat(path)
+	tmp := path + ".tmp" // 先写入临时文件再替换，以免重新加载时读到写了一半的内容
+	if err := os.WriteFile(tmp, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if statErr == nil {
+		modTime := stat.ModTime().Add(time.Second)
+		if err := os.Chtimes(tmp, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestStatic(t *testing.T, text string) (*StaticIns, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cluster.yml")
+	writeStatic(t, path, text)
+	s := NewStaticIns()
+	s.info.Path = path
+	if err := s.reload(); err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+func TestStaticLoad(t *testing.T) {
+	s, _ := newTestStatic(t, testStaticFile)
+	infos, err := s.SelectNodesByName("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := nodeIds(infos); strings.Join(ids, ",") != "lobby#1/sig-lobby,lobby#2/" {
+		t.Fatalf("文件中的节点不符: %v", ids)
+	}
+	if info, err := s.GetNodeById("lobby#1"); err != nil || info.MainEndPoint() != "tcp://127.0.0.1:33100" {
+		t.Fatalf("节点信息不符: %+v %v", info, err)
+	}
+	conf := new(testConf)
+	if err := s.GetConfig("lobby.conf", conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.MaxPlayers != 100 || strings.Join(conf.Rooms, ",") != "a,b" {
+		t.Fatalf("文件中的配置不符: %+v", conf)
+	}
+}
+
+// 文件中的节点注册时沿用指定的身份识别码，未指定时仅凭节点id信任
+func TestStaticSig(t *testing.T) {
+	s, _ := newTestStatic(t, testStaticFile)
+	declared := testNode("lobby#1", "sig-runtime")
+	if err := s.RegNodeInfo(declared); err != nil {
+		t.Fatal(err)
+	}
+	if declared.Sig != "sig-lobby" {
+		t.Fatalf("应沿用文件中的身份识别码: %s", declared.Sig)
+	}
+	if ok, err := s.CheckNodeSig("lobby#1", "sig-lobby"); err != nil || !ok {
+		t.Fatalf("验签失败: %v %v", ok, err)
+	}
+	if ok, _ := s.CheckNodeSig("lobby#1", "sig-runtime"); ok {
+		t.Fatal("错误的签名不应通过验证")
+	}
+	if ok, err := s.CheckNodeSig("lobby#2", "anything"); err != nil || !ok {
+		t.Fatalf("未指定身份识别码的节点应被信任: %v %v", ok, err)
+	}
+
+	// 运行期间注册的其他节点照常验签
+	other := testNode("room#1", "sig-room")
+	if err := s.RegNodeInfo(other); err != nil {
+		t.Fatal(err)
+	}
+	if other.Sig != "sig-room" {
+		t.Fatalf("不应修改其他节点的身份识别码: %s", other.Sig)
+	}
+	if ok, _ := s.CheckNodeSig("room#1", "sig-x"); ok {
+		t.Fatal("错误的签名不应通过验证")
+	}
+}
+
+func TestStaticParseError(t *testing.T) {
+	for name, text := range map[string]string{
+		"yaml":   "nodes: [\n",
+		"nodeid": "nodes:\n  - name: lobby\n",
+		"name":   "nodes:\n  - nodeid: lobby#1\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cluster.yml")
+			writeStatic(t, path, text)
+			s := NewStaticIns()
+			if err := s.InstallWithFile(path, 60000); err == nil {
+				s.Uninstall()
+				t.Fatal("文件格式错误时应装载失败")
+			}
+		})
+	}
+	s := NewStaticIns()
+	if err := s.InstallWithFile(filepath.Join(t.TempDir(), "missing.yml"), 60000); err == nil {
+		s.Uninstall()
+		t.Fatal("文件不存在时应装载失败")
+	}
+}
+
+// 文件变化后同步节点及配置的差异，并通知监听者
+func TestStaticReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cluster.yml")
+	writeStatic(t, path, testStaticFile)
+	s := NewStaticIns()
+	if err := s.InstallWithFile(path, 20); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Uninstall()
+	diffs := watchDiffs(t, s, "lobby")
+	expectDiff(t, diffs, []string{"lobby#1/sig-lobby", "lobby#2/"}, []string{})
+	if err := s.SetConfig("runtime.conf", &testConf{MaxPlayers: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeStatic(t, path, `
+nodes:
+  - nodeid: lobby#1
+    name: lobby
+    endpoints: [tcp://127.0.0.1:33100]
+    sig: sig-lobby
+  - nodeid: lobby#3
+    name: lobby
+    endpoints: [tcp://127.0.0.1:33102]
+configs:
+  other.conf:
+    maxplayers: 10
+`)
+	expectDiff(t, diffs, []string{}, []string{"lobby#2/"})
+	expectDiff(t, diffs, []string{"lobby#3/"}, []string{})
+	expectNoDiff(t, diffs) // 未变化的节点不重复通知
+
+	conf := new(testConf)
+	if err := s.GetConfig("lobby.conf", conf); err == nil {
+		t.Fatal("文件中移除的配置应被删除")
+	}
+	if err := s.GetConfig("other.conf", conf); err != nil || conf.MaxPlayers != 10 {
+		t.Fatalf("文件中新增的配置不符: %+v %v", conf, err)
+	}
+	if err := s.GetConfig("runtime.conf", conf); err != nil || conf.MaxPlayers != 1 {
+		t.Fatalf("运行期间写入的配置应保留: %+v %v", conf, err)
+	}
+
+	// 重新加载失败时保留此前的内容
+	writeStatic(t, path, "nodes: [\n")
+	time.Sleep(time.Millisecond * 100)
+	if infos, _ := s.SelectNodesByName("lobby"); strings.Join(nodeIds(infos), ",") != "lobby#1/sig-lobby,lobby#3/" {
+		t.Fatalf("加载失败后节点不应变化: %v", nodeIds(infos))
+	}
+	expectNoDiff(t, diffs)
+}
@@ -0,0 +1,120 @@
+package cluster
+
+import (
+	"sync"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+)
+
+// 节点变更通知函数，added为新增的节点，removed为已下线的节点
+type WatchHandler func(added []*ctx.NodeInfo, removed []*ctx.NodeInfo)
+
+type nodeChange struct {
+	added   []*ctx.NodeInfo
+	removed []*ctx.NodeInfo
+}
+
+// 记录已知节点并计算变更，变更通知在独立协程中按顺序执行
+// 供各注册中心实现WatchNodes时复用
+type NodeWatcher struct {
+	known   map[string]*ctx.NodeInfo
+	handler WatchHandler
+	changes []*nodeChange
+	running bool
+	stopped bool
+	sync.Mutex
+}
+
+func NewNodeWatcher(handler WatchHandler) *NodeWatcher {
+	w := new(NodeWatcher)
+	w.known = make(map[string]*ctx.NodeInfo)
+	w.handler = handler
+	w.changes = make([]*nodeChange, 0)
+	return w
+}
+
+// 以完整的节点列表更新，与已知节点比对后通知差异
+func (w *NodeWatcher) Reset(infos []*ctx.NodeInfo) {
+	w.Lock()
+	defer w.Unlock()
+	added := make([]*ctx.NodeInfo, 0)
+	removed := make([]*ctx.NodeInfo, 0)
+	current := make(map[string]*ctx.NodeInfo)
+	for _, info := range infos {
+		current[info.NodeId] = info
+		if old, exists := w.known[info.NodeId]; !exists {
+			added = append(added, info)
+		} else if old.Sig != info.Sig { // 相同id的节点重新注册
+			removed = append(removed, old)
+			added = append(added, info)
+		}
+	}
+	for nodeId, old := range w.known {
+		if _, exists := current[nodeId]; !exists {
+			removed = append(removed, old)
+		}
+	}
+	w.known = current
+	w.notify(added, removed)
+}
+
+// 单个节点注册或更新
+func (w *NodeWatcher) Put(info *ctx.NodeInfo) {
+	w.Lock()
+	defer w.Unlock()
+	removed := make([]*ctx.NodeInfo, 0)
+	if old, exists := w.known[info.NodeId]; exists {
+		if old.Sig == info.Sig {
+			return
+		}
+		removed = append(removed, old)
+	}
+	w.known[info.NodeId] = info
+	w.notify([]*ctx.NodeInfo{info}, removed)
+}
+
+// 单个节点下线
+func (w *NodeWatcher) Delete(nodeId string) {
+	w.Lock()
+	defer w.Unlock()
+	old, exists := w.known[nodeId]
+	if !exists {
+		return
+	}
+	delete(w.known, nodeId)
+	w.notify(nil, []*ctx.NodeInfo{old})
+}
+
+// 停止通知，尚未执行的变更将被丢弃
+func (w *NodeWatcher) Stop() {
+	w.Lock()
+	defer w.Unlock()
+	w.stopped = true
+	w.changes = nil
+}
+
+func (w *NodeWatcher) notify(added []*ctx.NodeInfo, removed []*ctx.NodeInfo) {
+	if w.stopped || (len(added) == 0 && len(removed) == 0) {
+		return
+	}
+	w.changes = append(w.changes, &nodeChange{added: added, removed: removed})
+	if !w.running {
+		w.running = true
+		go w.dispatch()
+	}
+}
+
+func (w *NodeWatcher) dispatch() {
+	for {
+		w.Lock()
+		if w.stopped || len(w.changes) == 0 {
+			w.running = false
+			w.Unlock()
+			return
+		}
+		change := w.changes[0]
+		w.changes = w.changes[1:]
+		w.Unlock()
+		w.handler(change.added, change.removed)
+	}
+}
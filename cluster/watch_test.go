@@ -0,0 +1,221 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+)
+
+func testNode(nodeId string, sig string) *ctx.NodeInfo {
+	info := ctx.NewNodeInfo()
+	info.Name = ctx.GetNodeNameFromId(nodeId)
+	info.NodeId = nodeId
+	info.Sig = sig
+	info.EndPoints = []string{"tcp://127.0.0.1:30001"}
+	return info
+}
+
+func waitUntil(t *testing.T, timeout time.Duration, cond func() bool, text string) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(text)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+type nodeDiff struct {
+	added   []string
+	removed []string
+}
+
+func (d nodeDiff) String() string {
+	return fmt.Sprintf("+%v -%v", d.added, d.removed)
+}
+
+func nodeIds(infos []*ctx.NodeInfo) []string {
+	ids := make([]string, 0, len(infos))
+	for _, info := range infos {
+		ids = append(ids, info.NodeId+"/"+info.Sig)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func expectDiff(t *testing.T, diffs chan nodeDiff, added []string, removed []string) {
+	t.Helper()
+	want := nodeDiff{added: added, removed: removed}
+	select {
+	case got := <-diffs:
+		if strings.Join(got.added, ",") != strings.Join(added, ",") || strings.Join(got.removed, ",") != strings.Join(removed, ",") {
+			t.Fatalf("节点变更不符: got %v, want %v", got, want)
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatalf("等待节点变更超时: %v", want)
+	}
+}
+
+// 以nodeDiff的形式接收WatchNodes的通知
+func watchDiffs(t *testing.T, reg IRegistry, name string) chan nodeDiff {
+	t.Helper()
+	diffs := make(chan nodeDiff, 16)
+	stop, err := reg.WatchNodes(name, func(added []*ctx.NodeInfo, removed []*ctx.NodeInfo) {
+		diffs <- nodeDiff{added: nodeIds(added), removed: nodeIds(removed)}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stop)
+	return diffs
+}
+
+func expectNoDiff(t *testing.T, diffs chan nodeDiff) {
+	t.Helper()
+	select {
+	case got := <-diffs:
+		t.Fatalf("不应收到节点变更: %v", got)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestNodeWatcher(t *testing.T) {
+	diffs := make(chan nodeDiff, 16)
+	w := NewNodeWatcher(func(added []*ctx.NodeInfo, removed []*ctx.NodeInfo) {
+		diffs <- nodeDiff{added: nodeIds(added), removed: nodeIds(removed)}
+	})
+	w.Reset([]*ctx.NodeInfo{testNode("logic#1", "sig-1"), testNode("logic#2", "sig-2")})
+	expectDiff(t, diffs, []string{"logic#1/sig-1", "logic#2/sig-2"}, []string{})
+	w.Reset([]*ctx.NodeInfo{testNode("logic#1", "sig-1"), testNode("logic#2", "sig-2")})
+	expectNoDiff(t, diffs)
+
+	// 全量更新时比对出新增、重新注册及下线的节点
+	w.Reset([]*ctx.NodeInfo{testNode("logic#2", "sig-3"), testNode("logic#3", "sig-4")})
+	expectDiff(t, diffs, []string{"logic#2/sig-3", "logic#3/sig-4"}, []string{"logic#1/sig-1", "logic#2/sig-2"})
+
+	w.Put(testNode("logic#3", "sig-4"))
+	expectNoDiff(t, diffs)
+	w.Put(testNode("logic#3", "sig-5"))
+	expectDiff(t, diffs, []string{"logic#3/sig-5"}, []string{"logic#3/sig-4"})
+	w.Delete("logic#9")
+	w.Delete("logic#2")
+	expectDiff(t, diffs, []string{}, []string{"logic#2/sig-3"})
+
+	w.Stop()
+	w.Put(testNode("logic#4", "sig-6"))
+	expectNoDiff(t, diffs)
+}
+
+// 通知按变更的先后顺序依次执行，处理函数阻塞期间的变更不会丢失
+func TestNodeWatcherOrder(t *testing.T) {
+	release := make(chan struct{})
+	var lock sync.Mutex
+	got := make([]string, 0)
+	w := NewNodeWatcher(func(added []*ctx.NodeInfo, removed []*ctx.NodeInfo) {
+		<-release
+		lock.Lock()
+		defer lock.Unlock()
+		for _, info := range added {
+			got = append(got, "+"+info.NodeId)
+		}
+		for _, info := range removed {
+			got = append(got, "-"+info.NodeId)
+		}
+	})
+	want := make([]string, 0)
+	for i := 0; i < 50; i++ {
+		nodeId := fmt.Sprintf("logic#%d", i)
+		w.Put(testNode(nodeId, "sig"))
+		w.Delete(nodeId)
+		want = append(want, "+"+nodeId, "-"+nodeId)
+	}
+	close(release)
+	waitUntil(t, time.Second*3, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(got) == len(want)
+	}, "等待变更通知超时")
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("通知顺序不符: %v", got)
+	}
+}
+
+func TestMemoryWatchNodes(t *testing.T) {
+	m := NewMemoryIns()
+	if err := m.RegNodeInfo(testNode("logic#1", "sig-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RegNodeInfo(testNode("gate#1", "sig-g")); err != nil {
+		t.Fatal(err)
+	}
+	diffs := watchDiffs(t, m, "logic")
+	expectDiff(t, diffs, []string{"logic#1/sig-1"}, []string{})
+
+	if err := m.RegNodeInfo(testNode("logic#2", "sig-2")); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{"logic#2/sig-2"}, []string{})
+	if err := m.RegNodeInfo(testNode("logic#2", "sig-3")); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{"logic#2/sig-3"}, []string{"logic#2/sig-2"})
+	if err := m.UnRegNodeInfo(testNode("logic#1", "sig-1")); err != nil {
+		t.Fatal(err)
+	}
+	expectDiff(t, diffs, []string{}, []string{"logic#1/sig-1"})
+	if err := m.RegNodeInfo(testNode("gate#2", "sig-g")); err != nil { // 其他名称的节点
+		t.Fatal(err)
+	}
+	expectNoDiff(t, diffs)
+}
+
+// 监听期间遗漏变更的注册中心
+type deafRegistry struct {
+	*EtcdIns
+}
+
+func (d *deafRegistry) WatchNodes(name string, handler WatchHandler) (func(), error) {
+	return func() {}, nil
+}
+
+// 监听遗漏的节点由定期全量同步发现
+func TestClusterResync(t *testing.T) {
+	e, _ := newTestEtcd(t)
+	reg := &deafRegistry{e}
+	self := testNode("gate#1", "sig-g")
+	self.BackEnds = []string{"logic"}
+	found := make(chan []string, 16)
+	c := NewCluster(reg, &ClusterParam{
+		SelfInfo: self,
+		OnScanning: func(otherInfos []*ctx.NodeInfo, err error) {
+			if err == nil && len(otherInfos) > 0 {
+				found <- nodeIds(otherInfos)
+			}
+		},
+		OnLeaving:      func(otherInfos []*ctx.NodeInfo) {},
+		ResyncInterval: 50,
+	})
+	if err := c.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	other := NewEtcdIns() // 每个实例仅持有一个租约
+	other.info, other.client = e.info, e.client
+	if err := other.RegNodeInfo(testNode("logic#1", "sig-1")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ids := <-found:
+		if strings.Join(ids, ",") != "logic#1/sig-1" {
+			t.Fatalf("全量同步的节点不符: %v", ids)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("全量同步未发现遗漏的节点")
+	}
+}
@@ -0,0 +1,153 @@
+// protoc插件，由.proto文件中的service定义生成Peer及类型化客户端，消息类型由protoc-gen-go生成
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//		--silvernode_out=. --silvernode_opt=paths=source_relative room.proto
+//
+// 每个service生成：
+//   - XxxServer接口，业务实现该接口
+//   - Xxx Peer，以NewXxx(impl)包装后注册，昵称即服务名
+//   - XxxClient客户端，调用形如client.Join(node, req)
+//
+// rpc的应答为google.protobuf.Empty时视为事件，含stream的rpc视为流方法
+package main
+
+import (
+	"google.golang.org/protobuf/compiler/protogen"
+)
+
+const (
+	contextPackage = protogen.GoImportPath("context")
+	peersPackage   = protogen.GoImportPath("github.com/silvernodes/silvernode-go/peers")
+)
+
+func main() {
+	protogen.Options{}.Run(func(gen *protogen.Plugin) error {
+		for _, f := range gen.Files {
+			if f.Generate && len(f.Services) > 0 {
+				generateFile(gen, f)
+			}
+		}
+		return nil
+	})
+}
+
+func generateFile(gen *protogen.Plugin, file *protogen.File) {
+	g := gen.NewGeneratedFile(file.GeneratedFilenamePrefix+"_silvernode.pb.go", file.GoImportPath)
+	g.P("// Code generated by protoc-gen-silvernode. DO NOT EDIT.")
+	g.P("// source: ", file.Desc.Path())
+	g.P()
+	g.P("package ", file.GoPackageName)
+	for _, service := range file.Services {
+		generateServer(g, service)
+		generateClient(g, service)
+	}
+}
+
+func isEvent(method *protogen.Method) bool {
+	return method.Output.Desc.FullName() == "google.protobuf.Empty"
+}
+
+func isStream(method *protogen.Method) bool {
+	return method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer()
+}
+
+// proc方法的签名，与peers的方法规范一致
+func serverSignature(g *protogen.GeneratedFile, method *protogen.Method) string {
+	args := "args *" + g.QualifiedGoIdent(method.Input.GoIdent)
+	switch {
+	case isStream(method):
+		return method.GoName + "(" + args + ", stream " + g.QualifiedGoIdent(peersPackage.Ident("Stream")) + ") error"
+	case isEvent(method):
+		return method.GoName + "(" + args + ") error"
+	default:
+		return method.GoName + "(" + args + ", reply *" + g.QualifiedGoIdent(method.Output.GoIdent) + ") error"
+	}
+}
+
+func generateServer(g *protogen.GeneratedFile, service *protogen.Service) {
+	name := service.GoName
+	serverName := name + "Server"
+
+	g.P()
+	g.P("// ", name, "服务的处理接口，由业务实现后以New", name, "包装并注册")
+	g.P("type ", serverName, " interface {")
+	for _, method := range service.Methods {
+		g.P(method.Comments.Leading, serverSignature(g, method))
+	}
+	g.P("}")
+	g.P()
+	g.P("// ", name, "服务的Peer，以服务名为昵称注册，如hub.Register(New", name, "(impl), processor)")
+	g.P("type ", name, " struct {")
+	g.P("impl ", serverName)
+	g.P("}")
+	g.P()
+	g.P("func New", name, "(impl ", serverName, ") *", name, " {")
+	g.P("return &", name, "{impl: impl}")
+	g.P("}")
+	for _, method := range service.Methods {
+		g.P()
+		g.P("func (p *", name, ") ", serverSignature(g, method), " {")
+		switch {
+		case isStream(method):
+			g.P("return p.impl.", method.GoName, "(args, stream)")
+		case isEvent(method):
+			g.P("return p.impl.", method.GoName, "(args)")
+		default:
+			g.P("return p.impl.", method.GoName, "(args, reply)")
+		}
+		g.P("}")
+	}
+}
+
+func generateClient(g *protogen.GeneratedFile, service *protogen.Service) {
+	name := service.GoName
+	clientName := name + "Client"
+	peer := g.QualifiedGoIdent(peersPackage.Ident("Peer"))
+
+	g.P()
+	g.P("// ", name, "的类型化客户端，由调用方的Peer发起请求")
+	g.P("type ", clientName, " struct {")
+	g.P("peer ", peer)
+	g.P("nick string")
+	g.P("}")
+	g.P()
+	g.P("func New", clientName, "(peer ", peer, ") *", clientName, " {")
+	g.P("return New", clientName, "WithNick(peer, \"", name, "\")")
+	g.P("}")
+	g.P()
+	g.P("// 目标Peer以自定义昵称注册时使用")
+	g.P("func New", clientName, "WithNick(peer ", peer, ", nick string) *", clientName, " {")
+	g.P("return &", clientName, "{peer: peer, nick: nick}")
+	g.P("}")
+	for _, method := range service.Methods {
+		argsType := "*" + g.QualifiedGoIdent(method.Input.GoIdent)
+		call := "c.nick+\"." + method.GoName + "\""
+		g.P()
+		switch {
+		case isStream(method):
+			ctx := g.QualifiedGoIdent(contextPackage.Ident("Context"))
+			stream := g.QualifiedGoIdent(peersPackage.Ident("Stream"))
+			g.P("func (c *", clientName, ") ", method.GoName, "(ctx ", ctx, ", node string, args ", argsType, ") (", stream, ", error) {")
+			g.P("return c.peer.OpenStream(ctx, node, ", call, ", args)")
+			g.P("}")
+		case isEvent(method):
+			g.P("func (c *", clientName, ") ", method.GoName, "(node string, args ", argsType, ") error {")
+			g.P("return c.peer.SendEvent(node, ", call, ", args)")
+			g.P("}")
+		default:
+			replyType := g.QualifiedGoIdent(method.Output.GoIdent)
+			g.P("func (c *", clientName, ") ", method.GoName, "(node string, args ", argsType, ") (*", replyType, ", error) {")
+			g.P("reply := new(", replyType, ")")
+			g.P("err := c.peer.Invoke(node, ", call, ", args, reply)")
+			g.P("return reply, err")
+			g.P("}")
+			g.P()
+			g.P("func (c *", clientName, ") ", method.GoName, "Async(node string, args ", argsType, ", done func(*", replyType, ", error)) {")
+			g.P("reply := new(", replyType, ")")
+			g.P("c.peer.Call(node, ", call, ", args, reply, func(err error) {")
+			g.P("done(reply, err)")
+			g.P("})")
+			g.P("}")
+		}
+	}
+}
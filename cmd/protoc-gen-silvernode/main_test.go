@@ -0,0 +1,123 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/pluginpb"
+)
+
+var update = flag.Bool("update", false, "以生成结果更新testdata中的golden文件")
+
+func message(name string, fields ...*descriptorpb.FieldDescriptorProto) *descriptorpb.DescriptorProto {
+	return &descriptorpb.DescriptorProto{Name: proto.String(name), Field: fields}
+}
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+	}
+}
+
+func rpc(name string, input string, output string, streaming bool) *descriptorpb.MethodDescriptorProto {
+	return &descriptorpb.MethodDescriptorProto{
+		Name:            proto.String(name),
+		InputType:       proto.String(input),
+		OutputType:      proto.String(output),
+		ServerStreaming: proto.Bool(streaming),
+	}
+}
+
+// 与以下.proto文件对应的描述
+//
+//	syntax = "proto3";
+//	package room;
+//	option go_package = "example.com/game/room";
+//	import "google/protobuf/empty.proto";
+//
+//	service Room {
+//	  rpc Join(JoinReq) returns (JoinResp);
+//	  rpc Leave(LeaveReq) returns (google.protobuf.Empty);
+//	  rpc Watch(JoinReq) returns (stream Frame);
+//	}
+func roomRequest() *pluginpb.CodeGeneratorRequest {
+	room := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("room/room.proto"),
+		Package:    proto.String("room"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/empty.proto"},
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("example.com/game/room")},
+		MessageType: []*descriptorpb.DescriptorProto{
+			message("JoinReq", field("user", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING)),
+			message("JoinResp", field("seat", 1, descriptorpb.FieldDescriptorProto_TYPE_INT32)),
+			message("LeaveReq", field("user", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING)),
+			message("Frame", field("data", 1, descriptorpb.FieldDescriptorProto_TYPE_BYTES)),
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Room"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				rpc("Join", ".room.JoinReq", ".room.JoinResp", false),
+				rpc("Leave", ".room.LeaveReq", ".google.protobuf.Empty", false),
+				rpc("Watch", ".room.JoinReq", ".room.Frame", true),
+			},
+		}},
+	}
+	return &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"room/room.proto"},
+		Parameter:      proto.String("paths=source_relative"),
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(emptypb.File_google_protobuf_empty_proto), room},
+	}
+}
+
+// 以插件处理请求，返回唯一的生成文件的内容
+func generate(t *testing.T, req *pluginpb.CodeGeneratorRequest) string {
+	t.Helper()
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range gen.Files {
+		if f.Generate && len(f.Services) > 0 {
+			generateFile(gen, f)
+		}
+	}
+	resp := gen.Response()
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if len(resp.File) != 1 || resp.File[0].GetName() != "room/room_silvernode.pb.go" {
+		t.Fatalf("生成的文件不符: %v", resp.File)
+	}
+	return resp.File[0].GetContent()
+}
+
+func TestGenerateGolden(t *testing.T) {
+	got := generate(t, roomRequest())
+	if again := generate(t, roomRequest()); again != got {
+		t.Fatal("两次生成的结果不一致")
+	}
+	golden := filepath.Join("testdata", "room_silvernode.pb.go.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Fatalf("生成结果与%s不一致，确认无误后以-update更新:\n%s", golden, got)
+	}
+}
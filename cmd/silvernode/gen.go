@@ -0,0 +1,600 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/silvernodes/silvernode-go/peers/proc"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/stringutil"
+	"golang.org/x/tools/go/packages"
+)
+
+const (
+	peersPath = "github.com/silvernodes/silvernode-go/peers"
+	procPath  = "github.com/silvernodes/silvernode-go/peers/proc"
+
+	markerPeer   = "//silvernode:peer"   // 标记于类型声明，将其视为proc
+	markerIgnore = "//silvernode:ignore" // 标记于proc的导出方法，不将其视为Peer方法
+)
+
+// 以proc为参数的注册函数及proc参数的位置
+var registerFuncs = map[string]int{
+	"Register":         0,
+	"RegisterInner":    0,
+	"RegisterWithNick": 2,
+}
+
+type genProc struct {
+	pkg     *packages.Package
+	obj     *types.TypeName
+	methods []*genMethod // 按方法名排序
+}
+
+type genMethod struct {
+	name  string
+	kind  int
+	args  types.Type // 参数指针所指的类型
+	reply types.Type // 应答指针所指的类型；流方法为peers.Stream，事件为nil
+}
+
+type generator struct {
+	fset   *token.FileSet
+	pkgs   map[string]*packages.Package // 按包路径
+	funcs  map[token.Pos]*ast.FuncDecl
+	procs  map[string]*genProc // 按"包路径.类型名"
+	errs   []string
+	meta   bool
+	client bool
+}
+
+func runGen(args []string) error {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	meta := flags.Bool("meta", true, "生成ProcMeta及方法表(xxx_meta.go)")
+	client := flags.Bool("client", true, "生成类型化客户端(xxx_client.go)")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: silvernode gen [-meta=true] [-client=true] [包...]")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "proc由类型声明上的"+markerPeer+"标记或对peers.Register等函数的调用识别，")
+		fmt.Fprintln(os.Stderr, "导出方法的签名不符合规范时报错退出；可以"+markerIgnore+"标记不作为Peer方法的导出方法。")
+		fmt.Fprintln(os.Stderr, "")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+
+	g := &generator{meta: *meta, client: *client}
+	if err := g.load(patterns); err != nil {
+		return err
+	}
+	g.findProcs()
+	keys := make([]string, 0, len(g.procs))
+	for key := range g.procs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if err := g.checkErrors(); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		g.collectMethods(g.procs[key])
+	}
+	if len(g.errs) > 0 {
+		for _, err := range g.errs {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return errutil.New(strconv.Itoa(len(g.errs)) + "处方法签名不符合规范")
+	}
+	for _, key := range keys {
+		if err := g.emit(g.procs[key]); err != nil {
+			return err
+		}
+	}
+	fmt.Println("gen完成![" + strconv.Itoa(len(keys)) + "]")
+	return nil
+}
+
+// 加载包；已生成的meta文件以仅含包声明的内容参与类型检查，以免过期的生成代码导致加载失败
+func (g *generator) load(patterns []string) error {
+	cfg := &packages.Config{Mode: packages.NeedName | packages.NeedFiles}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return errutil.Extend("加载包出错", err)
+	}
+	overlay := make(map[string][]byte)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.GoFiles {
+			if strings.HasSuffix(file, "_meta.go") && generatedFile(file) {
+				overlay[file] = []byte("package " + pkg.Name + "\n")
+			}
+		}
+	}
+
+	// 依赖同样由源码进行类型检查，不依赖与工具链版本相关的导出数据格式
+	cfg.Mode |= packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo
+	cfg.Overlay = overlay
+	pkgs, err = packages.Load(cfg, patterns...)
+	if err != nil {
+		return errutil.Extend("加载包出错", err)
+	}
+	g.pkgs = make(map[string]*packages.Package)
+	g.funcs = make(map[token.Pos]*ast.FuncDecl)
+	for _, pkg := range pkgs {
+		g.fset = pkg.Fset
+		g.pkgs[pkg.PkgPath] = pkg
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok {
+					g.funcs[fn.Name.Pos()] = fn
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// proc所在的包须通过类型检查；其余包的错误(如引用了尚未生成的客户端)仅作提示
+func (g *generator) checkErrors() error {
+	paths := make([]string, 0, len(g.pkgs))
+	for path := range g.pkgs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	failed := false
+	for _, path := range paths {
+		pkg := g.pkgs[path]
+		if len(pkg.Errors) == 0 {
+			continue
+		}
+		hasProc := false
+		for _, p := range g.procs {
+			hasProc = hasProc || p.pkg == pkg
+		}
+		if !hasProc {
+			fmt.Println("忽略包中的" + strconv.Itoa(len(pkg.Errors)) + "处错误:" + path)
+			continue
+		}
+		for _, err := range pkg.Errors {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		failed = true
+	}
+	if failed {
+		return errutil.New("proc所在的包存在错误，无法生成")
+	}
+	return nil
+}
+
+func generatedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	return strings.TrimSpace(line) == proc.GENERATED_HEADER
+}
+
+func hasMarker(doc *ast.CommentGroup, marker string) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == marker {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *generator) findProcs() {
+	g.procs = make(map[string]*genProc)
+	for _, pkg := range g.pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if hasMarker(ts.Doc, markerPeer) || (len(gen.Specs) == 1 && hasMarker(gen.Doc, markerPeer)) {
+						if obj, ok := pkg.TypesInfo.Defs[ts.Name].(*types.TypeName); ok {
+							g.addProc(obj)
+						}
+					}
+				}
+			}
+			ast.Inspect(file, func(n ast.Node) bool {
+				if call, ok := n.(*ast.CallExpr); ok {
+					g.inspectCall(pkg, call)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// 识别peers.Register等注册调用，记录以new(T)、&T{}等形式传入的proc类型
+func (g *generator) inspectCall(pkg *packages.Package, call *ast.CallExpr) {
+	var ident *ast.Ident
+	switch fun := call.Fun.(type) {
+	case *ast.Ident:
+		ident = fun
+	case *ast.SelectorExpr:
+		ident = fun.Sel
+	default:
+		return
+	}
+	fn, ok := pkg.TypesInfo.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != peersPath {
+		return
+	}
+	index, exists := registerFuncs[fn.Name()]
+	if !exists || len(call.Args) <= index {
+		return
+	}
+	ptr, ok := pkg.TypesInfo.TypeOf(call.Args[index]).(*types.Pointer)
+	if !ok {
+		return
+	}
+	if named, ok := ptr.Elem().(*types.Named); ok {
+		g.addProc(named.Obj())
+	}
+}
+
+func (g *generator) addProc(obj *types.TypeName) {
+	if obj.Pkg() == nil {
+		return
+	}
+	key := obj.Pkg().Path() + "." + obj.Name()
+	if _, exists := g.procs[key]; exists {
+		return
+	}
+	pkg, exists := g.pkgs[obj.Pkg().Path()]
+	if !exists { // 仅为本次加载的包生成代码
+		fmt.Println("跳过未加载的包中的proc:" + key + "，可将其所在包加入gen的参数")
+		return
+	}
+	if _, ok := obj.Type().Underlying().(*types.Interface); ok {
+		return
+	}
+	// 以加载的包中的对象为准
+	if local, ok := pkg.Types.Scope().Lookup(obj.Name()).(*types.TypeName); ok {
+		obj = local
+	}
+	g.procs[key] = &genProc{pkg: pkg, obj: obj}
+}
+
+func (g *generator) fail(pos token.Pos, p *genProc, method string, msg string) {
+	g.errs = append(g.errs, g.fset.Position(pos).String()+": "+p.obj.Name()+"."+method+": "+msg)
+}
+
+// 校验proc的导出方法，规则与运行时的proc.SuitableMethods一致，但不符合规范时报错而非忽略
+func (g *generator) collectMethods(p *genProc) {
+	mset := types.NewMethodSet(types.NewPointer(p.obj.Type()))
+	for i := 0; i < mset.Len(); i++ {
+		fn := mset.At(i).Obj().(*types.Func)
+		if !fn.Exported() || fn.Name() == "GetMeta" {
+			continue
+		}
+		if decl, exists := g.funcs[fn.Pos()]; exists && hasMarker(decl.Doc, markerIgnore) {
+			continue
+		}
+		sig := fn.Type().(*types.Signature)
+		if sig.Results().Len() != 1 || sig.Results().At(0).Type().String() != "error" {
+			g.fail(fn.Pos(), p, fn.Name(), "返回值须为error")
+			continue
+		}
+		params := sig.Params()
+		if params.Len() != 1 && params.Len() != 2 {
+			g.fail(fn.Pos(), p, fn.Name(), "参数须为(args *Args)、(args *Args, reply *Reply)或(args *Args, stream peers.Stream)")
+			continue
+		}
+		argsPtr, ok := params.At(0).Type().(*types.Pointer)
+		if !ok {
+			g.fail(fn.Pos(), p, fn.Name(), "参数须为指针")
+			continue
+		}
+		if !exported(argsPtr.Elem()) {
+			g.fail(fn.Pos(), p, fn.Name(), "参数类型须导出:"+argsPtr.Elem().String())
+			continue
+		}
+		m := &genMethod{name: fn.Name(), kind: proc.METHOD_EVENT, args: argsPtr.Elem()}
+		if params.Len() == 2 {
+			reply := params.At(1).Type()
+			if isStream(reply) {
+				m.kind, m.reply = proc.METHOD_STREAM, reply
+			} else if replyPtr, ok := reply.(*types.Pointer); !ok {
+				g.fail(fn.Pos(), p, fn.Name(), "应答须为指针或peers.Stream")
+				continue
+			} else if !exported(replyPtr.Elem()) {
+				g.fail(fn.Pos(), p, fn.Name(), "应答类型须导出:"+replyPtr.Elem().String())
+				continue
+			} else {
+				m.kind, m.reply = proc.METHOD_CALL, replyPtr.Elem()
+			}
+		}
+		p.methods = append(p.methods, m)
+	}
+	sort.Slice(p.methods, func(i, j int) bool {
+		return p.methods[i].name < p.methods[j].name
+	})
+}
+
+func exported(t types.Type) bool {
+	if named, ok := t.(*types.Named); ok {
+		return named.Obj().Exported()
+	}
+	return true
+}
+
+func isStream(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	return ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == peersPath && named.Obj().Name() == "Stream"
+}
+
+// 生成文件的import，包名冲突时以别名区分
+type genImports struct {
+	self  string
+	names map[string]string // 包路径 -> 引用名
+	used  map[string]bool
+	decl  map[string]string // 包路径 -> 声明的包名
+}
+
+func newImports(self string, fixed ...string) *genImports {
+	im := &genImports{self: self, names: make(map[string]string), used: make(map[string]bool), decl: make(map[string]string)}
+	for _, path := range fixed {
+		im.add(path, path[strings.LastIndex(path, "/")+1:])
+	}
+	return im
+}
+
+func (im *genImports) add(path string, name string) string {
+	if ref, exists := im.names[path]; exists {
+		return ref
+	}
+	ref := name
+	for i := 2; im.used[ref]; i++ {
+		ref = name + strconv.Itoa(i)
+	}
+	im.names[path] = ref
+	im.used[ref] = true
+	im.decl[path] = name
+	return ref
+}
+
+func (im *genImports) qualifier(pkg *types.Package) string {
+	if pkg.Path() == im.self {
+		return ""
+	}
+	return im.add(pkg.Path(), pkg.Name())
+}
+
+func (im *genImports) typeName(t types.Type) string {
+	return types.TypeString(t, im.qualifier)
+}
+
+func (im *genImports) block() string {
+	paths := make([]string, 0, len(im.names))
+	for path := range im.names {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	txt := "import (\n"
+	for _, path := range paths {
+		if ref := im.names[path]; ref != im.decl[path] {
+			txt += "\t" + ref + " \"" + path + "\"\n"
+		} else {
+			txt += "\t\"" + path + "\"\n"
+		}
+	}
+	return txt + ")\n"
+}
+
+// 方法表中的类型以reflect.Type.String()的形式记录，与运行时的校验一致
+func reflectName(t types.Type) string {
+	return types.TypeString(t, func(pkg *types.Package) string {
+		return pkg.Name()
+	})
+}
+
+func (g *generator) emit(p *genProc) error {
+	dir := filepath.Dir(p.pkg.GoFiles[0])
+	base := stringutil.Snake(p.obj.Name())
+	if g.meta {
+		if err := writeSource(filepath.Join(dir, base+"_meta.go"), g.metaSource(p)); err != nil {
+			return err
+		}
+	}
+	if g.client {
+		if err := writeSource(filepath.Join(dir, base+"_client.go"), g.clientSource(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 格式化后写入，内容未变化时不改动文件
+func writeSource(file string, txt string) error {
+	source, err := format.Source([]byte(txt))
+	if err != nil {
+		return errutil.Extend("格式化生成代码出错:"+file, err)
+	}
+	if old, err := os.ReadFile(file); err == nil && string(old) == string(source) {
+		return nil
+	}
+	if err := os.WriteFile(file, source, 0644); err != nil {
+		return errutil.Extend("写入生成代码出错:"+file, err)
+	}
+	fmt.Println("[" + filepath.Base(file) + "]生成完毕.")
+	return nil
+}
+
+func (g *generator) metaSource(p *genProc) string {
+	im := newImports(p.pkg.PkgPath, "errors", procPath)
+	name := p.obj.Name()
+	metaName := name + "Meta"
+
+	beans := ""
+	flows := ""
+	table := ""
+	for _, m := range p.methods {
+		reply := ""
+		if m.reply != nil {
+			reply = reflectName(types.NewPointer(m.reply))
+			if m.kind == proc.METHOD_STREAM {
+				reply = reflectName(m.reply)
+			}
+		}
+		table += fmt.Sprintf("\t\t{Name: %q, Kind: %s, Args: %q, Reply: %q},\n",
+			m.name, kindName(m.kind), reflectName(types.NewPointer(m.args)), reply)
+		if m.kind == proc.METHOD_STREAM { // 流方法始终以反射调用
+			continue
+		}
+		argsType := im.typeName(m.args)
+		beans += "\tcase \"" + m.name + "\":\n"
+		beans += "\t\targs := new(" + argsType + ")\n"
+		beans += autoWiredFields(m.args, im)
+		flows += "\tcase \"" + m.name + "\":\n"
+		if m.kind == proc.METHOD_EVENT {
+			beans += "\t\treturn args, nil, nil\n"
+			flows += "\t\treturn proc.(*" + name + ")." + m.name + "(args.(*" + argsType + "))\n"
+			continue
+		}
+		replyType := im.typeName(m.reply)
+		beans += "\t\treturn args, new(" + replyType + "), nil\n"
+		flows += "\t\treturn proc.(*" + name + ")." + m.name + "(args.(*" + argsType + "), reply.(*" + replyType + "))\n"
+	}
+
+	txt := "func (m *" + metaName + ") CreateBeans(method string, from string, ctx interface{}) (interface{}, interface{}, error) {\n"
+	txt += "\tswitch method {\n" + beans + "\t}\n"
+	txt += "\treturn nil, nil, errors.New(\"不支持的方法装配:\" + method)\n"
+	txt += "}\n\n"
+	txt += "func (m *" + metaName + ") ProcessFlow(method string, proc interface{}, args interface{}, reply interface{}) error {\n"
+	txt += "\tswitch method {\n" + flows + "\t}\n"
+	txt += "\treturn errors.New(\"不支持的方法调用:\" + method)\n"
+	txt += "}\n\n"
+	txt += "// 方法表，注册时据此校验生成代码是否与" + name + "一致\n"
+	txt += "func (m *" + metaName + ") Methods() []proc.MethodDesc {\n"
+	txt += "\treturn []proc.MethodDesc{\n" + table + "\t}\n"
+	txt += "}\n\n"
+	txt += "func (p *" + name + ") GetMeta() proc.ProcMeta {\n"
+	txt += "\treturn new(" + metaName + ")\n"
+	txt += "}\n"
+
+	head := proc.GENERATED_HEADER + "\n\n"
+	head += "package " + p.pkg.Name + "\n\n"
+	head += im.block() + "\n"
+	head += "type " + metaName + " struct {\n}\n\n"
+	return head + txt
+}
+
+func kindName(kind int) string {
+	switch kind {
+	case proc.METHOD_EVENT:
+		return "proc.METHOD_EVENT"
+	case proc.METHOD_STREAM:
+		return "proc.METHOD_STREAM"
+	default:
+		return "proc.METHOD_CALL"
+	}
+}
+
+// 参数中标记auto:"node"及auto:"ctx"的字段，由CreateBeans注入调用方节点及OnPreProc返回的上下文
+func autoWiredFields(args types.Type, im *genImports) string {
+	st, ok := args.Underlying().(*types.Struct)
+	if !ok {
+		return ""
+	}
+	txt := ""
+	for i := 0; i < st.NumFields(); i++ {
+		field := st.Field(i)
+		switch reflect.StructTag(st.Tag(i)).Get("auto") {
+		case "node":
+			if basic, ok := field.Type().Underlying().(*types.Basic); ok && basic.Kind() == types.String {
+				txt += "\t\targs." + field.Name() + " = from\n"
+			}
+		case "ctx":
+			switch t := field.Type().Underlying().(type) {
+			case *types.Interface:
+				if t.Empty() {
+					txt += "\t\targs." + field.Name() + " = ctx\n"
+				} else {
+					txt += "\t\targs." + field.Name() + ", _ = ctx.(" + im.typeName(field.Type()) + ")\n"
+				}
+			case *types.Pointer:
+				txt += "\t\targs." + field.Name() + ", _ = ctx.(" + im.typeName(field.Type()) + ")\n"
+			}
+		}
+	}
+	return txt
+}
+
+// 类型化客户端，调用形如client.Join(node, args)，方法名及参数类型由编译器检查
+func (g *generator) clientSource(p *genProc) string {
+	im := newImports(p.pkg.PkgPath, peersPath)
+	name := p.obj.Name()
+	clientName := name + "Client"
+
+	body := ""
+	for _, m := range p.methods {
+		argsType := im.typeName(types.NewPointer(m.args))
+		call := "c.nick+\"." + m.name + "\""
+		switch m.kind {
+		case proc.METHOD_STREAM:
+			im.add("context", "context")
+			body += "\nfunc (c *" + clientName + ") " + m.name + "(ctx context.Context, node string, args " + argsType + ") (peers.Stream, error) {\n"
+			body += "\treturn c.peer.OpenStream(ctx, node, " + call + ", args)\n"
+			body += "}\n"
+		case proc.METHOD_EVENT:
+			body += "\nfunc (c *" + clientName + ") " + m.name + "(node string, args " + argsType + ") error {\n"
+			body += "\treturn c.peer.SendEvent(node, " + call + ", args)\n"
+			body += "}\n"
+		default:
+			replyType := im.typeName(m.reply)
+			body += "\nfunc (c *" + clientName + ") " + m.name + "(node string, args " + argsType + ") (*" + replyType + ", error) {\n"
+			body += "\treply := new(" + replyType + ")\n"
+			body += "\terr := c.peer.Invoke(node, " + call + ", args, reply)\n"
+			body += "\treturn reply, err\n"
+			body += "}\n"
+			body += "\nfunc (c *" + clientName + ") " + m.name + "Async(node string, args " + argsType + ", done func(*" + replyType + ", error)) {\n"
+			body += "\treply := new(" + replyType + ")\n"
+			body += "\tc.peer.Call(node, " + call + ", args, reply, func(err error) {\n"
+			body += "\t\tdone(reply, err)\n"
+			body += "\t})\n"
+			body += "}\n"
+		}
+	}
+
+	txt := proc.GENERATED_HEADER + "\n\n"
+	txt += "package " + p.pkg.Name + "\n\n"
+	txt += im.block() + "\n"
+	txt += "// " + name + "的类型化客户端，由调用方的Peer发起请求\n"
+	txt += "type " + clientName + " struct {\n"
+	txt += "\tpeer peers.Peer\n"
+	txt += "\tnick string\n"
+	txt += "}\n\n"
+	txt += "func New" + clientName + "(peer peers.Peer) *" + clientName + " {\n"
+	txt += "\treturn New" + clientName + "WithNick(peer, \"" + name + "\")\n"
+	txt += "}\n\n"
+	txt += "// 目标Peer以自定义昵称注册时使用\n"
+	txt += "func New" + clientName + "WithNick(peer peers.Peer, nick string) *" + clientName + " {\n"
+	txt += "\treturn &" + clientName + "{peer: peer, nick: nick}\n"
+	txt += "}\n"
+	return txt + body
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "以生成结果更新testdata中的golden文件")
+
+// 将testdata中的包复制至testdata下的临时目录后生成，返回以./开头的包路径
+// 临时目录位于模块内，生成代码可引用本模块的包
+func copyPackage(t *testing.T, name string) string {
+	t.Helper()
+	tmp, err := os.MkdirTemp("testdata", "gen-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(tmp)
+	})
+	dir := filepath.Join(tmp, name)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join("testdata", name, "*.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(file)), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return "./" + filepath.ToSlash(dir)
+}
+
+// 读取生成的文件，按文件名索引
+func readGenerated(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	for _, suffix := range []string{"_meta.go", "_client.go"} {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*"+suffix))
+		for _, file := range matches {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[filepath.Base(file)] = string(data)
+		}
+	}
+	return files
+}
+
+// 依赖均由源码进行类型检查，每次生成约需数秒
+func TestGenGolden(t *testing.T) {
+	if testing.Short() {
+		t.Skip("需加载全部依赖的源码")
+	}
+	dir := copyPackage(t, "room")
+	if err := runGen([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	got := readGenerated(t, dir)
+	names := []string{"lobby_client.go", "lobby_meta.go", "room_client.go", "room_meta.go"}
+	if len(got) != len(names) {
+		t.Fatalf("生成的文件不符: %d", len(got))
+	}
+	for _, name := range names {
+		golden := filepath.Join("testdata", "golden", name+".golden")
+		if *update {
+			if err := os.WriteFile(golden, []byte(got[name]), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got[name] != string(want) {
+			t.Errorf("%s与%s不一致，确认无误后以-update更新:\n%s", name, golden, got[name])
+		}
+	}
+
+	// 已存在生成代码时再次生成，结果不变
+	if err := runGen([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	for name, txt := range readGenerated(t, dir) {
+		if txt != got[name] {
+			t.Errorf("%s两次生成的结果不一致", name)
+		}
+	}
+
+	// 生成代码可通过编译
+	if out, err := exec.Command("go", "build", dir).CombinedOutput(); err != nil {
+		t.Fatalf("生成代码未通过编译:\n%s", out)
+	}
+}
+
+// 方法签名不符合规范时报错且不生成任何文件
+func TestGenBadSignature(t *testing.T) {
+	dir := copyPackage(t, "badsig")
+	err := runGen([]string{dir})
+	if err == nil || !strings.Contains(err.Error(), "3处方法签名不符合规范") {
+		t.Fatalf("应因方法签名报错: %v", err)
+	}
+	if files := readGenerated(t, dir); len(files) != 0 {
+		t.Fatalf("报错时不应生成文件: %v", files)
+	}
+
+	g := &generator{meta: true, client: true}
+	if err := g.load([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	g.findProcs()
+	for _, p := range g.procs {
+		g.collectMethods(p)
+	}
+	errs := strings.Join(g.errs, "\n")
+	for _, want := range []string{"Broken.Join: 参数须为指针", "Broken.Leave: 返回值须为error", "Broken.Query: 参数类型须导出"} {
+		if !strings.Contains(errs, want) {
+			t.Errorf("缺少错误%q:\n%s", want, errs)
+		}
+	}
+	if !strings.Contains(errs, "badsig.go:") {
+		t.Errorf("错误应指明源码位置:\n%s", errs)
+	}
+}
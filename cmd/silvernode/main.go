@@ -0,0 +1,50 @@
+// silvernode命令行工具
+//
+//	silvernode gen [-meta=true] [-client=true] [包...]
+//	silvernode sdk [-lang=ts|cs] [-o 输出文件] schema.json
+//
+// gen在构建前为proc生成ProcMeta实现(xxx_meta.go，含方法表)及类型化客户端(xxx_client.go)，
+// 可在proc所在的包中以go:generate调用：
+//
+//	//go:generate go run github.com/silvernodes/silvernode-go/cmd/silvernode gen
+//
+// sdk由节点以peers.ExportSchema导出的接口描述生成前端(TypeScript/C#)客户端
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: silvernode <命令> [参数]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "命令:")
+	fmt.Fprintln(os.Stderr, "  gen    为proc生成ProcMeta、方法表及类型化客户端")
+	fmt.Fprintln(os.Stderr, "  sdk    由接口描述生成TypeScript/C#客户端")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "gen":
+		err = runGen(os.Args[2:])
+	case "sdk":
+		err = runSdk(os.Args[2:])
+	case "help", "-h", "--help":
+		usage()
+		return
+	default:
+		fmt.Fprintln(os.Stderr, "未知的命令:"+os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "silvernode "+os.Args[1]+": "+err.Error())
+		os.Exit(1)
+	}
+}
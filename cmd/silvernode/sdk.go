@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/silvernodes/silvernode-go/peers/schema"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+func runSdk(args []string) error {
+	flags := flag.NewFlagSet("sdk", flag.ExitOnError)
+	lang := flags.String("lang", "ts", "目标语言：ts或cs")
+	out := flags.String("o", "", "输出文件，缺省为silvernode.ts或Silvernode.cs")
+	namespace := flags.String("namespace", "Silvernode", "C#客户端的命名空间")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "用法: silvernode sdk [-lang=ts|cs] [-o 输出文件] [-namespace 命名空间] schema.json")
+		fmt.Fprintln(os.Stderr, "")
+		fmt.Fprintln(os.Stderr, "schema.json由运行中的节点以peers.ExportSchema导出，包含对外开放(非Inner)的Peer的方法及参数、应答结构。")
+		fmt.Fprintln(os.Stderr, "")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	doc, err := schema.Load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var txt string
+	switch *lang {
+	case "ts":
+		txt = schema.TypeScript(doc)
+		if *out == "" {
+			*out = "silvernode.ts"
+		}
+	case "cs":
+		txt = schema.CSharp(doc, *namespace)
+		if *out == "" {
+			*out = "Silvernode.cs"
+		}
+	default:
+		return errutil.New("不支持的语言:" + *lang)
+	}
+	if old, err := os.ReadFile(*out); err == nil && string(old) == txt {
+		return nil
+	}
+	if err := os.WriteFile(*out, []byte(txt), 0644); err != nil {
+		return errutil.Extend("写入生成代码出错:"+*out, err)
+	}
+	fmt.Println("[" + filepath.Base(*out) + "]生成完毕.")
+	return nil
+}
@@ -0,0 +1,23 @@
+package badsig
+
+type Req struct {
+}
+
+type query struct {
+}
+
+//silvernode:peer
+type Broken struct {
+}
+
+func (b *Broken) Join(args Req) error {
+	return nil
+}
+
+func (b *Broken) Leave(args *Req) (int, error) {
+	return 0, nil
+}
+
+func (b *Broken) Query(args *query, reply *Req) error {
+	return nil
+}
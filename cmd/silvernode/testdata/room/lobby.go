@@ -0,0 +1,25 @@
+package room
+
+import (
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+)
+
+type EnterReq struct {
+	User string
+}
+
+// 未标记，由注册调用识别
+type Lobby struct {
+}
+
+func (l *Lobby) Enter(args *EnterReq, reply *time.Time) error {
+	*reply = time.Now()
+	return nil
+}
+
+func Setup(hub *peers.Hub) error {
+	_, err := hub.Register(new(Lobby), nil)
+	return err
+}
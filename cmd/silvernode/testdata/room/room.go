@@ -0,0 +1,42 @@
+package room
+
+import (
+	"github.com/silvernodes/silvernode-go/peers"
+)
+
+type JoinReq struct {
+	From string      `auto:"node"`
+	Ctx  interface{} `auto:"ctx"`
+	User string
+}
+
+type JoinResp struct {
+	Seat int
+}
+
+//silvernode:peer
+type Room struct {
+	seats int
+}
+
+func (r *Room) Join(args *JoinReq, reply *JoinResp) error {
+	r.seats++
+	reply.Seat = r.seats
+	return nil
+}
+
+func (r *Room) Leave(args *JoinReq) error {
+	r.seats--
+	return nil
+}
+
+func (r *Room) Watch(args *JoinReq, stream peers.Stream) error {
+	return stream.CloseSend()
+}
+
+// 不作为Peer方法
+//
+//silvernode:ignore
+func (r *Room) Reset(seats int) {
+	r.seats = seats
+}
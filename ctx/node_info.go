@@ -2,9 +2,11 @@ package ctx
 
 import (
 	"fmt"
+	"math/rand"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/silvernodes/silvernode-go/utils"
 	"github.com/silvernodes/silvernode-go/utils/netutil"
@@ -15,6 +17,7 @@ import (
 type NodeInfo struct {
 	NodeId    string
 	Name      string
+	Host      string // 所在主机，缺省为hostname，用于判断节点是否部署在同一主机
 	EndPoints []string
 	IsPub     bool
 	BackEnds  []string
@@ -23,6 +26,119 @@ type NodeInfo struct {
 	Metrics   bool
 	Sig       string
 	UsrDatas  map[string]interface{}
+	Weight    int // 节点权重，供按权重选择节点时使用，<=0时按100计
+
+	MaxFrameSize int // 单帧数据长度上限(字节)，缺省为4MB
+	Heartbeat    HeartbeatConf
+	TLS          TLSConf
+	Reconnect    ReconnectConf            // 后端链接断开后的重连策略
+	Reconnects   map[string]ReconnectConf // 按后端节点名称单独指定的重连策略，缺省字段沿用Reconnect
+}
+
+// 重连策略，重连间隔按指数退避并附加随机抖动
+type ReconnectConf struct {
+	Disable      bool    // 关闭自动重连
+	MinInterval  int     // 首次重连的等待时间(毫秒)
+	MaxInterval  int     // 重连等待时间上限(毫秒)
+	MaxAttempts  int     // 最大重连次数，<=0表示不限次数
+	Jitter       float64 // 随机抖动比例(0~1)
+	QueueTimeout int     // 断线期间消息的最长缓存时间(毫秒)，超时后发送直接失败
+	QueueSize    int     // 断线期间最多缓存的消息条数，<0表示不缓存
+}
+
+func NewReconnectConf() ReconnectConf {
+	return ReconnectConf{
+		MinInterval:  200,
+		MaxInterval:  10000,
+		Jitter:       0.2,
+		QueueTimeout: 3000,
+		QueueSize:    1024,
+	}
+}
+
+// 以base补全未指定的字段
+func (r ReconnectConf) merge(base ReconnectConf) ReconnectConf {
+	r.Disable = r.Disable || base.Disable
+	if r.MinInterval <= 0 {
+		r.MinInterval = base.MinInterval
+	}
+	if r.MaxInterval <= 0 {
+		r.MaxInterval = base.MaxInterval
+	}
+	if r.MaxAttempts == 0 {
+		r.MaxAttempts = base.MaxAttempts
+	}
+	if r.Jitter == 0 {
+		r.Jitter = base.Jitter
+	}
+	if r.QueueTimeout <= 0 {
+		r.QueueTimeout = base.QueueTimeout
+	}
+	if r.QueueSize == 0 {
+		r.QueueSize = base.QueueSize
+	}
+	return r
+}
+
+// 第attempts次重连前的等待时间
+func (r ReconnectConf) Backoff(attempts int) time.Duration {
+	delay := float64(r.MinInterval)
+	for i := 0; i < attempts && delay < float64(r.MaxInterval); i++ {
+		delay *= 2
+	}
+	if delay > float64(r.MaxInterval) {
+		delay = float64(r.MaxInterval)
+	}
+	if r.Jitter > 0 {
+		delay *= 1 + r.Jitter*(rand.Float64()*2-1)
+	}
+	return time.Duration(delay * float64(time.Millisecond))
+}
+
+// 获取指定后端节点名称的重连策略
+func (n *NodeInfo) ReconnectPolicy(name string) ReconnectConf {
+	base := n.Reconnect.merge(NewReconnectConf())
+	if conf, exists := n.Reconnects[name]; exists {
+		return conf.merge(base)
+	}
+	return base
+}
+
+// TLS配置，供tls://及wss://终端使用
+type TLSConf struct {
+	Cert     string // 证书文件
+	Key      string // 私钥文件
+	ClientCA string // 校验对端节点证书的CA文件，配置后节点间启用双向认证
+	RootCA   string // 校验服务端证书的CA文件，缺省时使用ClientCA或系统证书
+	Insecure bool   // 跳过服务端证书校验，仅限测试环境使用
+}
+
+// 心跳检测配置
+type HeartbeatConf struct {
+	Disable  bool     // 关闭心跳检测
+	Interval int      // 心跳间隔(毫秒)
+	Misses   int      // 连续未收到回应的次数达到该值后断开链接
+	Protos   []string // 启用心跳的协议
+}
+
+func NewHeartbeatConf() HeartbeatConf {
+	return HeartbeatConf{
+		Interval: 2000,
+		Misses:   3,
+		Protos:   []string{"tcp", "udp", "ws", "tls", "wss"},
+	}
+}
+
+func (h HeartbeatConf) Enabled(proto string) bool {
+	if h.Disable {
+		return false
+	}
+	for _, p := range h.Protos {
+		if p == proto {
+			return true
+		}
+	}
+	return false
 }
 
 func NewNodeInfo() *NodeInfo {
@@ -31,6 +147,9 @@ func NewNodeInfo() *NodeInfo {
 	n.BackEnds = make([]string, 0, 0)
 	n.UsrDatas = make(map[string]interface{})
 	n.Metrics = true
+	n.Heartbeat = NewHeartbeatConf()
+	n.Reconnect = NewReconnectConf()
+	n.Reconnects = make(map[string]ReconnectConf)
 	return n
 }
 
@@ -46,6 +165,7 @@ func (n *NodeInfo) Clone() *NodeInfo {
 	clone := new(NodeInfo)
 	clone.NodeId = n.NodeId
 	clone.Name = n.Name
+	clone.Host = n.Host
 	clone.EndPoints = make([]string, 0, len(n.EndPoints))
 	for _, ep := range n.EndPoints {
 		clone.EndPoints = append(clone.EndPoints, ep)
@@ -58,6 +178,16 @@ func (n *NodeInfo) Clone() *NodeInfo {
 	clone.LogLevel = n.LogLevel
 	clone.MainPort = n.MainPort
 	clone.Metrics = n.Metrics
+	clone.Weight = n.Weight
+	clone.MaxFrameSize = n.MaxFrameSize
+	clone.Heartbeat = n.Heartbeat
+	clone.Heartbeat.Protos = append(make([]string, 0, len(n.Heartbeat.Protos)), n.Heartbeat.Protos...)
+	clone.TLS = n.TLS
+	clone.Reconnect = n.Reconnect
+	clone.Reconnects = make(map[string]ReconnectConf)
+	for k, v := range n.Reconnects {
+		clone.Reconnects[k] = v
+	}
 	clone.Sig = "..."
 	clone.UsrDatas = make(map[string]interface{})
 	for k, v := range n.UsrDatas {
@@ -66,6 +196,25 @@ func (n *NodeInfo) Clone() *NodeInfo {
 	return clone
 }
 
+// 主EndPoint，即首个非unix的终端，用于注册及跨主机访问
+func (n *NodeInfo) MainEndPoint() string {
+	for _, ep := range n.EndPoints {
+		if !strings.HasPrefix(ep, "unix://") {
+			return ep
+		}
+	}
+	return ""
+}
+
+func (n *NodeInfo) UnixEndPoint() (string, bool) {
+	for _, ep := range n.EndPoints {
+		if strings.HasPrefix(ep, "unix://") {
+			return ep, true
+		}
+	}
+	return "", false
+}
+
 func GetNodeNameFromId(nodeId string) string {
 	infos := strings.Split(nodeId, "#")
 	return infos[0]
@@ -75,6 +224,12 @@ func NodeSignature(n *NodeInfo) {
 	if _nodeId != "" {
 		return
 	}
+	SignNode(n)
+	_nodeId = n.NodeId
+}
+
+// 为节点生成id及签名，不会影响进程级的默认节点id
+func SignNode(n *NodeInfo) {
 	if n.NodeId == "" {
 		n.NodeId = n.Name + "#" + snowflake.Generate()
 	} else if n.NodeId == "k8s.metadata.name" {
@@ -83,6 +238,9 @@ func NodeSignature(n *NodeInfo) {
 	if !strings.HasPrefix(n.NodeId, n.Name+"#") {
 		n.NodeId = n.Name + "#" + n.NodeId
 	}
+	if n.Host == "" {
+		n.Host, _ = os.Hostname()
+	}
 	for i, ep := range n.EndPoints {
 		if strings.Contains(ep, "k8s.status.podIP") { // k8s网络地址适配
 			K8S_POD_IP := os.Getenv("K8S_POD_IP")
@@ -91,7 +249,6 @@ func NodeSignature(n *NodeInfo) {
 		n.EndPoints[i] = suitableEP(ep)
 	}
 	n.Sig = utils.MD5("?nodeid=" + n.NodeId + "&name=" + n.Name + "&sf=" + snowflake.Generate()) // call when register node info to DC, then other node use the sig to connect to this node <----> check url
-	_nodeId = n.NodeId
 }
 
 func suitableEP(ep string) string {
@@ -100,6 +257,9 @@ func suitableEP(ep string) string {
 		return ep
 	}
 	proto := tmpInfos[0]
+	if proto == "unix" {
+		return ep
+	}
 	tmps := strings.Split(tmpInfos[1], "/")
 	subfix := ""
 	if len(tmps) >= 2 {
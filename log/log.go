@@ -1,10 +1,13 @@
 package log
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"strings"
 	"time"
+
+	"github.com/silvernodes/silvernode-go/trace"
 )
 
 const (
@@ -97,6 +100,10 @@ func (l *Logger) Source(callstack int) string {
 }
 
 func (l *Logger) doLog(lvl int, callstack int, any interface{}, args ...interface{}) {
+	l.doLogCtx(nil, lvl, callstack+1, any, args...)
+}
+
+func (l *Logger) doLogCtx(c context.Context, lvl int, callstack int, any interface{}, args ...interface{}) {
 	if lvl < l.level {
 		return
 	}
@@ -122,6 +129,10 @@ func (l *Logger) doLog(lvl int, callstack int, any interface{}, args ...interfac
 	info.Message = msg
 	info.Source = src
 	info.SetCreated(time.Now())
+	if sc := trace.SpanContextFrom(c); sc.IsValid() {
+		info.TraceId = sc.TraceId.String()
+		info.SpanId = sc.SpanId.String()
+	}
 	if lvl <= DEBUG {
 		info.Println() // DEBUG always only to console
 	} else {
@@ -152,3 +163,21 @@ func (l *Logger) Error(arg0 interface{}, args ...interface{}) {
 func (l *Logger) Fatal(arg0 interface{}, args ...interface{}) {
 	l.doLog(FATAL, 1, arg0, args...)
 }
+
+// 以下方法附带上下文中的链路信息(TraceId及SpanId)
+
+func (l *Logger) DebugCtx(c context.Context, arg0 interface{}, args ...interface{}) {
+	l.doLogCtx(c, DEBUG, 1, arg0, args...)
+}
+
+func (l *Logger) InfoCtx(c context.Context, arg0 interface{}, args ...interface{}) {
+	l.doLogCtx(c, INFO, 1, arg0, args...)
+}
+
+func (l *Logger) WarnCtx(c context.Context, arg0 interface{}, args ...interface{}) {
+	l.doLogCtx(c, WARN, 1, arg0, args...)
+}
+
+func (l *Logger) ErrorCtx(c context.Context, arg0 interface{}, args ...interface{}) {
+	l.doLogCtx(c, ERROR, 1, arg0, args...)
+}
@@ -14,6 +14,8 @@ type LogInfo struct {
 	Source   string
 	Message  string
 	Category string
+	TraceId  string `json:",omitempty"`
+	SpanId   string `json:",omitempty"`
 }
 
 func NewLogInfo(level int, created string, source string, message string, category string) *LogInfo {
@@ -44,17 +46,12 @@ func (l *LogInfo) ToJson() (string, error) {
 }
 
 func (l *LogInfo) FormatString() string {
-	if l.Source == "" {
-		return fmt.Sprintf("[%s] [%s] [%s] %s",
-			l.Created,
-			l.Category,
-			LevelToString(l.Level),
-			l.Message)
+	txt := fmt.Sprintf("[%s] [%s] [%s]", l.Created, l.Category, LevelToString(l.Level))
+	if l.TraceId != "" {
+		txt += " [" + l.TraceId + ":" + l.SpanId + "]"
 	}
-	return fmt.Sprintf("[%s] [%s] [%s] (%s) %s",
-		l.Created,
-		l.Category,
-		LevelToString(l.Level),
-		l.Source,
-		l.Message)
+	if l.Source != "" {
+		txt += " (" + l.Source + ")"
+	}
+	return txt + " " + l.Message
 }
@@ -0,0 +1,212 @@
+package nets
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/process"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+const HANDSHAKE_TIMEOUT time.Duration = time.Second * 6
+
+// 网络设备的公共实现，包含握手验证及链接登记等流程，自定义设备嵌入后即可复用
+// 使用前需调用Init指定设备自身及协议名称
+type NetWorkerBase struct {
+	owner    INetWorker
+	proto    string
+	manager  *ConnectManager
+	listener io.Closer
+	bound    chan struct{} // 监听成功后关闭
+	closing  bool
+	release  func(conn net.Conn) // 链接关闭后释放设备自身持有的资源
+	lock     sync.Mutex
+}
+
+func (b *NetWorkerBase) Init(owner INetWorker, proto string) {
+	b.owner = owner
+	b.proto = proto
+	b.manager = _connectManager
+}
+
+// 绑定所属的链接管理器，由CreateNetWorkerWith调用
+func (b *NetWorkerBase) Bind(manager *ConnectManager) {
+	b.manager = manager
+}
+
+func (b *NetWorkerBase) Manager() *ConnectManager {
+	return b.manager
+}
+
+func (b *NetWorkerBase) Proto() string {
+	return b.proto
+}
+
+func (b *NetWorkerBase) tag() string {
+	return strings.ToUpper(b.proto) + "设备"
+}
+
+// 记录正在监听的对象，Shutdown时关闭
+// 须在绑定地址成功后调用，以此告知节点监听已就绪
+func (b *NetWorkerBase) SetListener(listener io.Closer) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.listener = listener
+	b.markListening()
+}
+
+// 监听成功后关闭
+func (b *NetWorkerBase) Listening() <-chan struct{} {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.bound == nil {
+		b.bound = make(chan struct{})
+	}
+	return b.bound
+}
+
+func (b *NetWorkerBase) markListening() {
+	if b.bound == nil {
+		b.bound = make(chan struct{})
+	}
+	select {
+	case <-b.bound:
+	default:
+		close(b.bound)
+	}
+}
+
+func (b *NetWorkerBase) Shutdown() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closing = true
+	if b.listener != nil {
+		return b.listener.Close()
+	}
+	return nil
+}
+
+func (b *NetWorkerBase) IsClosing() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.closing
+}
+
+// 校验来源信息，返回对端的节点id
+func (b *NetWorkerBase) CheckOrigin(origin string) (string, error) {
+	nodeId, err := b.manager.listener.OnCheckNode(origin) // let the gonode to check if the url is legal
+	if err != nil {
+		return "", errutil.Extend(b.tag()+"收到非法的握手验证信息!!", err)
+	}
+	return nodeId, nil
+}
+
+// 主动发起链接的一方发送握手信息并等待回应，成功后登记链接
+func (b *NetWorkerBase) DoHandShake(conn net.Conn, worker process.Service, origin string, url string, nodeId string) error {
+	info := make(map[string]string)
+	info["Header"] = "SILVERNODE/" + strings.ToUpper(b.proto)
+	info["Origin"] = origin
+	datas, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err2 := b.owner.Send(conn, datas); err2 != nil {
+		return err2
+	}
+
+	buf := make([]byte, 5, 5) // the rev buf
+	if err := conn.SetReadDeadline(time.Now().Add(HANDSHAKE_TIMEOUT)); err != nil {
+		return err
+	}
+	n, err := io.ReadFull(conn, buf)
+	if err != nil {
+		return errutil.Extend(b.tag()+"握手验证失败!!", err)
+	}
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		return err
+	}
+	if n == 5 && string(buf) == "#hsuc" {
+		b.OnConn(conn, worker, nodeId, url)
+		return nil
+	}
+	return errutil.New(b.tag() + "收到非法的握手验证信息!!")
+}
+
+// 被动接受链接的一方校验握手信息并回应，成功后登记链接
+func (b *NetWorkerBase) DealHandShake(conn net.Conn, worker process.Service, msg []byte) error {
+	var datas map[string]string
+	if err := json.Unmarshal(msg, &datas); err != nil {
+		return err
+	}
+	origin, exists := datas["Origin"]
+	if !exists {
+		return errutil.New(b.tag() + "握手验证信息丢失!")
+	}
+	nodeId, err := b.CheckOrigin(origin)
+	if err != nil {
+		return err
+	}
+	if _, err2 := conn.Write([]byte("#hsuc")); err2 != nil {
+		return errutil.Extend(b.tag()+"握手验证信息回复失败", err2)
+	}
+	b.OnConn(conn, worker, nodeId, LOCAL)
+	return nil
+}
+
+// 登记新建立的链接并通知监听者
+func (b *NetWorkerBase) OnConn(conn net.Conn, worker process.Service, nodeId string, url string) {
+	// record the set from nodeId to conn
+	_, err := b.manager.AddConnectInfo(nodeId, url, b.proto, conn, worker, b.owner)
+	if err != nil {
+		b.OnError(conn, err)
+	} else {
+		b.manager.listener.OnConnect(nodeId)
+	}
+}
+
+func (b *NetWorkerBase) OnMsg(conn net.Conn, nodeId string, msg []byte) {
+	if !b.manager.CheckPingPong(nodeId, msg) {
+		b.manager.listener.OnMessage(nodeId, msg)
+	}
+}
+
+func (b *NetWorkerBase) OnClose(nodeId string, conn net.Conn, reason error) {
+	b.manager.listener.OnClose(nodeId, reason)
+	b.manager.RemoveConnectInfo(nodeId, conn) // remove the closed conn from local record
+	b.onRelease(conn)
+	conn.Close()
+}
+
+func (b *NetWorkerBase) OnError(conn net.Conn, err error) {
+	if conn != nil {
+		nodeId, exists := b.manager.GetNodeIdByConn(conn)
+		if exists {
+			b.OnClose(nodeId, conn, err) // close the conn with errors
+		} else {
+			b.onRelease(conn)
+			conn.Close()
+			if !errutil.IsEOF(err) {
+				b.manager.listener.OnError(err)
+			}
+		}
+	} else {
+		b.manager.listener.OnError(err)
+	}
+}
+
+func (b *NetWorkerBase) Close(nodeId string, conn net.Conn, err error) error {
+	b.manager.listener.OnClose(nodeId, err)
+	b.manager.RemoveConnectInfo(nodeId, conn)
+	b.onRelease(conn)
+	return conn.Close()
+}
+
+func (b *NetWorkerBase) onRelease(conn net.Conn) {
+	if b.release != nil {
+		b.release(conn)
+	}
+}
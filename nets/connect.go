@@ -1,13 +1,15 @@
 package nets
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/silvernodes/silvernode-go/ctx"
 	"github.com/silvernodes/silvernode-go/process"
 	"github.com/silvernodes/silvernode-go/utils/errutil"
-	"github.com/silvernodes/silvernode-go/utils/timeutil"
 )
 
 type ConnectInfo struct {
@@ -17,8 +19,18 @@ type ConnectInfo struct {
 	conn      net.Conn
 	worker    process.Service
 	netWorker INetWorker
-	ping      bool
-	ts        int64
+	ts        int64 // 最近一次收到数据的时间
+	pingTs    int64 // 最近一次发出心跳的时间
+	misses    int   // 连续未收到回应的心跳次数
+	rtt       int64
+	pinging   bool // 心跳包仍在发送中(对端不读取时写入会阻塞)，期间不再重复发送
+	ponging   bool
+	lock      sync.Mutex
+}
+
+// 可发送协议层心跳包的网络设备，未实现时使用#ping/#pong消息
+type Pinger interface {
+	Ping(conn net.Conn) error
 }
 
 func NewConnectInfo(nodeId string, url string, proto string, conn net.Conn, worker process.Service, netWorker INetWorker) *ConnectInfo {
@@ -29,6 +41,7 @@ func NewConnectInfo(nodeId string, url string, proto string, conn net.Conn, work
 	info.conn = conn
 	info.worker = worker
 	info.netWorker = netWorker
+	info.ts = time.Now().UnixNano()
 	return info
 }
 
@@ -52,31 +65,92 @@ func (i *ConnectInfo) NetWorker() INetWorker {
 	return i.netWorker
 }
 
-func (i *ConnectInfo) Ping() {
-	go i.Send([]byte("#ping"))
-	i.ping = true
+// 发送心跳，返回当前连续未收到回应的次数
+// 上一次心跳仍阻塞在写入中时不再发送，由累计的未回应次数触发断开
+func (i *ConnectInfo) Ping() int {
+	i.lock.Lock()
+	if i.pingTs > 0 && i.ts < i.pingTs {
+		i.misses++
+	} else {
+		i.misses = 0
+	}
+	i.pingTs = time.Now().UnixNano()
+	misses := i.misses
+	pinging := i.pinging
+	i.pinging = true
+	i.lock.Unlock()
+	if pinging {
+		return misses
+	}
+	go func() {
+		if pinger, ok := i.netWorker.(Pinger); ok {
+			pinger.Ping(i.conn)
+		} else {
+			i.Send([]byte("#ping"))
+		}
+		i.lock.Lock()
+		i.pinging = false
+		i.lock.Unlock()
+	}()
+	return misses
 }
 
 func (i *ConnectInfo) Pong() {
-	go i.Send([]byte("#pong"))
-	i.ping = true
+	i.lock.Lock()
+	ponging := i.ponging
+	i.ponging = true
+	i.lock.Unlock()
+	if ponging { // 上一次回应尚未发出，对端以最新的一次为准
+		return
+	}
+	go func() {
+		i.Send([]byte("#pong"))
+		i.lock.Lock()
+		i.ponging = false
+		i.lock.Unlock()
+	}()
+}
+
+// 收到心跳回应
+func (i *ConnectInfo) OnPong() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.ts = time.Now().UnixNano()
+	if i.pingTs > 0 {
+		i.rtt = i.ts - i.pingTs
+	}
+	i.misses = 0
+}
+
+// 最近一次心跳的往返耗时，尚未完成任何心跳时返回0
+func (i *ConnectInfo) RTT() time.Duration {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return time.Duration(i.rtt)
 }
 
 func (i *ConnectInfo) CheckPingPong(msg []byte) bool {
-	i.ping = false
-	i.ts = timeutil.MilliSecond()
-	if msg[0] == 35 && len(msg) == 5 {
+	if len(msg) == 5 && msg[0] == 35 {
 		strmsg := string(msg)
 		if strmsg == "#pong" {
+			i.OnPong()
 			return true
 		} else if strmsg == "#ping" {
+			i.active()
 			i.Pong()
 			return true
 		}
 	}
+	i.active()
 	return false
 }
 
+func (i *ConnectInfo) active() {
+	i.lock.Lock()
+	i.ts = time.Now().UnixNano()
+	i.lock.Unlock()
+}
+
 func (i *ConnectInfo) Send(msg []byte) error {
 	return i.netWorker.Send(i.conn, msg)
 }
@@ -87,8 +161,14 @@ func (i *ConnectInfo) Close(err error) error {
 }
 
 type ConnectManager struct {
-	kv map[string]map[string]*ConnectInfo
-	vk map[net.Conn]string
+	kv           map[string]map[string]*ConnectInfo
+	vk           map[net.Conn]string
+	listener     *NetEventListener
+	maxFrameSize int
+	heartbeat    ctx.HeartbeatConf
+	beater       process.Service
+	serverTLS    *tls.Config
+	clientTLS    *tls.Config
 	sync.RWMutex
 }
 
@@ -96,9 +176,66 @@ func NewConnectManager() *ConnectManager {
 	c := new(ConnectManager)
 	c.kv = make(map[string]map[string]*ConnectInfo)
 	c.vk = make(map[net.Conn]string)
+	c.maxFrameSize = DEFAULT_MAX_FRAME_SIZE
+	c.heartbeat = ctx.NewHeartbeatConf()
 	return c
 }
 
+// 设定单帧数据长度上限，超出上限的数据帧将被拒绝，小于等于0时使用默认值
+func (c *ConnectManager) SetMaxFrameSize(size int) {
+	c.Lock()
+	defer c.Unlock()
+	if size <= 0 {
+		size = DEFAULT_MAX_FRAME_SIZE
+	}
+	c.maxFrameSize = size
+}
+
+func (c *ConnectManager) MaxFrameSize() int {
+	c.RLock()
+	defer c.RUnlock()
+	return c.maxFrameSize
+}
+
+// 设定tls://及wss://终端使用的证书
+func (c *ConnectManager) SetTLS(conf ctx.TLSConf, isPub bool) error {
+	server, client, err := NewTLSConfigs(conf, isPub)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.serverTLS = server
+	c.clientTLS = client
+	return nil
+}
+
+func (c *ConnectManager) ServerTLS() (*tls.Config, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if c.serverTLS == nil {
+		return nil, errutil.New("尚未配置TLS证书，无法开启加密监听!")
+	}
+	return c.serverTLS, nil
+}
+
+func (c *ConnectManager) ClientTLS() *tls.Config {
+	c.RLock()
+	defer c.RUnlock()
+	if c.clientTLS == nil {
+		return &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return c.clientTLS
+}
+
+func (c *ConnectManager) BindEventListener(eventListener *NetEventListener) {
+	c.listener = eventListener
+}
+
+func (c *ConnectManager) EventListener() *NetEventListener {
+	return c.listener
+}
+
 func (c *ConnectManager) AddConnectInfo(nodeId string, url string, proto string, conn net.Conn, worker process.Service, netWorker INetWorker) (*ConnectInfo, error) {
 	c.Lock()
 	defer c.Unlock()
@@ -146,27 +283,45 @@ func (c *ConnectManager) GetNodeIdByConn(conn net.Conn) (string, bool) {
 	return nodeId, exist
 }
 
+// 按配置开启心跳检测，重复调用时仅更新配置
+func (c *ConnectManager) StartHeartbeat(conf ctx.HeartbeatConf) {
+	c.Lock()
+	defer c.Unlock()
+	c.heartbeat = conf
+	if c.beater != nil || conf.Disable || conf.Interval <= 0 {
+		return
+	}
+	c.beater = process.SpawnS()
+	c.beater.StartTick(c.PingPong, conf.Interval, nil)
+}
+
+func (c *ConnectManager) StopHeartbeat() {
+	c.Lock()
+	beater := c.beater
+	c.beater = nil
+	c.Unlock()
+	if beater != nil {
+		beater.Terminate()
+	}
+}
+
+// 执行一轮心跳检测，关闭连续多次未回应的链接
 func (c *ConnectManager) PingPong() {
-	dirtyInfo := make([]*ConnectInfo, 0, 100)
-	for {
-		ms := timeutil.MilliSecond()
-		process.Sleep(2000)
-		for _, infos := range c.kv {
-			for _, info := range infos {
-				if info.proto == TCP || info.proto == UDP {
-					if info.ping {
-						dirtyInfo = append(dirtyInfo, info)
-					} else if ms-info.ts > 2000 {
-						info.Ping()
-					}
-				}
+	c.RLock()
+	conf := c.heartbeat
+	infos := make([]*ConnectInfo, 0, len(c.vk))
+	for _, kv := range c.kv {
+		for _, info := range kv {
+			if conf.Enabled(info.proto) {
+				infos = append(infos, info)
 			}
-			process.Sleep(1)
 		}
-		for _, info := range dirtyInfo {
-			info.Close(errutil.New("PingPong超时!"))
+	}
+	c.RUnlock()
+	for _, info := range infos {
+		if misses := info.Ping(); conf.Misses > 0 && misses >= conf.Misses {
+			info.Close(errutil.New(fmt.Sprintf("心跳超时:连续%d次未收到回应", misses)))
 		}
-		dirtyInfo = dirtyInfo[0:0]
 	}
 }
 
@@ -178,6 +333,12 @@ func (c *ConnectManager) CheckPingPong(nodeId string, msg []byte) bool {
 	return false
 }
 
+func (c *ConnectManager) OnPong(nodeId string) {
+	if info, exist := c.GetConnectInfo(nodeId); exist {
+		info.OnPong()
+	}
+}
+
 func (c *ConnectManager) GetNodes(name string) []string {
 	c.RLock()
 	defer c.RUnlock()
@@ -191,6 +352,20 @@ func (c *ConnectManager) GetNodes(name string) []string {
 	return ids
 }
 
+func (c *ConnectManager) CloseAll(reason error) {
+	c.RLock()
+	infos := make([]*ConnectInfo, 0, len(c.vk))
+	for _, kv := range c.kv {
+		for _, info := range kv {
+			infos = append(infos, info)
+		}
+	}
+	c.RUnlock()
+	for _, info := range infos {
+		info.Close(reason)
+	}
+}
+
 func (c *ConnectManager) KV(name string) map[string]*ConnectInfo {
 	if _, exists := c.kv[name]; !exists {
 		c.kv[name] = make(map[string]*ConnectInfo)
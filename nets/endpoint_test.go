@@ -0,0 +1,116 @@
+package nets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+type endpoint struct {
+	manager *ConnectManager
+	worker  INetWorker
+	linked  chan string
+	msgs    chan []byte
+	errs    chan error
+}
+
+// 以proto设备收发消息的一端，对端的节点id固定为peer
+func newEndpoint(t *testing.T, proto string, peer string) *endpoint {
+	e := new(endpoint)
+	e.manager = NewConnectManager()
+	e.linked = make(chan string, 1)
+	e.msgs = make(chan []byte, 4096)
+	e.errs = make(chan error, 16)
+	e.manager.BindEventListener(&NetEventListener{
+		OnConnect: func(nodeId string) {
+			e.linked <- nodeId
+		},
+		OnMessage: func(nodeId string, msg []byte) {
+			e.msgs <- msg
+		},
+		OnClose: func(nodeId string, err error) {
+			if !errutil.IsEOF(err) {
+				e.errs <- err
+			}
+		},
+		OnError: func(err error) {
+			e.errs <- err
+		},
+		OnCheckNode: func(origin string) (string, error) {
+			return peer, nil
+		},
+	})
+	worker, err := CreateNetWorkerWith(proto, e.manager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.worker = worker
+	return e
+}
+
+func (e *endpoint) conn(t *testing.T, nodeId string) *ConnectInfo {
+	info, exists := e.manager.GetConnectInfo(nodeId)
+	if !exists {
+		t.Fatal("链接不存在:" + nodeId)
+	}
+	return info
+}
+
+// 在后台开启监听，绑定成功后返回，测试结束时停止监听
+func (e *endpoint) listen(t *testing.T, url string) {
+	t.Helper()
+	failed := make(chan error, 1)
+	go func() {
+		failed <- e.worker.Listen(url)
+	}()
+	t.Cleanup(func() {
+		e.worker.Shutdown()
+	})
+	select {
+	case <-e.worker.(IListeningNetWorker).Listening():
+	case err := <-failed:
+		t.Fatal("开启监听失败:", err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("等待监听超时")
+	}
+}
+
+// 限时轮询直至cond成立，用于等待异步完成的状态变化
+func eventually(t *testing.T, cond func() bool, msg string) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second * 5)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal(msg)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+// 等待链接建立，返回对端的节点id
+func (e *endpoint) waitLinked(t *testing.T) string {
+	t.Helper()
+	select {
+	case nodeId := <-e.linked:
+		return nodeId
+	case err := <-e.errs:
+		t.Fatal(err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("等待链接建立超时")
+	}
+	return ""
+}
+
+func (e *endpoint) recv(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case msg := <-e.msgs:
+		return msg
+	case err := <-e.errs:
+		t.Fatal(err)
+	case <-time.After(time.Second * 5):
+		t.Fatal("等待消息超时")
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package nets
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+const (
+	PCK_MIN_SIZE  int   = 6          // 旧版 |--- header 4bytes ---|--- length 2 bytes ---|--- other datas --- ....
+	PCK_HEADER    int32 = 0x2123676f // !#go 旧版帧头，仅做兼容解析
+	PCK_HEADER_V1 int32 = 0x2124676f // !$go |--- header 4bytes ---|--- version 1byte ---|--- length varint 1~5bytes ---|--- other datas --- ....
+	PCK_VERSION   byte  = 1
+
+	DEFAULT_MAX_FRAME_SIZE int = 4 * 1024 * 1024 // 默认单帧数据上限4MB
+	TCP_BUFFER_SIZE        int = 8192            // 接收缓冲区初始大小
+)
+
+const pckV1Prefix int = 5 // header + version
+
+// 将数据封装为一帧
+func EncodeFrame(msg []byte) []byte {
+	datalen := len(msg)
+	frame := make([]byte, pckV1Prefix+binary.MaxVarintLen32+datalen)
+	binary.LittleEndian.PutUint32(frame, uint32(PCK_HEADER_V1))
+	frame[4] = PCK_VERSION
+	n := binary.PutUvarint(frame[pckV1Prefix:], uint64(datalen))
+	copy(frame[pckV1Prefix+n:], msg)
+	return frame[:pckV1Prefix+n+datalen]
+}
+
+// 从数据头部解析出一帧，返回帧内数据及整帧长度
+// 数据不完整时返回的数据为nil，长度为解析该帧至少需要的字节数
+func DecodeFrame(datas []byte, maxFrameSize int) ([]byte, int, error) {
+	count := len(datas)
+	if count < 4 {
+		return nil, 4, nil
+	}
+	head := int32(binary.LittleEndian.Uint32(datas))
+	switch head {
+	case PCK_HEADER_V1:
+		if count < pckV1Prefix+1 {
+			return nil, pckV1Prefix + 1, nil
+		}
+		if datas[4] != PCK_VERSION {
+			return nil, 0, errutil.New(fmt.Sprintf("不支持的数据帧版本:%d", datas[4]))
+		}
+		length, n := binary.Uvarint(datas[pckV1Prefix:])
+		if n == 0 {
+			if count-pckV1Prefix >= binary.MaxVarintLen32 {
+				return nil, 0, errutil.New("数据帧长度信息非法!")
+			}
+			return nil, count + 1, nil
+		}
+		if n < 0 || n > binary.MaxVarintLen32 {
+			return nil, 0, errutil.New("数据帧长度信息溢出!")
+		}
+		return sliceFrame(datas, pckV1Prefix+n, length, maxFrameSize)
+	case PCK_HEADER:
+		if count < PCK_MIN_SIZE {
+			return nil, PCK_MIN_SIZE, nil
+		}
+		length := binary.LittleEndian.Uint16(datas[4:])
+		return sliceFrame(datas, PCK_MIN_SIZE, uint64(length), maxFrameSize)
+	default:
+		return nil, 0, errutil.New(fmt.Sprintf("非法的数据帧头:%#x", uint32(head)))
+	}
+}
+
+func sliceFrame(datas []byte, prefix int, length uint64, maxFrameSize int) ([]byte, int, error) {
+	if length > uint64(maxFrameSize) {
+		return nil, 0, errutil.New(fmt.Sprintf("数据帧长度超出上限:%d>%d", length, maxFrameSize))
+	}
+	size := prefix + int(length)
+	if len(datas) < size {
+		return nil, size, nil
+	}
+	return datas[prefix:size], size, nil
+}
+
+func checkFrameSize(msg []byte, maxFrameSize int) error {
+	if len(msg) > maxFrameSize {
+		return errutil.New(fmt.Sprintf("发送数据超出单帧长度上限:%d>%d", len(msg), maxFrameSize))
+	}
+	return nil
+}
+
+// 可增长的接收缓冲区
+type TcpBuffer struct {
+	_count  int
+	_offset int
+	_buffer []byte
+	_len    int
+	_init   int
+}
+
+func NewTcpBuffer(buf []byte) *TcpBuffer {
+	t := new(TcpBuffer)
+	t._buffer = buf
+	t._offset = 0
+	t._count = 0
+	t._len = len(t._buffer)
+	t._init = t._len
+	return t
+}
+
+func (t *TcpBuffer) Clear() {
+	t._offset = 0
+	t._count = 0
+	for i := 0; i < t._len; i++ {
+		t._buffer[i] = byte(0)
+	}
+}
+
+func (t *TcpBuffer) Reset() {
+	copy(t._buffer, t.Slice())
+	t._offset = 0
+}
+
+// 尚未写入数据的空闲区域
+func (t *TcpBuffer) Buffer() []byte {
+	return t._buffer[t._offset+t._count:]
+}
+
+func (t *TcpBuffer) Slice() []byte {
+	return t._buffer[t._offset : t._offset+t._count]
+}
+
+func (t *TcpBuffer) Count() int {
+	return t._count
+}
+
+func (t *TcpBuffer) Offset() int {
+	return t._offset
+}
+
+func (t *TcpBuffer) Capcity() int {
+	return t._len - t._offset - t._count
+}
+
+// 保证缓冲区能够容纳size字节的数据，空间不足时先整理再按倍数扩容
+// 缓冲区为空时收缩回初始大小，避免单个大帧长期占用内存
+func (t *TcpBuffer) Ensure(size int) {
+	if t._count <= 0 && t._len > t._init && size <= t._init {
+		t._buffer = make([]byte, t._init)
+		t._len = t._init
+		t._offset = 0
+		return
+	}
+	if t._offset+size <= t._len {
+		return
+	}
+	t.Reset()
+	if size <= t._len {
+		return
+	}
+	newLen := t._len * 2
+	for newLen < size {
+		newLen *= 2
+	}
+	buf := make([]byte, newLen)
+	copy(buf, t.Slice())
+	t._buffer = buf
+	t._len = newLen
+}
+
+func (t *TcpBuffer) AddDataLen(count int) {
+	t._count += count
+}
+
+func (t *TcpBuffer) DeleteData(count int) {
+	if t._count >= count {
+		t._offset += count
+		t._count -= count
+	}
+}
+
+func (t *TcpBuffer) Dispose() {
+	t._buffer = nil
+	t._offset = 0
+	t._count = 0
+	t._len = 0
+}
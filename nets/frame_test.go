@@ -0,0 +1,217 @@
+package nets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func legacyFrame(msg []byte) []byte {
+	frame := make([]byte, PCK_MIN_SIZE+len(msg))
+	binary.LittleEndian.PutUint32(frame, uint32(PCK_HEADER))
+	binary.LittleEndian.PutUint16(frame[4:], uint16(len(msg)))
+	copy(frame[PCK_MIN_SIZE:], msg)
+	return frame
+}
+
+func payload(size int) []byte {
+	msg := make([]byte, size)
+	for i := range msg {
+		msg[i] = byte(i % 251)
+	}
+	return msg
+}
+
+func TestFrameVarintBoundaries(t *testing.T) {
+	cases := []struct {
+		size   int
+		varint int // 长度字段占用的字节数
+	}{
+		{0, 1},
+		{1, 1},
+		{127, 1},
+		{128, 2},
+		{16383, 2},
+		{16384, 3},
+		{2097151, 3},
+		{2097152, 4},
+	}
+	for _, c := range cases {
+		msg := payload(c.size)
+		frame := EncodeFrame(msg)
+		if want := pckV1Prefix + c.varint + c.size; len(frame) != want {
+			t.Errorf("size=%d: 帧长度 %d, 期望 %d", c.size, len(frame), want)
+			continue
+		}
+		if int32(binary.LittleEndian.Uint32(frame)) != PCK_HEADER_V1 || frame[4] != PCK_VERSION {
+			t.Errorf("size=%d: 帧头不符 %x", c.size, frame[:pckV1Prefix])
+			continue
+		}
+		data, size, err := DecodeFrame(frame, DEFAULT_MAX_FRAME_SIZE)
+		if err != nil {
+			t.Errorf("size=%d: %v", c.size, err)
+			continue
+		}
+		if size != len(frame) || !bytes.Equal(data, msg) {
+			t.Errorf("size=%d: 解析结果不符 size=%d len=%d", c.size, size, len(data))
+		}
+	}
+}
+
+func TestFramePartialReads(t *testing.T) {
+	frames := [][]byte{
+		EncodeFrame(payload(0)),
+		EncodeFrame(payload(5)),
+		EncodeFrame(payload(300)), // 2字节长度
+		legacyFrame(payload(40)),
+		EncodeFrame(payload(20000)), // 超出接收缓冲区初始大小
+		EncodeFrame(payload(127)),
+	}
+	stream := bytes.Join(frames, nil)
+
+	// 帧内任意位置截断时均应等待更多数据
+	for _, frame := range frames {
+		for i := 0; i < len(frame); i++ {
+			data, need, err := DecodeFrame(frame[:i], DEFAULT_MAX_FRAME_SIZE)
+			if err != nil || data != nil {
+				t.Fatalf("截断于%d/%d: data=%v err=%v", i, len(frame), data != nil, err)
+			}
+			if need <= i || need > len(frame) {
+				t.Fatalf("截断于%d/%d: 所需长度%d不合理", i, len(frame), need)
+			}
+		}
+	}
+
+	cases := []struct {
+		name  string
+		chunk int
+	}{
+		{"byte", 1},
+		{"small", 3},
+		{"odd", 7},
+		{"header", pckV1Prefix + 1},
+		{"buffer", TCP_BUFFER_SIZE},
+		{"whole", len(stream)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := readFrames(t, stream, c.chunk)
+			if len(got) != len(frames) {
+				t.Fatalf("解析出%d帧, 期望%d帧", len(got), len(frames))
+			}
+			for i, frame := range frames {
+				want, _, _ := DecodeFrame(frame, DEFAULT_MAX_FRAME_SIZE)
+				if !bytes.Equal(got[i], want) {
+					t.Fatalf("第%d帧数据不符", i)
+				}
+			}
+		})
+	}
+}
+
+// 与TcpNetWorker的接收流程一致，每次最多读入chunk字节
+func readFrames(t *testing.T, stream []byte, chunk int) [][]byte {
+	rcvbuf := NewTcpBuffer(make([]byte, TCP_BUFFER_SIZE))
+	ret := make([][]byte, 0)
+	for len(stream) > 0 {
+		n := copy(rcvbuf.Buffer(), stream[:min(chunk, len(stream))])
+		stream = stream[n:]
+		rcvbuf.AddDataLen(n)
+		need := rcvbuf.Count() + 1
+		for rcvbuf.Count() > 0 {
+			frame, size, err := DecodeFrame(rcvbuf.Slice(), DEFAULT_MAX_FRAME_SIZE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if frame == nil {
+				need = size
+				break
+			}
+			ret = append(ret, append([]byte(nil), frame...))
+			rcvbuf.DeleteData(size)
+			need = rcvbuf.Count() + 1
+		}
+		rcvbuf.Reset()
+		rcvbuf.Ensure(need)
+	}
+	if rcvbuf.Count() != 0 {
+		t.Fatalf("残留%d字节未解析", rcvbuf.Count())
+	}
+	return ret
+}
+
+func TestFrameOversize(t *testing.T) {
+	const limit = 1024
+	overflow := []byte{0x6f, 0x67, 0x24, 0x21, PCK_VERSION, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}
+	cases := []struct {
+		name  string
+		frame []byte
+		err   string // 为空时应能正常解析
+	}{
+		{"limit", EncodeFrame(payload(limit)), ""},
+		{"over", EncodeFrame(payload(limit + 1)), "超出上限"},
+		{"far over", EncodeFrame(payload(limit * 64)), "超出上限"},
+		{"header only", EncodeFrame(payload(limit + 1))[:pckV1Prefix+2], "超出上限"}, // 仅凭长度信息即可拒绝
+		{"legacy limit", legacyFrame(payload(limit)), ""},
+		{"legacy over", legacyFrame(payload(limit + 1)), "超出上限"},
+		{"varint overflow", overflow, "长度信息"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, _, err := DecodeFrame(c.frame, limit)
+			if c.err == "" {
+				if err != nil || data == nil {
+					t.Fatalf("应能解析: data=%v err=%v", data != nil, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("期望包含%q的错误, 实际为%v", c.err, err)
+			}
+		})
+	}
+
+	if err := checkFrameSize(payload(limit), limit); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFrameSize(payload(limit+1), limit); err == nil {
+		t.Fatal("发送超出上限的数据应返回错误")
+	}
+}
+
+func TestFrameLegacyFallback(t *testing.T) {
+	msg := []byte("legacy frame")
+	badVersion := EncodeFrame(msg)
+	badVersion[4] = PCK_VERSION + 1
+	cases := []struct {
+		name  string
+		frame []byte
+		want  []byte
+		err   string
+	}{
+		{"legacy", legacyFrame(msg), msg, ""},
+		{"legacy empty", legacyFrame(nil), []byte{}, ""},
+		{"legacy max", legacyFrame(payload(0xffff)), payload(0xffff), ""},
+		{"v1", EncodeFrame(msg), msg, ""},
+		{"unknown version", badVersion, nil, "版本"},
+		{"unknown header", []byte{1, 2, 3, 4, 5, 6}, nil, "帧头"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data, size, err := DecodeFrame(c.frame, DEFAULT_MAX_FRAME_SIZE)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("期望包含%q的错误, 实际为%v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != len(c.frame) || !bytes.Equal(data, c.want) {
+				t.Fatalf("解析结果不符: size=%d/%d", size, len(c.frame))
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package nets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/netutil"
+)
+
+func tcpUrl(t *testing.T) string {
+	t.Helper()
+	port, err := netutil.GetAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fmt.Sprintf("tcp://127.0.0.1:%d", port)
+}
+
+func fastHeartbeat() ctx.HeartbeatConf {
+	return ctx.HeartbeatConf{Interval: 50, Misses: 3, Protos: []string{TCP}}
+}
+
+// 完成握手后不再读取任何数据的对端
+func silentPeer(t *testing.T, url string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "tcp://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	datas, _ := json.Marshal(map[string]string{"Header": "SILVERNODE/TCP", "Origin": "silent"})
+	if _, err := conn.Write(EncodeFrame(datas)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "#hsuc" {
+		t.Fatalf("握手失败: %q %v", buf, err)
+	}
+	return conn
+}
+
+func TestHeartbeatRTT(t *testing.T) {
+	url := tcpUrl(t)
+	server := newEndpoint(t, TCP, "client")
+	client := newEndpoint(t, TCP, "server")
+	server.manager.StartHeartbeat(fastHeartbeat())
+	defer server.manager.StopHeartbeat()
+	server.listen(t, url)
+	if err := client.worker.Connect("server", url, "client"); err != nil {
+		t.Fatal(err)
+	}
+	server.waitLinked(t)
+	defer client.manager.CloseAll(errutil.EOF())
+
+	info := server.conn(t, "client")
+	deadline := time.Now().Add(time.Second * 5)
+	for info.RTT() <= 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("未收到心跳回应")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	time.Sleep(time.Millisecond * 300) // 持续回应的链接不会因心跳断开
+	select {
+	case err := <-server.errs:
+		t.Fatal(err)
+	default:
+	}
+	if _, exists := server.manager.GetConnectInfo("client"); !exists {
+		t.Fatal("链接不应被关闭")
+	}
+}
+
+// 对端不回应心跳时，连续Misses次后关闭链接并通知OnClose
+func TestHeartbeatSilentPeer(t *testing.T) {
+	url := tcpUrl(t)
+	server := newEndpoint(t, TCP, "silent")
+	server.listen(t, url)
+	silentPeer(t, url)
+	server.waitLinked(t)
+
+	begin := time.Now()
+	server.manager.StartHeartbeat(fastHeartbeat())
+	defer server.manager.StopHeartbeat()
+	select {
+	case err := <-server.errs:
+		if !strings.Contains(err.Error(), "心跳超时:连续3次") {
+			t.Fatalf("关闭原因不符: %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("未回应心跳的链接未被关闭")
+	}
+	if elapsed := time.Since(begin); elapsed < time.Millisecond*150 {
+		t.Fatalf("应在连续3次未回应后关闭: %v", elapsed)
+	}
+	eventually(t, func() bool { // 先通知OnClose再移除链接
+		_, exists := server.manager.GetConnectInfo("silent")
+		return !exists
+	}, "关闭后链接应被移除")
+}
+
+// 写入阻塞的设备
+type stuckNetWorker struct {
+	sends   int32
+	release chan struct{}
+}
+
+func (s *stuckNetWorker) Listen(url string) error                           { return nil }
+func (s *stuckNetWorker) Connect(nodeId string, url string, o string) error { return nil }
+func (s *stuckNetWorker) Close(nodeId string, conn net.Conn, e error) error { return nil }
+func (s *stuckNetWorker) Shutdown() error                                   { return nil }
+
+func (s *stuckNetWorker) Send(conn net.Conn, msg []byte) error {
+	atomic.AddInt32(&s.sends, 1)
+	<-s.release
+	return nil
+}
+
+// 上一次心跳阻塞在写入中时不再重复发送
+func TestHeartbeatInflight(t *testing.T) {
+	stuck := &stuckNetWorker{release: make(chan struct{})}
+	info := NewConnectInfo("stuck", LOCAL, TCP, nil, nil, stuck)
+	for i := 0; i < 10; i++ {
+		if misses := info.Ping(); misses != i {
+			t.Fatalf("未回应次数不符: got %d, want %d", misses, i)
+		}
+		info.Pong()
+	}
+	time.Sleep(time.Millisecond * 50)
+	if sends := atomic.LoadInt32(&stuck.sends); sends != 2 { // 一次心跳及一次回应
+		t.Fatalf("写入阻塞期间不应重复发送: %d", sends)
+	}
+	close(stuck.release)
+	time.Sleep(time.Millisecond * 50)
+	info.Ping()
+	time.Sleep(time.Millisecond * 50)
+	if sends := atomic.LoadInt32(&stuck.sends); sends != 3 {
+		t.Fatalf("写入完成后应恢复发送: %d", sends)
+	}
+}
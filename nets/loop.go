@@ -0,0 +1,240 @@
+package nets
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/process"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 进程内回环设备，不占用任何套接字，常用于单进程多节点部署及测试
+// 地址格式形如 loop://127.0.0.1:30001
+type LoopNetWorker struct {
+	NetWorkerBase
+	addr string
+	done chan struct{}
+	sync.Mutex
+}
+
+var _loopListeners map[string]*LoopNetWorker
+var _loopLock sync.RWMutex
+
+func init() {
+	_loopListeners = make(map[string]*LoopNetWorker)
+}
+
+func NewLoopNetWorker() *LoopNetWorker {
+	l := new(LoopNetWorker)
+	l.Init(l, LOOP)
+	return l
+}
+
+func loopAddr(url string) string {
+	url = strings.TrimPrefix(url, LOOP+"://")
+	infos := strings.Split(url, "/")
+	return infos[0]
+}
+
+func (l *LoopNetWorker) Listen(url string) error {
+	addr := loopAddr(url)
+	_loopLock.Lock()
+	if _, exists := _loopListeners[addr]; exists {
+		_loopLock.Unlock()
+		return errutil.New("LOOP设备地址已被占用:" + addr)
+	}
+	_loopListeners[addr] = l
+	_loopLock.Unlock()
+
+	l.Lock()
+	l.addr = addr
+	l.done = make(chan struct{})
+	done := l.done
+	l.Unlock()
+	l.lock.Lock()
+	l.markListening()
+	l.lock.Unlock()
+	<-done
+	return nil
+}
+
+func (l *LoopNetWorker) Connect(nodeId string, url string, origin string) error {
+	addr := loopAddr(url)
+	_loopLock.RLock()
+	target, exists := _loopListeners[addr]
+	_loopLock.RUnlock()
+	if !exists {
+		return errutil.New("LOOP设备无法连接到目标地址:" + addr)
+	}
+	local, remote := newLoopPipe(addr)
+	if err := target.accept(remote, origin); err != nil {
+		local.Close()
+		return err
+	}
+	worker := process.SpawnS()
+	l.OnConn(local, worker, nodeId, url)
+	l.h_loopSocket(local, worker)
+	return nil
+}
+
+func (l *LoopNetWorker) accept(conn *loopConn, origin string) error {
+	nodeId, err := l.CheckOrigin(origin)
+	if err != nil {
+		return err
+	}
+	worker := process.SpawnS()
+	l.OnConn(conn, worker, nodeId, LOCAL)
+	l.h_loopSocket(conn, worker)
+	return nil
+}
+
+func (l *LoopNetWorker) h_loopSocket(conn *loopConn, worker process.Service) {
+	worker.Start(func() {
+		msg, err := conn.recv()
+		if err != nil {
+			if worker.Running() { // 主动关闭的链接无需再次上报
+				l.OnError(conn, err)
+			}
+			worker.Terminate()
+			return
+		}
+		if nodeId, exists := l.manager.GetNodeIdByConn(conn); exists {
+			l.OnMsg(conn, nodeId, msg)
+		}
+	}, nil)
+}
+
+func (l *LoopNetWorker) Send(conn net.Conn, msg []byte) error {
+	_, err := conn.Write(msg)
+	return err
+}
+
+func (l *LoopNetWorker) Shutdown() error {
+	l.Lock()
+	defer l.Unlock()
+	if l.done == nil {
+		return nil
+	}
+	_loopLock.Lock()
+	if _loopListeners[l.addr] == l {
+		delete(_loopListeners, l.addr)
+	}
+	_loopLock.Unlock()
+	close(l.done)
+	l.done = nil
+	return nil
+}
+
+type loopAddress string
+
+func (a loopAddress) Network() string {
+	return LOOP
+}
+
+func (a loopAddress) String() string {
+	return string(a)
+}
+
+// 基于消息的内存管道，每次Write对应对端的一次Read，缓冲区无上限
+type loopConn struct {
+	addr   loopAddress
+	peer   *loopConn
+	inbox  [][]byte
+	closed bool
+	cond   *sync.Cond
+	sync.Mutex
+}
+
+func newLoopPipe(addr string) (*loopConn, *loopConn) {
+	a := new(loopConn)
+	a.addr = loopAddress(addr)
+	a.cond = sync.NewCond(a)
+	b := new(loopConn)
+	b.addr = loopAddress(addr)
+	b.cond = sync.NewCond(b)
+	a.peer = b
+	b.peer = a
+	return a, b
+}
+
+func (c *loopConn) push(msg []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return io.ErrClosedPipe
+	}
+	c.inbox = append(c.inbox, msg)
+	c.cond.Signal()
+	return nil
+}
+
+func (c *loopConn) recv() ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	for len(c.inbox) <= 0 && !c.closed {
+		c.cond.Wait()
+	}
+	if len(c.inbox) <= 0 {
+		return nil, io.EOF // 与TCP一致，对端关闭时视为异常断开，errutil.EOF()仅用于主动关闭
+	}
+	msg := c.inbox[0]
+	c.inbox[0] = nil
+	c.inbox = c.inbox[1:]
+	return msg, nil
+}
+
+func (c *loopConn) shut() {
+	c.Lock()
+	defer c.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+}
+
+func (c *loopConn) Read(b []byte) (int, error) {
+	msg, err := c.recv()
+	if err != nil {
+		return 0, err
+	}
+	if len(msg) > len(b) {
+		return 0, io.ErrShortBuffer
+	}
+	return copy(b, msg), nil
+}
+
+func (c *loopConn) Write(b []byte) (int, error) {
+	msg := make([]byte, len(b))
+	copy(msg, b)
+	if err := c.peer.push(msg); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *loopConn) Close() error {
+	c.shut()
+	c.peer.shut()
+	return nil
+}
+
+func (c *loopConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *loopConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *loopConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *loopConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c *loopConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -4,16 +4,22 @@ import (
 	"net"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/netutil"
 )
 
 const LOCAL string = "local://"
 
 const (
-	TCP string = "tcp"
-	UDP string = "udp"
-	WS  string = "ws"
+	TCP  string = "tcp"
+	UDP  string = "udp"
+	WS   string = "ws"
+	LOOP string = "loop"
+	TLS  string = "tls" // 基于TLS的TCP
+	WSS  string = "wss"
+	UNIX string = "unix"
 )
 
 type NetEventListener struct {
@@ -29,37 +35,91 @@ type INetWorker interface {
 	Connect(nodeId string, url string, origin string) error
 	Send(conn net.Conn, msg []byte) error
 	Close(nodeId string, conn net.Conn, err error) error
+	Shutdown() error
+}
+
+// 可告知监听状态的网络设备，嵌入NetWorkerBase即可自动实现
+// 节点据此在Listen返回前确认地址已绑定
+type IListeningNetWorker interface {
+	Listening() <-chan struct{}
+}
+
+// 需要归属于指定链接管理器的网络设备，嵌入NetWorkerBase即可自动实现
+type IManagedNetWorker interface {
+	Bind(manager *ConnectManager)
+}
+
+var _netWorkers map[string]func() INetWorker
+var _netWorkersLock sync.RWMutex
+
+// 注册网络设备，proto为url中的协议头，同名协议将被覆盖
+func RegisterNetWorker(proto string, factory func() INetWorker) {
+	_netWorkersLock.Lock()
+	defer _netWorkersLock.Unlock()
+	_netWorkers[proto] = factory
 }
 
 func CreateNetWorker(proto string) (INetWorker, error) {
-	switch proto {
-	case WS:
-		return NewWSNetWorker(), nil
-	case UDP:
-		return NewKcpNetWorker(), nil
-	case TCP:
-		return NewTcpNetWorker(), nil
-	default:
+	return CreateNetWorkerWith(proto, _connectManager)
+}
+
+// 创建归属于指定链接管理器的网络设备，网络事件将通知给该管理器绑定的监听者
+func CreateNetWorkerWith(proto string, manager *ConnectManager) (INetWorker, error) {
+	_netWorkersLock.RLock()
+	factory, exists := _netWorkers[proto]
+	_netWorkersLock.RUnlock()
+	if !exists {
 		return nil, errutil.New("不支持的协议类型:" + proto)
 	}
+	netWorker := factory()
+	if managed, ok := netWorker.(IManagedNetWorker); ok {
+		managed.Bind(manager)
+	}
+	return netWorker, nil
 }
 
 var _connectManager *ConnectManager
-var _eventListener *NetEventListener
 
 func init() {
 	_connectManager = NewConnectManager()
-	// go _connectManager.PingPong()
+	_netWorkers = make(map[string]func() INetWorker)
+	RegisterNetWorker(WS, func() INetWorker { return newWSNetWorker(WS) })
+	RegisterNetWorker(WSS, func() INetWorker { return newWSNetWorker(WSS) })
+	RegisterNetWorker(UDP, func() INetWorker { return NewKcpNetWorker() })
+	RegisterNetWorker(TCP, func() INetWorker { return newTcpNetWorker(TCP) })
+	RegisterNetWorker(TLS, func() INetWorker { return newTcpNetWorker(TLS) })
+	RegisterNetWorker(UNIX, func() INetWorker { return NewUnixNetWorker() })
+	RegisterNetWorker(LOOP, func() INetWorker { return NewLoopNetWorker() })
 }
 
 func BindEventListener(eventListener *NetEventListener) {
-	_eventListener = eventListener
+	_connectManager.BindEventListener(eventListener)
 }
 
 func ConnectManagerIns() *ConnectManager {
 	return _connectManager
 }
 
+// 去除url的协议头及子路径，返回地址部分
+func hostOf(url string) string {
+	if infos := strings.SplitN(url, "://", 2); len(infos) == 2 {
+		url = infos[1]
+	}
+	return strings.Split(url, "/")[0]
+}
+
+// 校验终端地址格式
+func CheckUrl(url string) error {
+	if strings.HasPrefix(url, UNIX+"://") {
+		if unixPath(url) == "" {
+			return errutil.New("非法的unix地址:" + url)
+		}
+		return nil
+	}
+	_, _, _, err := netutil.ParseUrlInfo(url)
+	return err
+}
+
 func CombineOriginInfo(nodeId string, url string, sig string) string {
 	return url + "?node=" + nodeId + "&sig=" + sig
 }
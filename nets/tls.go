@@ -0,0 +1,70 @@
+package nets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 依据配置生成服务端及客户端的TLS配置，未配置证书时服务端配置为nil
+// isPub为true时允许未携带证书的外部链接接入
+func NewTLSConfigs(conf ctx.TLSConf, isPub bool) (*tls.Config, *tls.Config, error) {
+	var server *tls.Config = nil
+	client := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: conf.Insecure,
+	}
+	if conf.Cert != "" || conf.Key != "" {
+		cert, err := tls.LoadX509KeyPair(conf.Cert, conf.Key)
+		if err != nil {
+			return nil, nil, errutil.Extend("加载TLS证书发生错误", err)
+		}
+		server = &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		}
+		client.Certificates = []tls.Certificate{cert}
+	}
+	if conf.ClientCA != "" {
+		if server == nil {
+			return nil, nil, errutil.New("启用双向认证时必须配置节点证书!")
+		}
+		pool, err := loadCertPool(conf.ClientCA)
+		if err != nil {
+			return nil, nil, err
+		}
+		server.ClientCAs = pool
+		if isPub {
+			server.ClientAuth = tls.VerifyClientCertIfGiven
+		} else {
+			server.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	rootCA := conf.RootCA
+	if rootCA == "" {
+		rootCA = conf.ClientCA
+	}
+	if rootCA != "" {
+		pool, err := loadCertPool(rootCA)
+		if err != nil {
+			return nil, nil, err
+		}
+		client.RootCAs = pool
+	}
+	return server, client, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	datas, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errutil.Extend("读取CA证书发生错误:"+file, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(datas) {
+		return nil, errutil.New("CA证书格式非法:" + file)
+	}
+	return pool, nil
+}
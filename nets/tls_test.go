@@ -0,0 +1,210 @@
+package nets
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/netutil"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+var _serial int64
+
+// 签发证书并写入dir，返回证书及私钥文件；parent为nil时生成自签名的CA
+func issue(t *testing.T, dir string, name string, parent *testCA) (*testCA, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_serial++
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(_serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	writePem(t, certFile, "CERTIFICATE", der)
+	writePem(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return &testCA{cert: cert, key: key, file: certFile}, certFile, keyFile
+}
+
+func writePem(t *testing.T, file string, typ string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type tlsFiles struct {
+	ca     string
+	server ctx.TLSConf // 要求对端携带ca签发的证书
+	client ctx.TLSConf // ca签发的证书
+	rogue  ctx.TLSConf // 其他CA签发的证书
+	bare   ctx.TLSConf // 不携带证书
+}
+
+func newTLSFiles(t *testing.T) *tlsFiles {
+	dir := t.TempDir()
+	ca, _, _ := issue(t, dir, "ca", nil)
+	other, _, _ := issue(t, dir, "other", nil)
+	f := &tlsFiles{ca: ca.file}
+	_, cert, key := issue(t, dir, "server", ca)
+	f.server = ctx.TLSConf{Cert: cert, Key: key, ClientCA: ca.file}
+	_, cert, key = issue(t, dir, "client", ca)
+	f.client = ctx.TLSConf{Cert: cert, Key: key, RootCA: ca.file}
+	_, cert, key = issue(t, dir, "rogue", other)
+	f.rogue = ctx.TLSConf{Cert: cert, Key: key, RootCA: ca.file}
+	f.bare = ctx.TLSConf{RootCA: ca.file}
+	return f
+}
+
+func tlsUrl(t *testing.T, proto string) string {
+	t.Helper()
+	port, err := netutil.GetAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proto == WSS {
+		return fmt.Sprintf("wss://127.0.0.1:%d/ws", port)
+	}
+	return fmt.Sprintf("tls://127.0.0.1:%d", port)
+}
+
+func newTLSEndpoint(t *testing.T, proto string, peer string, conf ctx.TLSConf, isPub bool) *endpoint {
+	t.Helper()
+	e := newEndpoint(t, proto, peer)
+	if err := e.manager.SetTLS(conf, isPub); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestTLSRoundTrip(t *testing.T) {
+	files := newTLSFiles(t)
+	for _, proto := range []string{TLS, WSS} {
+		t.Run(proto, func(t *testing.T) {
+			url := tlsUrl(t, proto)
+			server := newTLSEndpoint(t, proto, "client", files.server, false)
+			client := newTLSEndpoint(t, proto, "server", files.client, false)
+			server.listen(t, url)
+			if err := client.worker.Connect("server", url, "http://127.0.0.1/"); err != nil {
+				t.Fatal(err)
+			}
+			client.waitLinked(t)
+			server.waitLinked(t)
+			defer client.manager.CloseAll(errutil.EOF())
+
+			if err := client.conn(t, "server").Send([]byte("ping")); err != nil {
+				t.Fatal(err)
+			}
+			if msg := server.recv(t); string(msg) != "ping" {
+				t.Fatalf("服务端收到的消息不符: %q", msg)
+			}
+			if err := server.conn(t, "client").Send([]byte("pong")); err != nil {
+				t.Fatal(err)
+			}
+			if msg := client.recv(t); string(msg) != "pong" {
+				t.Fatalf("客户端收到的消息不符: %q", msg)
+			}
+		})
+	}
+}
+
+// 启用双向认证后，未携带证书或证书并非由ClientCA签发的链接均被拒绝
+func TestTLSMutualReject(t *testing.T) {
+	files := newTLSFiles(t)
+	for _, proto := range []string{TLS, WSS} {
+		for name, conf := range map[string]ctx.TLSConf{"rogue": files.rogue, "bare": files.bare} {
+			t.Run(proto+"/"+name, func(t *testing.T) {
+				url := tlsUrl(t, proto)
+				server := newTLSEndpoint(t, proto, "client", files.server, false)
+				client := newTLSEndpoint(t, proto, "server", conf, false)
+				server.listen(t, url)
+				if err := client.worker.Connect("server", url, "http://127.0.0.1/"); err == nil {
+					t.Fatal("双向认证失败时链接应被拒绝")
+				}
+				select {
+				case nodeId := <-server.linked:
+					t.Fatalf("服务端不应登记该链接: %s", nodeId)
+				case <-time.After(time.Millisecond * 100):
+				}
+			})
+		}
+	}
+}
+
+// 迟迟不发起握手的链接不影响其他链接接入，并在握手超时后被关闭
+func TestTLSHandshakeDeadline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("需等待握手超时")
+	}
+	files := newTLSFiles(t)
+	url := tlsUrl(t, TLS)
+	server := newTLSEndpoint(t, TLS, "client", files.server, false)
+	server.listen(t, url)
+
+	silent, err := net.Dial("tcp", hostOf(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+
+	client := newTLSEndpoint(t, TLS, "server", files.client, false)
+	if err := client.worker.Connect("server", url, "http://127.0.0.1/"); err != nil {
+		t.Fatal(err)
+	}
+	server.waitLinked(t)
+	client.manager.CloseAll(errutil.EOF())
+
+	silent.SetReadDeadline(time.Now().Add(HANDSHAKE_TIMEOUT + time.Second*2))
+	if _, err := silent.Read(make([]byte, 1)); err == nil || isTimeout(err) {
+		t.Fatalf("握手超时后链接应被服务端关闭: %v", err)
+	}
+}
+
+func isTimeout(err error) bool {
+	nerr, ok := err.(net.Error)
+	return ok && nerr.Timeout()
+}
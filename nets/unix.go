@@ -0,0 +1,49 @@
+package nets
+
+import (
+	"net"
+	"os"
+	"strings"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 基于unix domain socket的本机通讯设备，沿用TCP的分帧及握手流程
+// 地址格式形如 unix:///tmp/silvernode/room.sock
+type UnixNetWorker struct {
+	*TcpNetWorker
+}
+
+func NewUnixNetWorker() *UnixNetWorker {
+	u := new(UnixNetWorker)
+	u.TcpNetWorker = newTcpNetWorker(UNIX)
+	return u
+}
+
+func unixPath(url string) string {
+	return strings.TrimPrefix(url, UNIX+"://")
+}
+
+func (u *UnixNetWorker) Listen(url string) error {
+	path := unixPath(url)
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return errutil.New("UNIX设备地址已被占用:" + path)
+		}
+		os.Remove(path) // 清理异常退出时残留的套接字文件
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return u.serve(listener)
+}
+
+func (u *UnixNetWorker) Connect(nodeId string, url string, origin string) error {
+	conn, err := net.DialTimeout("unix", unixPath(url), HANDSHAKE_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	return u.connect(conn, nodeId, url, origin)
+}
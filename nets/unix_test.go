@@ -0,0 +1,69 @@
+package nets
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+func unixUrl(t *testing.T) string {
+	return UNIX + "://" + filepath.Join(t.TempDir(), "node.sock")
+}
+
+func TestUnixRoundTrip(t *testing.T) {
+	url := unixUrl(t)
+	server := newEndpoint(t, UNIX, "client")
+	client := newEndpoint(t, UNIX, "server")
+	server.listen(t, url)
+	if err := client.worker.Connect("server", url, "client"); err != nil {
+		t.Fatal(err)
+	}
+	client.waitLinked(t)
+	server.waitLinked(t)
+	defer client.manager.CloseAll(errutil.EOF())
+
+	if err := client.conn(t, "server").Send([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := server.recv(t); string(msg) != "ping" {
+		t.Fatalf("服务端收到的消息不符: %q", msg)
+	}
+	if err := server.conn(t, "client").Send([]byte("pong")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := client.recv(t); string(msg) != "pong" {
+		t.Fatalf("客户端收到的消息不符: %q", msg)
+	}
+}
+
+// 异常退出时残留的套接字文件在监听前被清理，仍在使用的地址则拒绝监听
+func TestUnixStaleSocket(t *testing.T) {
+	url := unixUrl(t)
+	stale, err := net.Listen("unix", unixPath(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if info, err := os.Stat(unixPath(url)); err != nil || info.Mode()&os.ModeSocket == 0 {
+		t.Fatalf("应残留套接字文件: %v", err)
+	}
+
+	server := newEndpoint(t, UNIX, "client")
+	server.listen(t, url)
+
+	other := newEndpoint(t, UNIX, "client")
+	if err := other.worker.Listen(url); err == nil || !strings.Contains(err.Error(), "已被占用") {
+		t.Fatalf("地址仍在使用时应拒绝监听: %v", err)
+	}
+	client := newEndpoint(t, UNIX, "server")
+	if err := client.worker.Connect("server", url, "client"); err != nil {
+		t.Fatalf("拒绝监听时不应清理仍在使用的套接字文件: %v", err)
+	}
+	server.waitLinked(t)
+	client.manager.CloseAll(errutil.EOF())
+}
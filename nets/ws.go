@@ -1,9 +1,14 @@
 package nets
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/silvernodes/silvernode-go/process"
 	"github.com/silvernodes/silvernode-go/utils/errutil"
@@ -11,33 +16,73 @@ import (
 )
 
 type WSNetWorker struct {
+	NetWorkerBase
+	wlocks sync.Map // ping帧与消息的写入互斥
 }
 
 func NewWSNetWorker() *WSNetWorker {
+	return newWSNetWorker(WS)
+}
+
+func newWSNetWorker(proto string) *WSNetWorker {
 	w := new(WSNetWorker)
+	w.Init(w, proto)
+	w.release = func(conn net.Conn) {
+		w.wlocks.Delete(conn)
+	}
 	return w
 }
 
 func (w *WSNetWorker) Listen(url string) error {
-	url = strings.Trim(url, "ws://") // trim the ws header
-	infos := strings.Split(url, "/") // parse the sub path
+	url = strings.SplitN(url, "://", 2)[1] // trim the ws header
+	infos := strings.Split(url, "/")       // parse the sub path
 	wsMux := http.NewServeMux()
-	wsMux.Handle("/"+infos[1], websocket.Handler(w.h_webSocket))
-	err := http.ListenAndServe(infos[0], wsMux)
+	wsMux.Handle("/"+infos[1], websocket.Server{Handler: w.h_webSocket, Handshake: checkOrigin})
+	server := &http.Server{Addr: infos[0], Handler: wsMux, ReadHeaderTimeout: HANDSHAKE_TIMEOUT} // 同时限制TLS握手的时长
+	if w.proto == WSS {
+		conf, err := w.manager.ServerTLS()
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = conf
+	}
+	listener, err := net.Listen("tcp", infos[0])
+	if err != nil {
+		return err
+	}
+	w.SetListener(server) // 关闭server时一并关闭listener
+	if w.proto == WSS {
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		err = server.Serve(listener)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// 浏览器之外的客户端(如移动端、Node.js及.NET)通常不携带Origin，以空值代替而非拒绝链接，随后按访客处理
+func checkOrigin(config *websocket.Config, req *http.Request) (err error) {
+	config.Origin, err = websocket.Origin(config, req)
+	if err == nil && config.Origin == nil {
+		config.Origin = new(url.URL)
+	}
 	return err
 }
 
 func (w *WSNetWorker) h_webSocket(conn *websocket.Conn) {
 	remote := conn.RemoteAddr().String()
-	nodeId, err := _eventListener.OnCheckNode(remote) // let the gonode to check if the url is legal
+	nodeId, err := w.CheckOrigin(remote)
 	if err == nil {
 		worker := process.SpawnS()
-		w.onConn(conn, worker, nodeId, LOCAL)
-		var msg []byte
+		w.OnConn(conn, worker, nodeId, LOCAL)
 		worker.Start(func() {
-			err := websocket.Message.Receive(conn, &msg)
+			msg, err := w.receive(conn)
 			if err != nil {
-				w.onError(conn, err)
+				if worker.Running() { // 主动关闭的链接无需再次上报
+					w.OnError(conn, err)
+				}
 				worker.Terminate()
 				return
 			}
@@ -45,20 +90,29 @@ func (w *WSNetWorker) h_webSocket(conn *websocket.Conn) {
 		}, nil)
 		worker.Sync()
 	} else {
-		w.onError(conn, err)
+		w.OnError(conn, err)
 	}
 }
 
 func (w *WSNetWorker) Connect(nodeId string, url string, origin string) error {
-	conn, err := websocket.Dial(url, "tcp", origin)
+	config, err := websocket.NewConfig(url, origin)
+	if err != nil {
+		return err
+	}
+	if w.proto == WSS {
+		config.TlsConfig = w.manager.ClientTLS()
+	}
+	config.Dialer = &net.Dialer{Timeout: HANDSHAKE_TIMEOUT}
+	conn, err := websocket.DialConfig(config)
 	if err == nil {
 		worker := process.SpawnS()
-		w.onConn(conn, worker, nodeId, url)
-		var msg []byte
+		w.OnConn(conn, worker, nodeId, url)
 		worker.Start(func() {
-			err := websocket.Message.Receive(conn, &msg)
+			msg, err := w.receive(conn)
 			if err != nil {
-				w.onError(conn, err)
+				if worker.Running() { // 主动关闭的链接无需再次上报
+					w.OnError(conn, err)
+				}
 				worker.Terminate()
 				return
 			}
@@ -68,69 +122,86 @@ func (w *WSNetWorker) Connect(nodeId string, url string, origin string) error {
 	return err
 }
 
+// 读取一条完整消息，期间处理心跳回应等控制帧
+func (w *WSNetWorker) receive(conn *websocket.Conn) ([]byte, error) {
+	for {
+		frame, err := conn.NewFrameReader()
+		if err != nil {
+			return nil, err
+		}
+		if frame.PayloadType() == websocket.PongFrame {
+			io.Copy(ioutil.Discard, frame)
+			if nodeId, exists := w.manager.GetNodeIdByConn(conn); exists {
+				w.manager.OnPong(nodeId)
+			}
+			continue
+		}
+		frame, err = conn.HandleFrame(frame)
+		if err != nil {
+			return nil, err
+		}
+		if frame == nil { // 其余控制帧已由底层处理
+			continue
+		}
+		if frame.Len() > w.manager.MaxFrameSize() {
+			return nil, errutil.New(fmt.Sprintf("数据帧长度超出上限:%d>%d", frame.Len(), w.manager.MaxFrameSize()))
+		}
+		return ioutil.ReadAll(frame)
+	}
+}
+
+// 发送协议层的ping帧，对端将自动回复pong帧
+// 经由Conn.Write写入，与底层自动回复pong帧共用其内部的写锁，避免两者交错写入
+func (w *WSNetWorker) Ping(conn net.Conn) error {
+	wsconn, ok := conn.(*websocket.Conn)
+	if !ok {
+		return errutil.New("WS设备无法识别的链接类型")
+	}
+	lock := w.writeLock(conn)
+	lock.Lock()
+	defer lock.Unlock()
+	payloadType := wsconn.PayloadType // 仅Conn.Write读取，由writeLock保护
+	wsconn.PayloadType = websocket.PingFrame
+	defer func() {
+		wsconn.PayloadType = payloadType
+	}()
+	_, err := wsconn.Write([]byte("ping"))
+	return err
+}
+
 func (w *WSNetWorker) Send(conn net.Conn, msg []byte) error {
+	if err := checkFrameSize(msg, w.manager.MaxFrameSize()); err != nil {
+		return err
+	}
 	defer func() {
 		msg = nil // dispose the send buffer
 	}()
+	lock := w.writeLock(conn)
+	lock.Lock()
+	defer lock.Unlock()
 	err := websocket.Message.Send(conn.(*websocket.Conn), msg)
 	return err
 }
 
+func (w *WSNetWorker) writeLock(conn net.Conn) *sync.Mutex {
+	lock, _ := w.wlocks.LoadOrStore(conn, new(sync.Mutex))
+	return lock.(*sync.Mutex)
+}
+
 func (w *WSNetWorker) SendText(nodeId string, str string) error {
-	info, exist := _connectManager.GetConnectInfo(nodeId)
+	info, exist := w.manager.GetConnectInfo(nodeId)
 	if !exist {
 		return errutil.New("未能找到对应的链路信息:" + nodeId)
 	}
+	lock := w.writeLock(info.conn)
+	lock.Lock()
+	defer lock.Unlock()
 	err := websocket.Message.Send(info.conn.(*websocket.Conn), str)
 	return err
 }
 
-func (w *WSNetWorker) onConn(conn *websocket.Conn, worker process.Service, nodeId string, url string) {
-	// record the set from nodeId to conn
-	_, err := _connectManager.AddConnectInfo(nodeId, url, WS, conn, worker, w)
-	if err != nil {
-		w.onError(conn, err)
-	} else {
-		_eventListener.OnConnect(nodeId)
-	}
-}
-
 func (w *WSNetWorker) onMsg(conn *websocket.Conn, msg []byte) {
-	nodeId, exists := _connectManager.GetNodeIdByConn(conn)
-	if exists {
-		if msg[0] == 35 && len(msg) == 5 {
-			strmsg := string(msg)
-			if strmsg == "#ping" {
-				go w.Send(conn, []byte("#pong"))
-				return
-			}
-		}
-		_eventListener.OnMessage(nodeId, msg)
+	if nodeId, exists := w.manager.GetNodeIdByConn(conn); exists {
+		w.OnMsg(conn, nodeId, msg)
 	}
 }
-
-func (w *WSNetWorker) onClose(nodeId string, conn *websocket.Conn, reason error) {
-	_eventListener.OnClose(nodeId, reason)
-	_connectManager.RemoveConnectInfo(nodeId, conn) // remove the closed conn from local record
-	conn.Close()
-}
-
-func (w *WSNetWorker) onError(conn *websocket.Conn, err error) {
-	if conn != nil {
-		nodeId, exists := _connectManager.GetNodeIdByConn(conn)
-		if exists {
-			w.onClose(nodeId, conn, err) // close the conn with errors
-		} else {
-			conn.Close()
-			_eventListener.OnError(err)
-		}
-	} else {
-		_eventListener.OnError(err)
-	}
-}
-
-func (w *WSNetWorker) Close(nodeId string, conn net.Conn, err error) error {
-	_eventListener.OnClose(nodeId, err)
-	_connectManager.RemoveConnectInfo(nodeId, conn) // remove the closed conn from local record
-	return conn.Close()
-}
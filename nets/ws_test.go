@@ -0,0 +1,91 @@
+package nets
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/netutil"
+)
+
+// 双方同时收发消息并互发ping，底层自动回复的pong不得与ping交错写入
+func TestWSPingWithPong(t *testing.T) {
+	port, err := netutil.GetAvailablePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := fmt.Sprintf("ws://127.0.0.1:%d/echo", port)
+	server := newEndpoint(t, WS, "client")
+	client := newEndpoint(t, WS, "server")
+	server.listen(t, url)
+	if err := client.worker.Connect("server", url, "http://127.0.0.1/"); err != nil {
+		t.Fatal(err)
+	}
+	<-client.linked
+	<-server.linked
+	defer client.manager.CloseAll(errutil.EOF())
+
+	const count = 2000
+	var wg sync.WaitGroup
+	for _, pair := range []struct {
+		from *endpoint
+		to   string
+	}{{client, "server"}, {server, "client"}} {
+		info := pair.from.conn(t, pair.to)
+		pinger := info.NetWorker().(Pinger)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				if err := info.Send([]byte(fmt.Sprintf("msg-%d", i))); err != nil {
+					pair.from.errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				info.Ping()
+				if err := pinger.Ping(info.Conn()); err != nil {
+					pair.from.errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, e := range []*endpoint{server, client} {
+		for i := 0; i < count; i++ {
+			select {
+			case msg := <-e.msgs:
+				if want := fmt.Sprintf("msg-%d", i); string(msg) != want {
+					t.Fatalf("消息错乱: got %q, want %q", msg, want)
+				}
+			case err := <-e.errs:
+				t.Fatal(err)
+			case <-time.After(time.Second * 10):
+				t.Fatalf("等待第%d条消息超时", i)
+			}
+		}
+	}
+	for _, pair := range []struct {
+		from *endpoint
+		to   string
+	}{{client, "server"}, {server, "client"}} {
+		info := pair.from.conn(t, pair.to)
+		eventually(t, func() bool {
+			return info.RTT() > 0
+		}, "未收到"+pair.to+"的pong帧")
+	}
+	select {
+	case err := <-client.errs:
+		t.Fatal(err)
+	case err := <-server.errs:
+		t.Fatal(err)
+	default:
+	}
+}
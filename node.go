@@ -0,0 +1,728 @@
+package silvernode
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/silvernodes/silvernode-go/board"
+	"github.com/silvernodes/silvernode-go/cluster"
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/log"
+
+	"github.com/silvernodes/silvernode-go/nets"
+	"github.com/silvernodes/silvernode-go/plugins"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/fileutil"
+	"github.com/silvernodes/silvernode-go/utils/netutil"
+	"github.com/silvernodes/silvernode-go/utils/snowflake"
+)
+
+// 节点实例，独立持有自身的配置、流水线、注册中心、链接管理器等资源
+// 同一进程内可同时运行多个节点实例
+type Node struct {
+	info       atomic.Pointer[ctx.NodeInfo] // Serve中载入完毕后整体发布，各协程并发读取
+	reg        cluster.IRegistry
+	log        *log.Logger
+	conf       *ctx.AppConf
+	setup      *SetupParam
+	pipe       *Pipeline
+	conns      *nets.ConnectManager
+	cluster    *cluster.Cluster
+	netWorkers map[string]nets.INetWorker
+	relinks    *relinkSet
+	others     map[string]*ctx.NodeInfo // 注册中心中发现的其他节点信息
+	othersLock sync.RWMutex
+	server     *http.Server
+	hooks      []func(context.Context) error
+	inited     atomic.Bool // 链接的接收协程并发读取
+	closing    bool
+	isDefault  bool
+	ready      chan struct{}
+	done       chan struct{}
+	sync.RWMutex
+}
+
+func NewNode(param *SetupParam) *Node {
+	n := newNode(ctx.NewAppConf(), nets.NewConnectManager(), false)
+	if param != nil {
+		n.Setup(param)
+	}
+	return n
+}
+
+func newNode(conf *ctx.AppConf, conns *nets.ConnectManager, isDefault bool) *Node {
+	n := new(Node)
+	n.info.Store(ctx.NewNodeInfo())
+	n.log = log.NewLogger("silvernode", log.DEBUG, nil)
+	n.conf = conf
+	n.conns = conns
+	n.isDefault = isDefault
+	n.netWorkers = make(map[string]nets.INetWorker)
+	n.relinks = newRelinkSet()
+	n.others = make(map[string]*ctx.NodeInfo)
+	n.hooks = make([]func(context.Context) error, 0, 2)
+	n.ready = make(chan struct{})
+	n.done = make(chan struct{})
+
+	n.setup = new(SetupParam)
+	n.setup.AppConf = fileutil.CurrentDir() + "app.yml"
+	n.setup.ShutdownTimeout = 15000
+
+	n.pipe = new(Pipeline)
+	n.pipe.OnConnect = func(nodeId string) {
+
+	}
+	n.pipe.OnInBound = func(nodeId string, data []byte) (interface{}, error) {
+		return data, nil
+	}
+	n.pipe.OnMessage = func(nodeId string, msg interface{}) error {
+		return n.Send(nodeId, msg) // echo server
+	}
+	n.pipe.OnOutBound = func(nodeId string, msg interface{}) ([]byte, error) {
+		return msg.([]byte), nil
+	}
+	n.pipe.OnClose = func(nodeId string, err error) {
+
+	}
+	n.pipe.OnError = func(err error) {
+		n.log.Log(log.ERROR, err)
+	}
+	n.pipe.OnReconnect = func(nodeId string, attempts int, err error) {
+
+	}
+	if n.isDefault {
+		errutil.CustomErrFunc(n.pipe.OnError)
+	}
+	return n
+}
+
+func (n *Node) Setup(param *SetupParam) {
+	if param.AppConf != "" {
+		n.setup.AppConf = param.AppConf
+	}
+	if param.ClusterNode != "" {
+		n.setup.ClusterNode = param.ClusterNode
+	}
+	if param.SpecifiedId != "" {
+		n.setup.SpecifiedId = param.SpecifiedId
+	}
+	if param.ShutdownTimeout > 0 {
+		n.setup.ShutdownTimeout = param.ShutdownTimeout
+	}
+	if param.NodeInfo != nil {
+		n.setup.NodeInfo = param.NodeInfo
+	}
+	if param.Registry != nil {
+		n.setup.Registry = param.Registry
+	}
+}
+
+func (n *Node) BindPipeline(pipe *Pipeline) {
+	if pipe != nil {
+		if pipe.Init != nil {
+			n.pipe.Init = pipe.Init
+		}
+		if pipe.Start != nil {
+			n.pipe.Start = pipe.Start
+		}
+		if pipe.OnConnect != nil {
+			n.pipe.OnConnect = pipe.OnConnect
+		}
+		if pipe.OnInBound != nil {
+			n.pipe.OnInBound = pipe.OnInBound
+		}
+		if pipe.OnMessage != nil {
+			n.pipe.OnMessage = pipe.OnMessage
+		}
+		if pipe.OnOutBound != nil {
+			n.pipe.OnOutBound = pipe.OnOutBound
+		}
+		if pipe.OnClose != nil {
+			n.pipe.OnClose = pipe.OnClose
+		}
+		if pipe.OnError != nil {
+			n.pipe.OnError = pipe.OnError
+			if n.isDefault {
+				errutil.CustomErrFunc(n.pipe.OnError)
+			}
+		}
+		if pipe.OnReconnect != nil {
+			n.pipe.OnReconnect = pipe.OnReconnect
+		}
+	}
+}
+
+func (n *Node) Serve() error {
+	runtime.GOMAXPROCS(runtime.NumCPU())
+
+	if n.setup.NodeInfo == nil || fileutil.Exists(n.setup.AppConf) {
+		text, err := fileutil.LoadFile(n.setup.AppConf)
+		if err != nil {
+			return errutil.Extend("读取配置文件发生错误:"+n.setup.AppConf, err)
+		}
+		if err := n.conf.LoadAppYaml(text); err != nil {
+			return errutil.Extend("解析配置文件发生错误", err)
+		}
+	}
+
+	if n.setup.Registry != nil {
+		n.reg = n.setup.Registry
+	} else if n.conf.CheckConfExists("cluster") {
+		clusterType := ""
+		if err := n.conf.GetConfDatas("cluster.type", &clusterType); err != nil {
+			return errutil.Extend("集群注册中心类型缺失", err)
+		}
+		reg, err := cluster.CreateRegistryWithConf(clusterType, n.conf)
+		if err != nil {
+			return err
+		}
+		n.reg = reg
+	}
+
+	if n.isDefault { // 插件为进程级资源，仅由默认节点装载
+		if err := plugins.InstallPlugins(n.reg); err != nil {
+			return errutil.Extend("初始化插件系统发生错误", err)
+		}
+	}
+
+	info := ctx.NewNodeInfo()
+	if n.setup.NodeInfo != nil { // 指定配置
+		info = n.setup.NodeInfo
+	} else if n.conf.CheckConfExists("node") { // 本地配置
+		if err := n.conf.GetConfDatas("node", info); err != nil {
+			return errutil.Extend("加载节点配置信息出错", err)
+		}
+	} else if n.reg != nil && n.setup.ClusterNode != "" { // 云端配置
+		if err := n.reg.GetConfig(n.setup.ClusterNode, info); err != nil {
+			return errutil.Extend("从注册中心获取节点配置信息出错", err)
+		}
+	} else {
+		return errutil.New("无法获取正确的节点配置信息！")
+	}
+
+	if info.MainEndPoint() == "" {
+		return errutil.New("每个节点至少应包含一个非unix的主EndPoint！")
+	}
+	if info.MainPort == 0 {
+		port, err := netutil.GetAvailablePort()
+		if err != nil {
+			return errutil.Extend("本地无法获得可用的随机端口", err)
+		}
+		info.MainPort = port
+	}
+	if n.isDefault {
+		ctx.NodeSignature(info)
+	} else {
+		ctx.SignNode(info)
+	}
+	if n.setup.SpecifiedId != "" {
+		info.NodeId = n.setup.SpecifiedId
+	}
+	_, ip, _, err := netutil.ParseUrlInfo(info.MainEndPoint())
+	if err != nil {
+		return errutil.Extend("解析节点注册信息发生错误", err)
+	}
+	n.info.Store(info)
+
+	mainUrl := fmt.Sprintf("%s:%d", ip, info.MainPort)
+	mainMux := http.NewServeMux()
+	mainMux.HandleFunc("/", board.DashBoard)
+	if info.Metrics {
+		mainMux.Handle("/metrics", promhttp.Handler())
+	}
+	n.server = &http.Server{Addr: mainUrl, Handler: mainMux}
+	go func() {
+		if err := n.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			n.pipe.OnError(errutil.Extend("开启检测监听时发生错误", err))
+		}
+	}()
+	n.log = log.NewLogger(info.NodeId, info.LogLevel, nil)
+
+	n.conns.SetMaxFrameSize(info.MaxFrameSize)
+	if err := n.conns.SetTLS(info.TLS, info.IsPub); err != nil {
+		return n.abortServe(errutil.Extend("加载节点TLS配置出错", err))
+	}
+	n.conns.BindEventListener(&nets.NetEventListener{
+		OnConnect: func(nodeId string) {
+			defer errutil.Catch(n.pipe.OnError)
+			n.log.Log(log.INFO, "新的链接已建立:"+nodeId)
+			n.relinks.wake(nodeId)
+			n.pipe.OnConnect(nodeId)
+		},
+		OnMessage: func(nodeId string, msg []byte) {
+			defer errutil.Catch(n.pipe.OnError)
+			if !n.inited.Load() {
+				n.pipe.OnError(errutil.New("节点尚未初始化完毕!"))
+				return
+			}
+			data, err := n.pipe.OnInBound(nodeId, msg)
+			if err != nil {
+				n.pipe.OnError(err)
+			} else {
+				if err := n.pipe.OnMessage(nodeId, data); err != nil {
+					n.pipe.OnError(err)
+				}
+			}
+		},
+		OnClose: func(nodeId string, err error) {
+			defer errutil.Catch(n.pipe.OnError)
+			n.log.Log(log.ERROR, "链接已关闭:"+nodeId+"|"+err.Error())
+			n.pipe.OnClose(nodeId, err)
+			if err != errutil.EOF() { // 主动关闭的链接无需重连
+				if info, exists := n.conns.GetConnectInfo(nodeId); exists && info.Url() != nets.LOCAL {
+					n.reconnect(nodeId, info.Url())
+				}
+			}
+		},
+		OnError:     n.pipe.OnError,
+		OnCheckNode: n.onCheckNode,
+	})
+
+	if n.pipe.Init != nil {
+		n.pipe.Init()
+	}
+	for _, ep := range info.EndPoints {
+		if err := n.Listen(ep); err != nil {
+			return n.abortServe(err)
+		}
+	}
+	n.conns.StartHeartbeat(info.Heartbeat)
+	if n.reg != nil {
+		n.cluster = cluster.NewCluster(n.reg, &cluster.ClusterParam{
+			SelfInfo:   info,
+			OnScanning: n.onScanning,
+			OnLeaving:  n.onLeaving,
+		})
+		if err := n.cluster.Serve(); err != nil {
+			return n.abortServe(err)
+		}
+	}
+	n.inited.Store(true)
+	n.printInfo()
+	if n.pipe.Start != nil {
+		n.pipe.Start()
+	}
+	close(n.ready)
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+	select {
+	case sig := <-sigs:
+		n.log.Log(log.INFO, "收到系统信号:"+sig.String())
+		c, cancel := context.WithTimeout(context.Background(), time.Millisecond*time.Duration(n.setup.ShutdownTimeout))
+		defer cancel()
+		if err := n.Shutdown(c); err != nil {
+			n.pipe.OnError(err)
+		}
+	case <-n.done:
+	}
+	return nil
+}
+
+// 启动失败时关闭已开启的检测服务、监听及心跳
+func (n *Node) abortServe(err error) error {
+	n.Lock()
+	workers := make([]nets.INetWorker, 0, len(n.netWorkers))
+	for _, netWorker := range n.netWorkers {
+		workers = append(workers, netWorker)
+	}
+	n.Unlock()
+	for _, netWorker := range workers {
+		netWorker.Shutdown()
+	}
+	n.server.Close()
+	n.conns.StopHeartbeat()
+	return err
+}
+
+// 节点初始化完毕(全部EndPoint均已绑定并完成注册)后关闭
+func (n *Node) Ready() <-chan struct{} {
+	return n.ready
+}
+
+// 节点关闭流程执行完毕后关闭
+func (n *Node) Done() <-chan struct{} {
+	return n.done
+}
+
+// 注册节点关闭时需要执行的清理操作，按注册顺序依次执行
+// 执行时节点已从注册中心注销并停止监听，但已建立的链接依旧可用
+func (n *Node) AddShutdownHook(hook func(context.Context) error) {
+	n.Lock()
+	defer n.Unlock()
+	n.hooks = append(n.hooks, hook)
+}
+
+// 优雅关闭节点:注销节点->停止监听->关闭检测服务->执行清理操作->关闭全部链接
+// 当c超时后，剩余步骤依旧会执行，并返回超时错误
+func (n *Node) Shutdown(c context.Context) error {
+	n.Lock()
+	if n.closing {
+		n.Unlock()
+		return errutil.New("节点已处于关闭流程中!")
+	}
+	n.closing = true
+	workers := make([]nets.INetWorker, 0, len(n.netWorkers))
+	for _, netWorker := range n.netWorkers {
+		workers = append(workers, netWorker)
+	}
+	hooks := n.hooks
+	n.Unlock()
+
+	var reterr error = nil
+	report := func(err error) {
+		if reterr == nil {
+			reterr = err
+		}
+		n.pipe.OnError(err)
+	}
+	n.log.Log(log.INFO, "节点开始关闭:"+n.info.Load().NodeId)
+	n.relinks.stop()
+	if n.cluster != nil {
+		if err := n.cluster.Stop(); err != nil {
+			report(errutil.Extend("从注册中心注销节点时发生错误", err))
+		}
+	}
+	for _, netWorker := range workers {
+		if err := netWorker.Shutdown(); err != nil {
+			report(errutil.Extend("停止监听时发生错误", err))
+		}
+	}
+	if n.server != nil {
+		if err := n.server.Shutdown(c); err != nil {
+			report(errutil.Extend("关闭检测服务时发生错误", err))
+		}
+	}
+	for _, hook := range hooks {
+		if err := hook(c); err != nil {
+			report(err)
+		}
+	}
+	n.conns.StopHeartbeat()
+	n.conns.CloseAll(errutil.New("节点已关闭:" + n.info.Load().NodeId))
+	n.inited.Store(false)
+	close(n.done)
+	n.log.Log(log.INFO, "节点已关闭:"+n.info.Load().NodeId)
+	return reterr
+}
+
+// 在后台开启监听，待地址绑定成功后返回，绑定失败时返回对应错误
+// 无法告知监听状态的自定义设备则立即返回，其错误交由OnError处理
+func (n *Node) Listen(url string) error {
+	if err := nets.CheckUrl(url); err != nil {
+		return err
+	}
+	n.Lock()
+	netWorker, err := n.getNetWorker(url)
+	n.Unlock()
+	if err != nil {
+		return err
+	}
+	notifier, ok := netWorker.(nets.IListeningNetWorker)
+	if !ok {
+		go func() {
+			if err := netWorker.Listen(url); err != nil {
+				n.pipe.OnError(err)
+			}
+		}()
+		return nil
+	}
+	listening := notifier.Listening()
+	failed := make(chan error, 1)
+	go func() {
+		err := netWorker.Listen(url)
+		select {
+		case <-listening: // 监听期间的错误
+			if err != nil {
+				n.pipe.OnError(err)
+			}
+		default:
+			failed <- err
+		}
+	}()
+	select {
+	case <-listening:
+		return nil
+	case err := <-failed:
+		if err == nil {
+			return errutil.New("监听未能开启:" + url)
+		}
+		return errutil.Extend("开启监听时发生错误:"+url, err)
+	}
+}
+
+func (n *Node) Connect(nodeId string, url string) (string, error) {
+	info, exists := n.conns.GetConnectInfo(nodeId)
+	if exists {
+		if info.Url() == url {
+			return nodeId, nil
+		} else if strings.HasPrefix(url, nets.UNIX+"://") {
+			nodeId = nodeId + "@" + nets.UNIX
+		} else {
+			proto, _, port, err := netutil.ParseUrlInfo(url)
+			if err != nil {
+				return "", err
+			}
+			nodeId = nodeId + "@" + proto + fmt.Sprint(port)
+		}
+	}
+	netWorker, err := n.getNetWorker(url)
+	if err != nil {
+		return "", err
+	}
+	return nodeId, n.dial(netWorker, nodeId, url)
+}
+
+func (n *Node) dial(netWorker nets.INetWorker, nodeId string, url string) error {
+	self := n.info.Load()
+	originInfo := nets.CombineOriginInfo(self.NodeId, self.MainEndPoint(), self.Sig)
+	return netWorker.Connect(nodeId, url, originInfo)
+}
+
+func (n *Node) getNetWorker(url string) (nets.INetWorker, error) {
+	_, exists := n.netWorkers[url]
+	if !exists {
+		proto := strings.Split(url, "://")[0]
+		netWorker, err := nets.CreateNetWorkerWith(proto, n.conns)
+		if err != nil {
+			return nil, err
+		}
+		n.netWorkers[url] = netWorker
+	}
+	return n.netWorkers[url], nil
+}
+
+func (n *Node) Send(nodeId string, msg interface{}) error {
+	data, err := n.pipe.OnOutBound(nodeId, msg)
+	if err != nil {
+		if errutil.IsEOF(err) {
+			return nil
+		}
+		return errutil.Extend("数据发送失败", err)
+	}
+	if n.relinks.enqueue(nodeId, data) { // 重连期间暂存，链接恢复并补发完毕后才直接发送
+		return nil
+	}
+	connInfo, exists := n.conns.GetConnectInfo(nodeId)
+	if !exists {
+		return errutil.New("尚未建立到对应节点的链接:" + nodeId)
+	}
+	return connInfo.Send(data)
+}
+
+func (n *Node) Close(nodeId string) error {
+	connInfo, exists := n.conns.GetConnectInfo(nodeId)
+	if !exists {
+		return errutil.New("尚未建立到对应节点的链接:" + nodeId)
+	}
+	return connInfo.Close(errutil.EOF())
+}
+
+// 到目标节点链接的心跳往返耗时
+func (n *Node) RTT(nodeId string) (time.Duration, error) {
+	connInfo, exists := n.conns.GetConnectInfo(nodeId)
+	if !exists {
+		return 0, errutil.New("尚未建立到对应节点的链接:" + nodeId)
+	}
+	return connInfo.RTT(), nil
+}
+
+func (n *Node) GetNodeList(name string) []string {
+	return n.conns.GetNodes(name)
+}
+
+// 获取注册中心中发现的节点信息，仅含最近一次扫描或监听到的节点
+func (n *Node) GetNodeInfo(nodeId string) (*ctx.NodeInfo, bool) {
+	n.othersLock.RLock()
+	defer n.othersLock.RUnlock()
+	info, exists := n.others[nodeId]
+	return info, exists
+}
+
+func (n *Node) SetUsrData(k string, v interface{}) {
+	n.info.Load().UsrDatas[k] = v
+}
+
+func (n *Node) GetUsrDatas(nodeId string) (map[string]interface{}, bool) {
+	if n.reg == nil {
+		n.pipe.OnError(errutil.New("尚未配置注册中心，无法获取节点信息:" + nodeId))
+		return nil, false
+	}
+	info, err := n.reg.GetNodeById(nodeId)
+	if err != nil {
+		n.pipe.OnError(err)
+		return nil, false
+	}
+	return info.UsrDatas, true
+}
+
+func (n *Node) GetAppConf(prefix string, ref interface{}) error {
+	return n.conf.GetConfDatas(prefix, ref)
+}
+
+func (n *Node) onScanning(otherInfos []*ctx.NodeInfo, err error) {
+	n.Lock()
+	defer n.Unlock()
+	if n.closing {
+		return
+	}
+	if err == nil && otherInfos != nil {
+		n.othersLock.Lock()
+		for _, otherInfo := range otherInfos {
+			n.others[otherInfo.NodeId] = otherInfo
+		}
+		n.othersLock.Unlock()
+		for _, otherInfo := range otherInfos {
+			_, exists := n.conns.GetConnectInfo(otherInfo.NodeId)
+			if !exists && !n.relinks.exists(otherInfo.NodeId) {
+				if n.isBackEnd(otherInfo.NodeId) {
+					n.log.Log(log.INFO, "发现新节点:"+otherInfo.NodeId)
+					url := n.selectEndPoint(otherInfo)
+					if _, err := n.Connect(otherInfo.NodeId, url); err != nil {
+						if n.reconnect(otherInfo.NodeId, url) { // 对端可能尚未完成监听，交由重连流程处理
+							n.log.Log(log.WARN, "链接节点失败，稍后重试:"+otherInfo.NodeId+"|"+err.Error())
+						} else {
+							n.pipe.OnError(err)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// 后端节点从注册中心下线时，停止重连并关闭到该节点的链接(含附加链接)
+func (n *Node) onLeaving(otherInfos []*ctx.NodeInfo) {
+	defer errutil.Catch(n.pipe.OnError)
+	n.othersLock.Lock()
+	for _, otherInfo := range otherInfos {
+		delete(n.others, otherInfo.NodeId)
+	}
+	n.othersLock.Unlock()
+	for _, otherInfo := range otherInfos {
+		if !n.isBackEnd(otherInfo.NodeId) {
+			continue
+		}
+		n.log.Log(log.INFO, "节点已下线:"+otherInfo.NodeId)
+		n.relinks.cancel(otherInfo.NodeId)
+		for _, nodeId := range n.conns.GetNodes(otherInfo.Name) {
+			if nodeId == otherInfo.NodeId || strings.HasPrefix(nodeId, otherInfo.NodeId+"@") {
+				n.Close(nodeId) // 以EOF关闭，不会触发重连
+			}
+		}
+	}
+}
+
+// 选择链接目标节点时使用的终端，位于同一主机的节点优先使用unix
+func (n *Node) selectEndPoint(other *ctx.NodeInfo) string {
+	if other.Host != "" && other.Host == n.info.Load().Host {
+		if ep, ok := other.UnixEndPoint(); ok {
+			return ep
+		}
+	}
+	return other.MainEndPoint()
+}
+
+func (n *Node) isBackEnd(id string) bool {
+	name := ctx.GetNodeNameFromId(id)
+	self := n.info.Load()
+	for _, back := range self.BackEnds {
+		if back == name && self.NodeId != id {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *Node) onCheckNode(origin string) (string, error) {
+	ret := false
+	id, _, sig, err := nets.ParseOriginInfo(origin)
+	if err == nil && n.reg != nil { // 未配置注册中心时无法验证身份，一律视为外来节点
+		ret2, err2 := n.reg.CheckNodeSig(id, sig)
+		if err2 != nil {
+			return "", err2
+		}
+		ret = ret2
+	} else {
+		if !n.info.Load().IsPub {
+			if err == nil {
+				err = errutil.New("尚未配置注册中心，无法验证节点身份:" + id)
+			}
+			return "", err
+		}
+	}
+	if !ret { // 如果是注册中心无法验证的节点，则视为外来节点
+		if !n.info.Load().IsPub {
+			return "", errutil.New("节点证书数据不匹配:" + id + "<--->" + sig) // 如果节点对外不开放，则直接放弃链接
+		} else {
+			guestId := ""
+			if id == "" {
+				guestId = ctx.GuestPrefix() + "#" + snowflake.Generate()
+			} else if !strings.HasPrefix(id, ctx.GuestPrefix()) {
+				guestId = ctx.GuestPrefix() + "#" + id
+			}
+			return guestId, nil
+		}
+	} else {
+		// 过滤重复发起链接申请的内部节点
+		_, exist := n.conns.GetConnectInfo(id)
+		if exist {
+			return "", errutil.New("本地已存在相同的链接:" + id)
+		}
+		return id, nil
+	}
+}
+
+func (n *Node) Logger() *log.Logger {
+	return n.log
+}
+
+func (n *Node) Error(err error) {
+	n.pipe.OnError(err)
+}
+
+func (n *Node) NodeId() string {
+	return n.info.Load().NodeId
+}
+
+// 节点名称，无需像NodeInfo()一样复制完整的节点信息
+func (n *Node) Name() string {
+	return n.info.Load().Name
+}
+
+// 节点权重，供按权重选择节点时使用
+func (n *Node) Weight() int {
+	return n.info.Load().Weight
+}
+
+func (n *Node) NodeInfo() *ctx.NodeInfo {
+	return n.info.Load().Clone()
+}
+
+func (n *Node) ConnectManager() *nets.ConnectManager {
+	return n.conns
+}
+
+func (n *Node) Registry() cluster.IRegistry {
+	return n.reg
+}
+
+func (n *Node) printInfo() {
+	fmt.Println()
+	fmt.Println(BANNER)
+	fmt.Println()
+	info, _ := n.info.Load().Clone().Marshal()
+	fmt.Println(info)
+	fmt.Println()
+}
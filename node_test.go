@@ -0,0 +1,163 @@
+package silvernode_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	silvernode "github.com/silvernodes/silvernode-go"
+	"github.com/silvernodes/silvernode-go/cluster"
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/log"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+func freePort(t *testing.T) uint64 {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return uint64(l.Addr().(*net.TCPAddr).Port)
+}
+
+func newInfo(name string, endPoints ...string) *ctx.NodeInfo {
+	info := ctx.NewNodeInfo()
+	info.Name = name
+	info.NodeId = name + "#1"
+	info.EndPoints = endPoints
+	info.LogLevel = log.WARN
+	info.Metrics = false
+	return info
+}
+
+// 启动期间并发读取节点信息
+func TestServeNodeInfo(t *testing.T) {
+	info := newInfo("info", "loop://127.0.0.1:0")
+	n := silvernode.NewNode(&silvernode.SetupParam{NodeInfo: info, ShutdownTimeout: 1000})
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				n.Name()
+				n.NodeInfo()
+			}
+		}
+	}()
+	served := make(chan error, 1)
+	go func() {
+		served <- n.Serve()
+	}()
+	select {
+	case <-n.Ready():
+	case err := <-served:
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+	if n.Name() != "info" || n.NodeId() != "info#1" || n.NodeInfo().MainPort == 0 {
+		t.Fatalf("节点信息未载入: %+v", n.NodeInfo())
+	}
+	c, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := n.Shutdown(c); err != nil {
+		t.Fatal(err)
+	}
+	<-served
+}
+
+// 监听失败时Serve返回错误，并关闭已开启的检测服务
+func TestServeListenFailure(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	info := newInfo("broken", "loop://127.0.0.1:0", "tcp://"+busy.Addr().String())
+	info.MainPort = freePort(t)
+	n := silvernode.NewNode(&silvernode.SetupParam{NodeInfo: info, ShutdownTimeout: 1000})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- n.Serve()
+	}()
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Fatal("监听失败时Serve应返回错误")
+		}
+	case <-n.Ready():
+		t.Fatal("监听失败时节点不应就绪")
+	case <-time.After(time.Second * 10):
+		t.Fatal("等待Serve返回超时")
+	}
+	time.Sleep(time.Millisecond * 100) // 检测服务在后台开启
+	l, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", info.MainPort))
+	if err != nil {
+		t.Fatalf("检测服务的端口未释放: %v", err)
+	}
+	l.Close()
+}
+
+// 启动节点并等待就绪，测试结束时关闭
+func serveNode(t *testing.T, info *ctx.NodeInfo, reg cluster.IRegistry) *silvernode.Node {
+	t.Helper()
+	n := silvernode.NewNode(&silvernode.SetupParam{NodeInfo: info, Registry: reg, ShutdownTimeout: 1000})
+	served := make(chan error, 1)
+	go func() {
+		served <- n.Serve()
+	}()
+	select {
+	case <-n.Ready():
+	case err := <-served:
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		n.Shutdown(c)
+		<-served
+	})
+	return n
+}
+
+// 位于同一主机的节点之间优先以unix链接，其他主机的节点使用主终端
+func TestSelectUnixEndPoint(t *testing.T) {
+	for host, want := range map[string]string{"box-a": "unix", "box-b": "tcp"} {
+		t.Run(host, func(t *testing.T) {
+			reg := silvernodetest.NewMemoryRegistry()
+			logic := newInfo("logic", "tcp://127.0.0.1:0", "unix://"+filepath.Join(t.TempDir(), "logic.sock"))
+			logic.Host = host
+			serveNode(t, logic, reg)
+			gate := newInfo("gate", "tcp://127.0.0.1:0")
+			gate.Host = "box-a"
+			gate.BackEnds = []string{"logic"}
+			n := serveNode(t, gate, reg)
+
+			deadline := time.Now().Add(time.Second * 10)
+			for {
+				if info, exists := n.ConnectManager().GetConnectInfo(logic.NodeId); exists {
+					if info.Proto() != want {
+						t.Fatalf("链接使用的协议不符: %s(%s)", info.Proto(), info.Url())
+					}
+					return
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("等待链接建立超时")
+				}
+				time.Sleep(time.Millisecond * 10)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package peers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+const (
+	BREAKER_CLOSED    int = 0 // 正常放行
+	BREAKER_OPEN      int = 1 // 熔断，请求直接失败
+	BREAKER_HALF_OPEN int = 2 // 试探，仅放行少量请求
+)
+
+const breakerBuckets int = 10
+
+var breakerStates = []string{"closed", "open", "half-open"}
+
+// 熔断器配置，按目标节点id或节点名称指定，每个目标节点各自统计
+// 统计窗口内请求数达到MinRequests且错误率或慢调用率超过阈值时熔断
+type BreakerConf struct {
+	Window           int     // 统计窗口(毫秒)
+	MinRequests      int     // 窗口内请求数达到该值后才进行判定
+	ErrorRate        float64 // 错误率阈值(0~1)，<=0表示不按错误率熔断
+	SlowCall         int     // 耗时超过该值(毫秒)视为慢调用，<=0表示不统计
+	SlowRate         float64 // 慢调用率阈值(0~1)
+	OpenTime         int     // 熔断持续时间(毫秒)，之后进入试探状态
+	HalfOpenRequests int     // 试探状态放行的请求数，全部成功后恢复
+}
+
+func NewBreakerConf() *BreakerConf {
+	return &BreakerConf{
+		Window:           10000,
+		MinRequests:      20,
+		ErrorRate:        0.5,
+		SlowCall:         0,
+		SlowRate:         0.5,
+		OpenTime:         5000,
+		HalfOpenRequests: 3,
+	}
+}
+
+// 以缺省值补全未指定的字段
+func (b *BreakerConf) normalize() *BreakerConf {
+	conf := *b
+	def := NewBreakerConf()
+	if conf.Window <= 0 {
+		conf.Window = def.Window
+	}
+	if conf.MinRequests <= 0 {
+		conf.MinRequests = def.MinRequests
+	}
+	if conf.SlowRate <= 0 {
+		conf.SlowRate = def.SlowRate
+	}
+	if conf.OpenTime <= 0 {
+		conf.OpenTime = def.OpenTime
+	}
+	if conf.HalfOpenRequests <= 0 {
+		conf.HalfOpenRequests = def.HalfOpenRequests
+	}
+	return &conf
+}
+
+// 熔断器的当前状态，供看板及监控使用
+type BreakerStat struct {
+	Node     string
+	State    string
+	Requests int // 窗口内请求数
+	Failures int
+	Slows    int
+	OpenedAt *time.Time `json:",omitempty"` // 熔断时间，正常状态下为nil
+}
+
+type bucket struct {
+	start    int64
+	requests int
+	failures int
+	slows    int
+}
+
+type breaker struct {
+	hub      *Hub
+	node     string
+	conf     *BreakerConf
+	state    int
+	buckets  []bucket
+	openedAt time.Time
+	probes   int // 试探状态已放行的请求数
+	passes   int // 试探状态已成功的请求数
+	lock     sync.Mutex
+}
+
+func newBreaker(hub *Hub, node string, conf *BreakerConf) *breaker {
+	b := new(breaker)
+	b.hub = hub
+	b.node = node
+	b.conf = conf
+	b.buckets = make([]bucket, breakerBuckets)
+	return b
+}
+
+func (b *breaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BREAKER_OPEN:
+		if time.Since(b.openedAt) < time.Duration(b.conf.OpenTime)*time.Millisecond {
+			return false
+		}
+		b.transit(BREAKER_HALF_OPEN)
+		fallthrough
+	case BREAKER_HALF_OPEN:
+		if b.probes >= b.conf.HalfOpenRequests {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+func (b *breaker) record(failed bool, cost time.Duration) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	slow := b.conf.SlowCall > 0 && cost >= time.Duration(b.conf.SlowCall)*time.Millisecond
+	switch b.state {
+	case BREAKER_HALF_OPEN:
+		if failed || slow {
+			b.transit(BREAKER_OPEN)
+		} else if b.passes++; b.passes >= b.conf.HalfOpenRequests {
+			b.transit(BREAKER_CLOSED)
+		}
+	case BREAKER_CLOSED:
+		bk := b.current()
+		bk.requests++
+		if failed {
+			bk.failures++
+		}
+		if slow {
+			bk.slows++
+		}
+		requests, failures, slows := b.sum()
+		if requests < b.conf.MinRequests {
+			return
+		}
+		if b.conf.ErrorRate > 0 && float64(failures)/float64(requests) >= b.conf.ErrorRate {
+			b.transit(BREAKER_OPEN)
+		} else if b.conf.SlowCall > 0 && float64(slows)/float64(requests) >= b.conf.SlowRate {
+			b.transit(BREAKER_OPEN)
+		}
+	}
+}
+
+// 持有锁时调用
+func (b *breaker) transit(state int) {
+	b.state = state
+	b.probes = 0
+	b.passes = 0
+	switch state {
+	case BREAKER_OPEN:
+		b.openedAt = time.Now()
+	case BREAKER_CLOSED:
+		b.buckets = make([]bucket, breakerBuckets)
+	}
+	_breakerState.WithLabelValues(b.hub.node.NodeId(), b.node).Set(float64(state))
+	b.hub.node.Logger().Warn("熔断器状态变更:" + b.node + " -> " + breakerStates[state])
+}
+
+// 持有锁时调用
+func (b *breaker) current() *bucket {
+	span := int64(b.conf.Window / breakerBuckets)
+	if span <= 0 {
+		span = 1
+	}
+	start := time.Now().UnixMilli() / span * span
+	bk := &b.buckets[int(start/span)%breakerBuckets]
+	if bk.start != start {
+		*bk = bucket{start: start}
+	}
+	return bk
+}
+
+// 持有锁时调用
+func (b *breaker) sum() (int, int, int) {
+	requests, failures, slows := 0, 0, 0
+	since := time.Now().UnixMilli() - int64(b.conf.Window)
+	for _, bk := range b.buckets {
+		if bk.start > since {
+			requests += bk.requests
+			failures += bk.failures
+			slows += bk.slows
+		}
+	}
+	return requests, failures, slows
+}
+
+func (b *breaker) stat() *BreakerStat {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	stat := &BreakerStat{Node: b.node, State: breakerStates[b.state]}
+	stat.Requests, stat.Failures, stat.Slows = b.sum()
+	if b.state != BREAKER_CLOSED {
+		openedAt := b.openedAt
+		stat.OpenedAt = &openedAt
+	}
+	return stat
+}
+
+// 调用方主动放弃不计为失败，被调方返回的业务错误说明目标节点可用，同样不计
+func breakerFailed(err error) bool {
+	return err != nil && err != context.Canceled && !IsRemoteError(err)
+}
+
+// 为目标节点id或节点名称设置熔断器，节点id的配置优先；conf为nil时移除
+func (h *Hub) SetBreaker(target string, conf *BreakerConf) {
+	h.breakerLock.Lock()
+	defer h.breakerLock.Unlock()
+
+	if conf == nil {
+		delete(h.breakerConfs, target)
+	} else {
+		h.breakerConfs[target] = conf.normalize()
+	}
+	for node, b := range h.breakers { // 配置变更后按新配置重新统计
+		if node == target || ctx.GetNodeNameFromId(node) == target {
+			delete(h.breakers, node)
+			_breakerState.DeleteLabelValues(h.node.NodeId(), b.node)
+		}
+	}
+}
+
+func SetBreaker(target string, conf *BreakerConf) {
+	_hub.SetBreaker(target, conf)
+}
+
+func (h *Hub) getBreaker(node string) *breaker {
+	h.breakerLock.Lock()
+	defer h.breakerLock.Unlock()
+
+	if b, exists := h.breakers[node]; exists {
+		return b
+	}
+	conf, exists := h.breakerConfs[node]
+	if !exists {
+		if conf, exists = h.breakerConfs[ctx.GetNodeNameFromId(node)]; !exists {
+			return nil
+		}
+	}
+	b := newBreaker(h, node, conf)
+	h.breakers[node] = b
+	_breakerState.WithLabelValues(h.node.NodeId(), node).Set(float64(BREAKER_CLOSED))
+	return b
+}
+
+// 各目标节点熔断器的当前状态，按节点id排序
+func (h *Hub) Breakers() []*BreakerStat {
+	h.breakerLock.Lock()
+	breakers := make([]*breaker, 0, len(h.breakers))
+	for _, b := range h.breakers {
+		breakers = append(breakers, b)
+	}
+	h.breakerLock.Unlock()
+
+	stats := make([]*BreakerStat, 0, len(breakers))
+	for _, b := range breakers {
+		stats = append(stats, b.stat())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Node < stats[j].Node
+	})
+	return stats
+}
+
+func Breakers() []*BreakerStat {
+	return _hub.Breakers()
+}
+
+func errBreakerOpen(node string) error {
+	return errutil.New("目标节点已熔断,请求被拒绝:" + node)
+}
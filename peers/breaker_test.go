@@ -0,0 +1,286 @@
+package peers_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/process"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+type DbReq struct {
+	Ctx context.Context `auto:"context"`
+}
+
+type IdempotentReq struct {
+	Key string
+	Ctx context.Context `auto:"context"`
+	_   struct{}        `peer:"idempotent"`
+}
+
+type DbResp struct {
+	Ok bool
+}
+
+type Db struct {
+	calls int32
+	delay int64 // 处理耗时(纳秒)
+	slow  int32 // Get的前slow次调用耗时delay
+}
+
+func (d *Db) Query(args *DbReq, reply *DbResp) error {
+	atomic.AddInt32(&d.calls, 1)
+	return d.work(args.Ctx, reply)
+}
+
+func (d *Db) Get(args *IdempotentReq, reply *DbResp) error {
+	if atomic.AddInt32(&d.calls, 1) > atomic.LoadInt32(&d.slow) {
+		reply.Ok = true
+		return nil
+	}
+	return d.work(args.Ctx, reply)
+}
+
+func (d *Db) Fail(args *IdempotentReq, reply *DbResp) error {
+	atomic.AddInt32(&d.calls, 1)
+	return errors.New("记录不存在")
+}
+
+// 超时后即释放处理协程，以免重试的请求排队至过期被丢弃
+func (d *Db) work(c context.Context, reply *DbResp) error {
+	select {
+	case <-time.After(time.Duration(atomic.LoadInt64(&d.delay))):
+		reply.Ok = true
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+func (d *Db) reset(delay time.Duration, slow int32) {
+	atomic.StoreInt32(&d.calls, 0)
+	atomic.StoreInt64(&d.delay, int64(delay))
+	atomic.StoreInt32(&d.slow, slow)
+}
+
+func startDb(t *testing.T) (*silvernodetest.Node, *silvernodetest.Node, *Db) {
+	t.Helper()
+	db := new(Db)
+	c, gate := startGate(t, "db", &silvernodetest.NodeSpec{Name: "db", Init: func(n *silvernodetest.Node) error {
+		_, err := n.Hub().Register(db, process.SpawnM(8, 256))
+		return err
+	}})
+	return gate, c.NodesByName("db")[0], db
+}
+
+func breakerState(t *testing.T, gate *silvernodetest.Node, node string) *peers.BreakerStat {
+	t.Helper()
+	for _, stat := range gate.Hub().Breakers() {
+		if stat.Node == node {
+			return stat
+		}
+	}
+	t.Fatalf("未找到熔断器: %s", node)
+	return nil
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	withTimeout(t, 150)
+	gate, db, d := startDb(t)
+	gate.Hub().SetBreaker("db", &peers.BreakerConf{MinRequests: 5, ErrorRate: 0.5, OpenTime: 1000, HalfOpenRequests: 2})
+
+	d.reset(time.Millisecond*400, 0)
+	for i := 0; i < 5; i++ {
+		if err := gate.Invoke(db.Id(), "Db.Query", &DbReq{}, new(DbResp)); err == nil || peers.IsRemoteError(err) {
+			t.Fatalf("应因超时失败: %v", err)
+		}
+	}
+	stat := breakerState(t, gate, db.Id())
+	if stat.State != "open" || stat.Failures != 5 || stat.OpenedAt == nil {
+		t.Fatalf("应已熔断: %+v", stat)
+	}
+	time.Sleep(time.Millisecond * 400) // 等待已发出的请求处理完毕
+	calls := atomic.LoadInt32(&d.calls)
+	begin := time.Now()
+	err := gate.Invoke(db.Id(), "Db.Query", &DbReq{}, new(DbResp))
+	if err == nil || !strings.Contains(err.Error(), "熔断") || time.Since(begin) > time.Millisecond*50 {
+		t.Fatalf("熔断期间请求应直接失败: %v", err)
+	}
+	time.Sleep(time.Millisecond * 50)
+	if now := atomic.LoadInt32(&d.calls); now != calls {
+		t.Fatalf("熔断期间的请求不应发出: %d -> %d", calls, now)
+	}
+
+	// 到期后放行试探请求，全部成功后恢复
+	d.reset(0, 0)
+	time.Sleep(time.Until(stat.OpenedAt.Add(time.Millisecond * 1050)))
+	for i := 0; i < 2; i++ {
+		if err := gate.Invoke(db.Id(), "Db.Query", &DbReq{}, new(DbResp)); err != nil {
+			t.Fatalf("试探请求%d失败: %v", i, err)
+		}
+	}
+	if stat := breakerState(t, gate, db.Id()); stat.State != "closed" || stat.OpenedAt != nil {
+		t.Fatalf("试探成功后应恢复: %+v", stat)
+	}
+}
+
+func TestBreakerSlowCall(t *testing.T) {
+	gate, db, d := startDb(t)
+	gate.Hub().SetBreaker(db.Id(), &peers.BreakerConf{MinRequests: 4, SlowCall: 50, SlowRate: 0.5, OpenTime: 60000})
+
+	d.reset(time.Millisecond*80, 0)
+	for i := 0; i < 4; i++ {
+		if err := gate.Invoke(db.Id(), "Db.Query", &DbReq{}, new(DbResp)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stat := breakerState(t, gate, db.Id()); stat.State != "open" || stat.Slows != 4 || stat.Failures != 0 {
+		t.Fatalf("慢调用率超出阈值应熔断: %+v", stat)
+	}
+
+	// 移除配置后不再熔断
+	gate.Hub().SetBreaker(db.Id(), nil)
+	if err := gate.Invoke(db.Id(), "Db.Query", &DbReq{}, new(DbResp)); err != nil {
+		t.Fatal(err)
+	}
+	if stats := gate.Hub().Breakers(); len(stats) != 0 {
+		t.Fatalf("移除后不应保留熔断器: %+v", stats[0])
+	}
+}
+
+// 被调方返回的业务错误说明目标节点可用，不计为失败也不重试
+func TestBreakerRemoteError(t *testing.T) {
+	gate, db, d := startDb(t)
+	gate.Hub().SetBreaker("db", &peers.BreakerConf{MinRequests: 3, ErrorRate: 0.1})
+
+	d.reset(0, 0)
+	for i := 0; i < 6; i++ {
+		err := gate.Invoke(db.Id(), "Db.Fail", &IdempotentReq{}, new(DbResp))
+		if !peers.IsRemoteError(err) || !strings.Contains(err.Error(), "记录不存在") {
+			t.Fatalf("应返回被调方的错误: %v", err)
+		}
+	}
+	if stat := breakerState(t, gate, db.Id()); stat.State != "closed" || stat.Requests != 6 || stat.Failures != 0 {
+		t.Fatalf("业务错误不应计为失败: %+v", stat)
+	}
+	if calls := atomic.LoadInt32(&d.calls); calls != 6 {
+		t.Fatalf("业务错误不应重试: %d", calls)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	withTimeout(t, 150)
+	gate, db, d := startDb(t)
+
+	// 以标签标记的幂等方法，首次超时后重试成功
+	d.reset(time.Millisecond*300, 1)
+	reply := new(DbResp)
+	if err := gate.Invoke(db.Id(), "Db.Get", &IdempotentReq{}, reply); err != nil || !reply.Ok {
+		t.Fatalf("重试后应成功: %v", err)
+	}
+	if calls := atomic.LoadInt32(&d.calls); calls != 2 {
+		t.Fatalf("调用次数不符: %d", calls)
+	}
+
+	// 非幂等方法不重试
+	d.reset(time.Millisecond*300, 0)
+	if err := gate.Invoke(db.Id(), "Db.Query", &DbReq{}, new(DbResp)); err == nil {
+		t.Fatal("应因超时失败")
+	}
+	if calls := atomic.LoadInt32(&d.calls); calls != 1 {
+		t.Fatalf("非幂等方法不应重试: %d", calls)
+	}
+
+	// 登记为幂等后按策略重试，全部失败后返回最后一次的错误
+	gate.Hub().RegisterIdempotent("Db.Query")
+	gate.Hub().SetRetryPolicy(&peers.RetryPolicy{Attempts: 4, Backoff: 10, MaxBackoff: 20})
+	d.reset(time.Millisecond*300, 0)
+	if err := gate.Invoke(db.Id(), "Db.Query", &DbReq{}, new(DbResp)); err == nil {
+		t.Fatal("应因超时失败")
+	}
+	if calls := atomic.LoadInt32(&d.calls); calls != 4 {
+		t.Fatalf("应共尝试4次: %d", calls)
+	}
+
+	// 关闭重试
+	gate.Hub().SetRetryPolicy(nil)
+	d.reset(time.Millisecond*300, 1)
+	if err := gate.Invoke(db.Id(), "Db.Get", &IdempotentReq{}, new(DbResp)); err == nil {
+		t.Fatal("关闭重试后应因超时失败")
+	}
+	if calls := atomic.LoadInt32(&d.calls); calls != 1 {
+		t.Fatalf("关闭重试后不应重试: %d", calls)
+	}
+}
+
+// 熔断拒绝不重试
+func TestRetryBreakerOpen(t *testing.T) {
+	gate, db, d := startDb(t)
+	gate.Hub().SetBreaker(db.Id(), &peers.BreakerConf{MinRequests: 1, SlowCall: 1, SlowRate: 0.1, OpenTime: 60000})
+
+	d.reset(time.Millisecond*20, 1)
+	if err := gate.Invoke(db.Id(), "Db.Get", &IdempotentReq{}, new(DbResp)); err != nil {
+		t.Fatal(err)
+	}
+	err := gate.Invoke(db.Id(), "Db.Get", &IdempotentReq{}, new(DbResp))
+	if err == nil || !strings.Contains(err.Error(), "熔断") {
+		t.Fatalf("应被熔断器拒绝: %v", err)
+	}
+	if calls := atomic.LoadInt32(&d.calls); calls != 1 {
+		t.Fatalf("熔断拒绝不应重试: %d", calls)
+	}
+}
+
+// 等待重试期间上下文结束或节点关闭，请求随即以错误结束
+func TestRetryAbortedDuringBackoff(t *testing.T) {
+	withTimeout(t, 150)
+	gate, db, d := startDb(t)
+	gate.Hub().SetRetryPolicy(&peers.RetryPolicy{Attempts: 3, Backoff: 60000, MaxBackoff: 60000})
+
+	call := func(c context.Context) chan error {
+		done := make(chan error, 1)
+		gate.Caller().CallCtx(c, db.Id(), "Db.Get", &IdempotentReq{}, new(DbResp), func(err error) {
+			done <- err
+		})
+		return done
+	}
+	wait := func(done chan error) error {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(silvernodetest.DefaultWait):
+			t.Fatal("等待重试的请求未结束")
+			return nil
+		}
+	}
+
+	d.reset(time.Millisecond*300, 2)
+	c, cancel := context.WithCancel(context.Background())
+	done := call(c)
+	time.Sleep(time.Millisecond * 300) // 首次请求已超时，等待重试
+	cancel()
+	if err := wait(done); !errors.Is(err, context.Canceled) {
+		t.Fatalf("应返回上下文取消: %v", err)
+	}
+
+	done = call(context.Background())
+	time.Sleep(time.Millisecond * 300)
+	begin := time.Now()
+	if err := gate.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second*2 {
+		t.Fatalf("关闭节点时不应等待重试: %v", elapsed)
+	}
+	if err := wait(done); err == nil || !strings.Contains(err.Error(), "节点已关闭") {
+		t.Fatalf("应因节点关闭而中断: %v", err)
+	}
+	if calls := atomic.LoadInt32(&d.calls); calls != 2 {
+		t.Fatalf("中断后不应再重试: %d", calls)
+	}
+}
@@ -0,0 +1,83 @@
+package peers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 汇聚调用中单个节点的结果
+type GatherResult struct {
+	NodeId string
+	Reply  interface{} // 由newReply创建，Err为nil时已填充应答内容
+	Err    error
+}
+
+// 已链接的指定名称节点，按NodeId排序，不含附加链接及本节点
+func (h *Hub) linkedNodes(name string) []string {
+	nodeIds := make([]string, 0, 4)
+	for _, nodeId := range h.node.GetNodeList(name) {
+		if !strings.Contains(nodeId, "@") {
+			nodeIds = append(nodeIds, nodeId)
+		}
+	}
+	sort.Strings(nodeIds)
+	return nodeIds
+}
+
+// 向已链接的全部同名节点并发发送事件，不等待处理结果
+// 部分节点发送失败时返回的错误中列出各失败节点
+func (p *peer) Broadcast(name string, method string, args interface{}) error {
+	return p.BroadcastCtx(context.Background(), name, method, args)
+}
+
+func (p *peer) BroadcastCtx(c context.Context, name string, method string, args interface{}) error {
+	nodeIds := p.hub.linkedNodes(name)
+	errs := make([]error, len(nodeIds))
+	var wg sync.WaitGroup
+	for i, nodeId := range nodeIds {
+		wg.Add(1)
+		go func(i int, nodeId string) {
+			defer wg.Done()
+			errs[i] = p.SendEventCtx(c, nodeId, method, args)
+		}(i, nodeId)
+	}
+	wg.Wait()
+	txt := ""
+	for i, err := range errs {
+		if err != nil {
+			txt += "\n" + nodeIds[i] + "|" + err.Error()
+		}
+	}
+	if txt != "" {
+		return errutil.New("广播事件失败:" + method + txt)
+	}
+	return nil
+}
+
+// 向已链接的全部同名节点并发发起调用，等待全部节点应答、失败或超时后返回各节点的结果
+// newReply为每个节点分别创建应答结构，整体时限为全局超时
+func (p *peer) Gather(name string, method string, args interface{}, newReply func() interface{}) []*GatherResult {
+	return p.GatherCtx(context.Background(), name, method, args, newReply)
+}
+
+// 整体时限取上下文截止时间与全局超时中较早者，到期未应答的节点其Err为c.Err()
+func (p *peer) GatherCtx(c context.Context, name string, method string, args interface{}, newReply func() interface{}) []*GatherResult {
+	nodeIds := p.hub.linkedNodes(name)
+	results := make([]*GatherResult, len(nodeIds))
+	var wg sync.WaitGroup
+	for i, nodeId := range nodeIds {
+		result := &GatherResult{NodeId: nodeId, Reply: newReply()}
+		results[i] = result
+		wg.Add(1)
+		p.CallCtx(c, nodeId, method, args, result.Reply, func(err error) {
+			result.Err = err
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	return results
+}
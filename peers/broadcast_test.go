@@ -0,0 +1,167 @@
+package peers_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+type ShardReq struct {
+	Text string
+}
+
+type ShardResp struct {
+	Node string
+	Text string
+}
+
+type Shard struct {
+	nodeId string
+	delay  time.Duration
+	err    string
+	events chan string
+}
+
+func (s *Shard) Query(args *ShardReq, reply *ShardResp) error {
+	time.Sleep(s.delay)
+	if s.err != "" {
+		return errors.New(s.err)
+	}
+	reply.Node = s.nodeId
+	reply.Text = args.Text
+	return nil
+}
+
+func (s *Shard) Notify(args *ShardReq) error {
+	s.events <- s.nodeId + "|" + args.Text
+	return nil
+}
+
+// gate节点以shard为后端，各shard节点按shards中的设置处理请求
+func startShards(t *testing.T, shards ...*Shard) (*silvernodetest.Cluster, *silvernodetest.Node) {
+	t.Helper()
+	specs := make([]*silvernodetest.NodeSpec, 0, len(shards))
+	for _, shard := range shards {
+		shard := shard
+		specs = append(specs, procSpec("shard", func(n *silvernodetest.Node) interface{} {
+			shard.nodeId = n.Id()
+			return shard
+		}))
+	}
+	return startGate(t, "shard", specs...)
+}
+
+func TestBroadcast(t *testing.T) {
+	events := make(chan string, 16)
+	shards := []*Shard{{events: events}, {events: events}, {events: events}}
+	_, gate := startShards(t, shards...)
+
+	if err := gate.Caller().Broadcast("shard", "Shard.Notify", &ShardReq{Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]bool)
+	for range shards {
+		select {
+		case event := <-events:
+			got[event] = true
+		case <-time.After(silvernodetest.DefaultWait):
+			t.Fatalf("等待事件超时: %v", got)
+		}
+	}
+	for _, shard := range shards {
+		if !got[shard.nodeId+"|hi"] {
+			t.Fatalf("%s未收到事件: %v", shard.nodeId, got)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("每个节点应只收到一次: %s", event)
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	if err := gate.Caller().Broadcast("nope", "Shard.Notify", &ShardReq{}); err != nil {
+		t.Fatalf("没有同名节点时不应返回错误: %v", err)
+	}
+}
+
+func TestBroadcastLinkLost(t *testing.T) {
+	events := make(chan string, 16)
+	shards := []*Shard{{events: events}, {events: events}}
+	_, gate := startShards(t, shards...)
+
+	// 广播过程中链接断开的节点列于错误中
+	gate.Hub().Intercept(func(next peers.Invoker) peers.Invoker {
+		return func(req *peers.Request, done func(error)) {
+			if req.Node == shards[0].nodeId {
+				gate.CloseLink(req.Node)
+			}
+			next(req, done)
+		}
+	})
+	err := gate.Caller().BroadcastCtx(context.Background(), "shard", "Shard.Notify", &ShardReq{Text: "hi"})
+	if err == nil || !strings.Contains(err.Error(), shards[0].nodeId) || strings.Contains(err.Error(), shards[1].nodeId) {
+		t.Fatalf("错误中应仅列出失败的节点: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event != shards[1].nodeId+"|hi" {
+			t.Fatalf("事件不符: %s", event)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("等待事件超时")
+	}
+}
+
+func TestGather(t *testing.T) {
+	shards := []*Shard{{}, {err: "分片不可用"}, {delay: time.Second * 3}, {}}
+	_, gate := startShards(t, shards...)
+
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel()
+	begin := time.Now()
+	results := gate.Caller().GatherCtx(c, "shard", "Shard.Query", &ShardReq{Text: "q"}, func() interface{} {
+		return new(ShardResp)
+	})
+	if elapsed := time.Since(begin); elapsed > time.Second*2 {
+		t.Fatalf("到期后未及时返回: %v", elapsed)
+	}
+	if len(results) != len(shards) {
+		t.Fatalf("结果数量不符: %d", len(results))
+	}
+	byNode := make(map[string]*peers.GatherResult)
+	for i, result := range results {
+		if i > 0 && results[i-1].NodeId >= result.NodeId {
+			t.Fatal("结果应按节点id排序")
+		}
+		byNode[result.NodeId] = result
+	}
+	for _, shard := range shards {
+		result := byNode[shard.nodeId]
+		switch {
+		case shard.err != "":
+			if result.Err == nil || !strings.Contains(result.Err.Error(), shard.err) {
+				t.Fatalf("%s的错误不符: %v", shard.nodeId, result.Err)
+			}
+		case shard.delay > 0:
+			if !errors.Is(result.Err, context.DeadlineExceeded) {
+				t.Fatalf("%s应因超时失败: %v", shard.nodeId, result.Err)
+			}
+		default:
+			reply := result.Reply.(*ShardResp)
+			if result.Err != nil || reply.Node != shard.nodeId || reply.Text != "q" {
+				t.Fatalf("%s的应答不符: %+v %v", shard.nodeId, reply, result.Err)
+			}
+		}
+	}
+
+	if results := gate.Caller().Gather("nope", "Shard.Query", &ShardReq{}, func() interface{} {
+		return new(ShardResp)
+	}); len(results) != 0 {
+		t.Fatalf("没有同名节点时结果应为空: %d", len(results))
+	}
+}
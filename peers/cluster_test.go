@@ -0,0 +1,35 @@
+package peers_test
+
+import (
+	"testing"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+// 缩短全局超时，结束后恢复缺省值
+func withTimeout(t *testing.T, ms int) {
+	peers.Setup(&peers.SetupParam{Timeout: ms})
+	t.Cleanup(func() {
+		peers.Setup(&peers.SetupParam{Timeout: 15000})
+	})
+}
+
+// 名为name的节点，启动时注册newProc创建的Peer(以类型名为昵称，各请求独立协程处理)
+func procSpec(name string, newProc func(n *silvernodetest.Node) interface{}) *silvernodetest.NodeSpec {
+	return &silvernodetest.NodeSpec{Name: name, Init: func(n *silvernodetest.Node) error {
+		_, err := n.Hub().Register(newProc(n), nil)
+		return err
+	}}
+}
+
+// 启动以backend为后端的gate节点及specs中的节点，等待链接建立后返回集群及gate节点
+func startGate(t *testing.T, backend string, specs ...*silvernodetest.NodeSpec) (*silvernodetest.Cluster, *silvernodetest.Node) {
+	t.Helper()
+	specs = append([]*silvernodetest.NodeSpec{{Name: "gate", BackEnds: []string{backend}}}, specs...)
+	c := silvernodetest.Run(t, specs...)
+	if err := c.WaitLinked(silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	return c, c.NodesByName("gate")[0]
+}
@@ -1,13 +1,20 @@
 package peers
 
 import (
+	"bytes"
+	"reflect"
+
 	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
 	"github.com/silvernodes/silvernode-go/utils/gobutil"
 	"github.com/silvernodes/silvernode-go/utils/jsonutil"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
 )
 
 var _outerCodec Codec = nil
 var _innerCodec Codec = nil
+var _defaultCodec Codec = NewGobCodec()
 
 type Codec interface {
 	Encode(interface{}) ([]byte, error)
@@ -22,11 +29,16 @@ func SetInnerCodec(codec Codec) {
 	_innerCodec = codec
 }
 
-func getCodec(node string) (Codec, bool) {
+// 未设置编解码器时以gob编码
+func getCodec(node string) Codec {
+	codec := _innerCodec
 	if ctx.IsGuest(node) {
-		return _outerCodec, _outerCodec != nil
+		codec = _outerCodec
+	}
+	if codec == nil {
+		return _defaultCodec
 	}
-	return _innerCodec, _innerCodec != nil
+	return codec
 }
 
 type JsonCodec struct {
@@ -58,3 +70,56 @@ func (g *GobCodec) Encode(obj interface{}) ([]byte, error) {
 func (g *GobCodec) Decode(data []byte, ref interface{}) error {
 	return gobutil.Unmarshal(data, ref)
 }
+
+// protobuf编解码，参数及应答须为protoc-gen-go生成的消息类型(实现proto.Message)
+// 可配合protoc-gen-silvernode由.proto文件生成Peer及客户端
+type ProtoCodec struct {
+}
+
+func NewProtoCodec() *ProtoCodec {
+	return new(ProtoCodec)
+}
+
+func (p *ProtoCodec) Encode(obj interface{}) ([]byte, error) {
+	msg, ok := obj.(proto.Message)
+	if !ok {
+		return nil, errutil.New("protobuf编码的对象须实现proto.Message:" + reflect.TypeOf(obj).String())
+	}
+	return proto.Marshal(msg)
+}
+
+func (p *ProtoCodec) Decode(data []byte, ref interface{}) error {
+	msg, ok := ref.(proto.Message)
+	if !ok {
+		return errutil.New("protobuf解码的对象须实现proto.Message:" + reflect.TypeOf(ref).String())
+	}
+	return proto.Unmarshal(data, msg)
+}
+
+// MessagePack编解码，字段名可由msgpack标签指定，未指定时沿用json标签
+type MsgpackCodec struct {
+}
+
+func NewMsgpackCodec() *MsgpackCodec {
+	return new(MsgpackCodec)
+}
+
+func (m *MsgpackCodec) Encode(obj interface{}) ([]byte, error) {
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+	buf := new(bytes.Buffer)
+	enc.Reset(buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(obj); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *MsgpackCodec) Decode(data []byte, ref interface{}) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+	dec.Reset(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(ref)
+}
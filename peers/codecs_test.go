@@ -0,0 +1,150 @@
+package peers_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type SumReq struct {
+	Nums  []int             `json:"nums"`
+	Label string            `json:"label" msgpack:"tag"`
+	Extra map[string]string `json:"extra,omitempty"`
+	Inner *SumReq           `json:"inner,omitempty"`
+}
+
+type SumResp struct {
+	Total int `json:"total"`
+}
+
+type Calc struct {
+}
+
+func (c *Calc) Sum(args *SumReq, reply *SumResp) error {
+	for _, num := range args.Nums {
+		reply.Total += num
+	}
+	return nil
+}
+
+func (c *Calc) Upper(args *wrapperspb.StringValue, reply *wrapperspb.StringValue) error {
+	reply.Value = strings.ToUpper(args.Value)
+	return nil
+}
+
+func TestProtoCodec(t *testing.T) {
+	codec := peers.NewProtoCodec()
+	fields, err := structpb.NewStruct(map[string]interface{}{"room": "r1", "seats": 4.0, "open": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []proto.Message{
+		wrapperspb.String("hi"),
+		timestamppb.New(time.Unix(1700000000, 42)),
+		fields,
+	} {
+		data, err := codec.Encode(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref := msg.ProtoReflect().New().Interface()
+		if err := codec.Decode(data, ref); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(msg, ref) {
+			t.Fatalf("解码结果不符: %v <--> %v", msg, ref)
+		}
+	}
+
+	if _, err := codec.Encode(&SumReq{}); err == nil || !strings.Contains(err.Error(), "proto.Message") {
+		t.Fatalf("非proto消息应编码失败: %v", err)
+	}
+	data, _ := codec.Encode(wrapperspb.String("hi"))
+	if err := codec.Decode(data, new(SumReq)); err == nil || !strings.Contains(err.Error(), "proto.Message") {
+		t.Fatalf("非proto消息应解码失败: %v", err)
+	}
+}
+
+func TestMsgpackCodec(t *testing.T) {
+	codec := peers.NewMsgpackCodec()
+	req := &SumReq{Nums: []int{1, 2, 3}, Label: "l", Extra: map[string]string{"k": "v"}, Inner: &SumReq{Nums: []int{4}}}
+	data, err := codec.Encode(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(SumReq)
+	if err := codec.Decode(data, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Nums) != 3 || got.Nums[2] != 3 || got.Label != "l" || got.Extra["k"] != "v" || got.Inner == nil || got.Inner.Nums[0] != 4 {
+		t.Fatalf("解码结果不符: %+v", got)
+	}
+
+	// 字段名优先取msgpack标签，其次为json标签，供其他语言的客户端按名称解析
+	raw := make(map[string]interface{})
+	if err := codec.Decode(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"nums", "tag", "extra", "inner"} {
+		if _, exists := raw[key]; !exists {
+			t.Fatalf("缺少字段%s: %v", key, raw)
+		}
+	}
+	if _, exists := raw["label"]; exists {
+		t.Fatalf("msgpack标签应优先于json标签: %v", raw)
+	}
+	data, _ = codec.Encode(&SumReq{Nums: []int{1}})
+	raw = make(map[string]interface{})
+	codec.Decode(data, &raw)
+	if _, exists := raw["extra"]; exists {
+		t.Fatalf("应沿用json标签的omitempty: %v", raw)
+	}
+}
+
+// 节点之间以指定的编解码器传递参数及应答
+func TestInnerCodec(t *testing.T) {
+	c, gate := startGate(t, "calc", procSpec("calc", func(n *silvernodetest.Node) interface{} {
+		return new(Calc)
+	}))
+	calc := c.NodesByName("calc")[0].Id()
+	t.Cleanup(func() {
+		peers.SetInnerCodec(nil)
+	})
+
+	for name, codec := range map[string]peers.Codec{
+		"json":    peers.NewJsonCodec(),
+		"gob":     peers.NewGobCodec(),
+		"msgpack": peers.NewMsgpackCodec(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			peers.SetInnerCodec(codec)
+			reply := new(SumResp)
+			if err := gate.Caller().Invoke(calc, "Calc.Sum", &SumReq{Nums: []int{1, 2, 3}}, reply); err != nil {
+				t.Fatal(err)
+			}
+			if reply.Total != 6 {
+				t.Fatalf("应答不符: %+v", reply)
+			}
+		})
+	}
+	t.Run("proto", func(t *testing.T) {
+		peers.SetInnerCodec(peers.NewProtoCodec())
+		reply := new(wrapperspb.StringValue)
+		if err := gate.Caller().Invoke(calc, "Calc.Upper", wrapperspb.String("hi"), reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Value != "HI" {
+			t.Fatalf("应答不符: %v", reply)
+		}
+		if err := gate.Caller().Invoke(calc, "Calc.Sum", &SumReq{Nums: []int{1}}, new(SumResp)); err == nil {
+			t.Fatal("非proto消息应调用失败")
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package peers_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/process"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+type SlowReq struct {
+	Ms  int
+	Ctx context.Context `auto:"context"`
+}
+
+type SlowResp struct {
+	Left int64 // 被调方看到的剩余时限(毫秒)，-1为不限
+	Done bool
+}
+
+type Slow struct {
+	started chan int
+	aborted chan error
+}
+
+func (s *Slow) Work(args *SlowReq, reply *SlowResp) error {
+	reply.Left = -1
+	if deadline, ok := args.Ctx.Deadline(); ok {
+		reply.Left = time.Until(deadline).Milliseconds()
+	}
+	s.started <- args.Ms
+	select {
+	case <-time.After(time.Duration(args.Ms) * time.Millisecond):
+		reply.Done = true
+		return nil
+	case <-args.Ctx.Done():
+		s.aborted <- args.Ctx.Err()
+		return args.Ctx.Err()
+	}
+}
+
+// slow节点开放Slow(并发处理)及Queued(单协程依次处理)，gate节点以slow为后端
+func startSlow(t *testing.T) (*silvernodetest.Node, *silvernodetest.Node, *Slow) {
+	t.Helper()
+	slow := &Slow{started: make(chan int, 16), aborted: make(chan error, 16)}
+	c, gate := startGate(t, "slow", &silvernodetest.NodeSpec{Name: "slow", Init: func(n *silvernodetest.Node) error {
+		if _, err := n.Hub().Register(slow, nil); err != nil {
+			return err
+		}
+		_, err := n.Hub().RegisterWithNick("Queued", false, slow, process.Spawn(64))
+		return err
+	}})
+	return gate, c.NodesByName("slow")[0], slow
+}
+
+// 到期时被调方的上下文可能先因自身时限到期，也可能先收到取消通知
+func expectAborted(t *testing.T, slow *Slow, want ...error) {
+	t.Helper()
+	select {
+	case err := <-slow.aborted:
+		for _, w := range want {
+			if errors.Is(err, w) {
+				return
+			}
+		}
+		t.Fatalf("被调方上下文的错误不符: got %v, want %v", err, want)
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("被调方未中止处理")
+	}
+}
+
+func TestInvokeCtxDeadline(t *testing.T) {
+	gate, slow, s := startSlow(t)
+
+	c, cancel := context.WithTimeout(context.Background(), time.Second*2)
+	defer cancel()
+	reply := new(SlowResp)
+	if err := gate.Caller().InvokeCtx(c, slow.Id(), "Slow.Work", &SlowReq{Ms: 1}, reply); err != nil {
+		t.Fatal(err)
+	}
+	<-s.started
+	if !reply.Done || reply.Left <= 0 || reply.Left > 2000 {
+		t.Fatalf("被调方的时限应取自调用方的上下文: %+v", reply)
+	}
+
+	// 未指定上下文时以全局超时为准
+	reply = new(SlowResp)
+	if err := gate.Invoke(slow.Id(), "Slow.Work", &SlowReq{Ms: 1}, reply); err != nil {
+		t.Fatal(err)
+	}
+	<-s.started
+	if reply.Left <= 2000 {
+		t.Fatalf("被调方的时限应为全局超时: %+v", reply)
+	}
+}
+
+func TestInvokeCtxTimeout(t *testing.T) {
+	gate, slow, s := startSlow(t)
+
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond*150)
+	defer cancel()
+	begin := time.Now()
+	err := gate.Caller().InvokeCtx(c, slow.Id(), "Slow.Work", &SlowReq{Ms: 5000}, new(SlowResp))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应返回上下文超时: %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second*2 {
+		t.Fatalf("到期后未及时返回: %v", elapsed)
+	}
+	expectAborted(t, s, context.DeadlineExceeded, context.Canceled)
+}
+
+func TestInvokeCtxCancel(t *testing.T) {
+	gate, slow, s := startSlow(t)
+
+	c, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	gate.Caller().CallCtx(c, slow.Id(), "Queued.Work", &SlowReq{Ms: 5000}, new(SlowResp), func(err error) {
+		done <- err
+	})
+	<-s.started
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("应返回上下文取消: %v", err)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("取消后未及时返回")
+	}
+	expectAborted(t, s, context.Canceled)
+
+	// 已取消的上下文不再发出请求
+	if err := gate.Caller().InvokeCtx(c, slow.Id(), "Slow.Work", &SlowReq{Ms: 1}, new(SlowResp)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("应返回上下文取消: %v", err)
+	}
+	select {
+	case <-s.started:
+		t.Fatal("已取消的请求不应被处理")
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+// 被调方因自身时限到期而中止时不应答，调用方按全局超时返回
+func TestInvokeGlobalTimeout(t *testing.T) {
+	withTimeout(t, 150)
+	gate, slow, s := startSlow(t)
+
+	err := gate.Invoke(slow.Id(), "Slow.Work", &SlowReq{Ms: 5000}, new(SlowResp))
+	if err == nil || peers.IsRemoteError(err) || !strings.Contains(err.Error(), "超时") {
+		t.Fatalf("应返回请求超时: %v", err)
+	}
+	expectAborted(t, s, context.DeadlineExceeded)
+}
+
+// 排队期间已超时的请求被直接丢弃
+func TestInvokeCtxExpiredInQueue(t *testing.T) {
+	gate, slow, s := startSlow(t)
+
+	busy := make(chan error, 1)
+	gate.Caller().Call(slow.Id(), "Queued.Work", &SlowReq{Ms: 500}, new(SlowResp), func(err error) {
+		busy <- err
+	})
+	<-s.started
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if err := gate.Caller().InvokeCtx(c, slow.Id(), "Queued.Work", &SlowReq{Ms: 1}, new(SlowResp)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应返回上下文超时: %v", err)
+	}
+	if err := <-busy; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ms := <-s.started:
+		t.Fatalf("已超时的请求不应被处理: %d", ms)
+	case <-time.After(time.Millisecond * 200):
+	}
+}
+
+func TestInvokeCtxLocal(t *testing.T) {
+	_, slow, s := startSlow(t)
+
+	p, ok := slow.Hub().GetPeer("Slow")
+	if !ok {
+		t.Fatal("未找到Peer")
+	}
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	if err := p.InvokeCtx(c, slow.Id(), "Queued.Work", &SlowReq{Ms: 5000}, new(SlowResp)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("应返回上下文超时: %v", err)
+	}
+	<-s.started
+	expectAborted(t, s, context.DeadlineExceeded, context.Canceled)
+}
@@ -1,63 +1,148 @@
 package peers
 
 import (
-	"encoding/gob"
+	"context"
+	"encoding/binary"
 	"fmt"
 	"reflect"
+	"sort"
 
-	"github.com/silvernodes/silvernode-go/ctx"
 	"github.com/silvernodes/silvernode-go/peers/proc"
+	"github.com/silvernodes/silvernode-go/trace"
 	"github.com/silvernodes/silvernode-go/utils/buffutil"
 	"github.com/silvernodes/silvernode-go/utils/errutil"
 )
 
+const (
+	retCancel       byte = 2 // 调用方放弃请求，通知被调方中止处理
+	retStreamData   byte = 3 // 流消息
+	retStreamCredit byte = 4 // 流量额度，对端读取消息后归还
+	retStreamClose  byte = 5 // 结束发送(半关闭)
+	retStreamEnd    byte = 6 // 被调方处理结束，流随之关闭，Err为处理结果
+)
+
+// 交互数据头格式
+// 旧版 |--- From ---|--- To ---|--- Func ---|--- Seq ---|--- Ret ---|--- Err ---|--- body ---
+// V1   |--- mark 4bytes ---|--- version 1byte ---|--- 旧版各字段 ---|--- TimeLeft ---|--- Metadata ---|--- body ---
+// V2   |--- V1各字段(不含body) ---|--- Stream ---|--- Credit ---|--- body ---
+// 旧版首4字节为From的长度(非负)，V1以负数标记区分，新节点可同时解析各版本格式
+// 仅流的交互数据使用V2，其余仍以V1发送，不影响与V1节点共存
+const (
+	EXCHANGE_MARK    int32 = -0x21676f76 // 旧版节点将其视为非法的字符串长度并丢弃
+	EXCHANGE_VERSION byte  = 2
+	MAX_METADATA     int32 = 256 // 单次交互附带的元数据条数上限
+)
+
 type exchange struct {
-	From  string
-	To    string
-	Func  string
-	Seq   int64
-	Ret   byte
-	Err   string
-	Datas []byte
-
-	args   interface{}
-	parser *buffutil.Parser
+	Version  byte
+	From     string
+	To       string
+	Func     string
+	Seq      int64
+	Ret      byte
+	Err      string
+	TimeLeft int64    // 请求剩余时限(毫秒)，0为不限；以相对值传递以规避节点间的时钟偏差
+	Metadata Metadata // 随请求传递的键值对，如链路追踪id、用户id、语言等
+	Stream   int64    // 流id，发起方发出的为正，被调方发出的取负，非流为0
+	Credit   int32    // 流量额度帧归还的消息数
+	Datas    []byte
+
+	args    interface{}
+	parser  *buffutil.Parser
+	callCtx context.Context
+	cancel  context.CancelFunc
+	span    *trace.Span
+	stream  *stream // 被调方为流请求建立的流
+}
+
+// 事件不等待应答，也不建立流
+func (e *exchange) oneway() bool {
+	return e.Seq == 0 && e.Stream == 0
 }
 
 func (e *exchange) Marshal(node string, capacity int) ([]byte, error) {
-	buffer := buffutil.NewBuffer(capacity).
-		WriteString(e.From).
+	buffer := buffutil.NewBuffer(capacity)
+	version := byte(1)
+	if e.Stream != 0 {
+		version = 2
+	}
+	if !_setup.LegacyExchange {
+		buffer.WriteInt(EXCHANGE_MARK).
+			WriteByte(version)
+	} else if e.Stream != 0 {
+		return nil, errutil.New("旧版交互数据格式不支持流")
+	}
+	buffer.WriteString(e.From).
 		WriteString(e.To).
 		WriteString(e.Func).
 		WriteLong(e.Seq).
 		WriteByte(e.Ret).
 		WriteString(e.Err)
+	if !_setup.LegacyExchange {
+		if len(e.Metadata) > int(MAX_METADATA) { // 对端将拒绝解析，提前报错
+			return nil, errutil.New(fmt.Sprintf("元数据条数超出上限:%d", len(e.Metadata)))
+		}
+		buffer.WriteLong(e.TimeLeft)
+		keys := make([]string, 0, len(e.Metadata))
+		for k := range e.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buffer.WriteInt(int32(len(keys)))
+		for _, k := range keys {
+			buffer.WriteString(k).WriteString(e.Metadata[k])
+		}
+		if version >= 2 {
+			buffer.WriteLong(e.Stream).WriteInt(e.Credit)
+		}
+	}
 	if buffer.Error() != nil {
 		return nil, errutil.Extend("交互数据头序列化出错", buffer.Error())
 	}
-	if codec, b := getCodec(node); b {
-		data, err := codec.Encode(e.args)
-		if err != nil {
-			return nil, errutil.Extend("交互数据体序列化出错", err)
-		}
-		buffer.WriteBytes(data)
-	} else {
-		enc := gob.NewEncoder(buffer.Buf())
-		if err := enc.Encode(e.args); err != nil {
-			return nil, errutil.Extend("交互数据体序列化出错", err)
-		}
+	if e.args == nil { // 错误应答、取消通知及流的控制帧不携带数据体
+		return buffer.Flush()
+	}
+	data, err := getCodec(node).Encode(e.args)
+	if err != nil {
+		return nil, errutil.Extend("交互数据体序列化出错", err)
 	}
+	buffer.WriteBytes(data)
 	return buffer.Flush()
 }
 
 func (e *exchange) Unmarshal(data []byte) error {
 	parser := buffutil.NewParser(data, 0)
+	if len(data) >= 4 && int32(binary.LittleEndian.Uint32(data)) == EXCHANGE_MARK {
+		parser.ReadInt()
+		e.Version = parser.ReadByte()
+		if e.Version > EXCHANGE_VERSION {
+			return errutil.New(fmt.Sprintf("不支持的交互数据版本:%d", e.Version))
+		}
+	}
 	e.From = parser.ReadString()
 	e.To = parser.ReadString()
 	e.Func = parser.ReadString()
 	e.Seq = parser.ReadLong()
 	e.Ret = parser.ReadByte()
 	e.Err = parser.ReadString()
+	if e.Version >= 1 {
+		e.TimeLeft = parser.ReadLong()
+		count := parser.ReadInt()
+		if count < 0 || count > MAX_METADATA {
+			return errutil.New(fmt.Sprintf("交互数据头元数据条数非法:%d", count))
+		}
+		if count > 0 {
+			e.Metadata = make(Metadata, count)
+			for i := int32(0); i < count; i++ {
+				k := parser.ReadString()
+				e.Metadata[k] = parser.ReadString()
+			}
+		}
+	}
+	if e.Version >= 2 {
+		e.Stream = parser.ReadLong()
+		e.Credit = parser.ReadInt()
+	}
 	if parser.Error() != nil {
 		return errutil.Extend("交互数据头反序列化出错", parser.Error())
 	}
@@ -65,7 +150,7 @@ func (e *exchange) Unmarshal(data []byte) error {
 	return nil
 }
 
-func (e *exchange) FetchArgv(nodeId string, mtype *proc.MethodType, ctx interface{}) (reflect.Value, error) {
+func (e *exchange) FetchArgv(hub *Hub, nodeId string, mtype *proc.MethodType, ctx interface{}) (reflect.Value, error) {
 	var argv reflect.Value
 	if e.args == nil { // 远端
 		argIsValue := false // if true, need to indirect before calling.
@@ -75,7 +160,7 @@ func (e *exchange) FetchArgv(nodeId string, mtype *proc.MethodType, ctx interfac
 			argv = reflect.New(mtype.ArgType)
 			argIsValue = true
 		}
-		if err := e.FetchArgs(nodeId, argv.Interface()); err != nil {
+		if err := e.FetchArgs(hub, nodeId, argv.Interface()); err != nil {
 			return argv, err
 		}
 		if argIsValue {
@@ -119,9 +204,9 @@ func (e *exchange) FetchArgv(nodeId string, mtype *proc.MethodType, ctx interfac
 	return argv, nil
 }
 
-func (e *exchange) FetchReplyv(nodeId string, mtype *proc.MethodType) reflect.Value {
-	if nodeId == ctx.GetNodeId() && e.Seq != 0 {
-		if p, exists := getpeer(e.From); exists {
+func (e *exchange) FetchReplyv(hub *Hub, nodeId string, mtype *proc.MethodType) reflect.Value {
+	if nodeId == hub.node.NodeId() && e.Seq != 0 {
+		if p, exists := hub.getpeer(e.From); exists {
 			if call, b := p.getCall(e.Seq); b {
 				return reflect.ValueOf(call.Reply)
 			}
@@ -138,31 +223,23 @@ func (e *exchange) FetchReplyv(nodeId string, mtype *proc.MethodType) reflect.Va
 
 }
 
-func (e *exchange) FetchArgs(node string, token interface{}) error {
+func (e *exchange) FetchArgs(hub *Hub, node string, token interface{}) error {
 	if e.parser == nil {
 		return errutil.New("交互数据头反序列化尚未完成")
 	}
-	if codec, b := getCodec(node); b {
-		err := codec.Decode(e.parser.Buf().Bytes(), token)
-		if err != nil {
-			return errutil.Extend("交互数据体反序列化出错", err)
-		}
-	} else {
-		dec := gob.NewDecoder(e.parser.Buf())
-		if err := dec.Decode(token); err != nil {
-			return errutil.Extend("交互数据体反序列化出错", err)
-		}
+	if err := getCodec(node).Decode(e.parser.Buf().Bytes(), token); err != nil {
+		return errutil.Extend("交互数据体反序列化出错", err)
 	}
 	e.args = token
-	e.PrintInfo(node, false)
+	e.PrintInfo(hub.node.NodeId(), node, false)
 	return nil
 }
 
-func (e *exchange) PrintInfo(node string, sender bool) {
+func (e *exchange) PrintInfo(self string, node string, sender bool) {
 	if _setup.OnMonitor == nil {
 		return
 	}
-	s := ctx.GetNodeId()
+	s := self
 	r := node
 	argStr := fmt.Sprint(e.args)
 	if !sender {
@@ -203,12 +280,41 @@ type ExChangeMessage struct {
 	Err      string
 }
 
+// 为参数中标记了auto:"context"的context.Context字段注入本次请求的上下文
+// 请求超时或被调用方放弃时该上下文将被取消，被调方可据此提前中止处理
+func autoWiredContext(args interface{}, c context.Context) {
+	argv := reflect.ValueOf(args)
+	if c == nil || argv.Kind() != reflect.Ptr || argv.IsNil() || argv.Elem().Kind() != reflect.Struct {
+		return
+	}
+	argt := argv.Type().Elem()
+	for index := 0; index < argt.NumField(); index++ {
+		field := argt.Field(index)
+		if field.Tag.Get("auto") == "context" && field.Type == typeOfContext {
+			argv.Elem().Field(index).Set(reflect.ValueOf(c))
+		}
+	}
+}
+
+var typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+
 type callFunc struct {
-	Method  string
-	Args    interface{}
-	Reply   interface{}
-	Error   error
-	Done    func(error)
-	Chan    chan error
-	TimeOut int64
+	Method string
+	Args   interface{}
+	Reply  interface{}
+	Error  error
+	Done   func(error)
+
+	seq   int64
+	node  string
+	to    string
+	timer int64         // 时间轮中的超时任务
+	over  chan struct{} // 调用结束时关闭，供监听上下文取消的协程退出
+}
+
+func (c *callFunc) finish(err error) {
+	if c.over != nil {
+		close(c.over)
+	}
+	c.Done(err)
 }
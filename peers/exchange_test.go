@@ -0,0 +1,219 @@
+package peers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/silvernodes/silvernode-go/utils/buffutil"
+)
+
+type exchangeArgs struct {
+	Text  string
+	Count int
+}
+
+func withLegacyExchange(t *testing.T, legacy bool) {
+	old := _setup.LegacyExchange
+	_setup.LegacyExchange = legacy
+	t.Cleanup(func() {
+		_setup.LegacyExchange = old
+	})
+}
+
+func roundTrip(t *testing.T, e *exchange) (*exchange, []byte) {
+	t.Helper()
+	data, err := e.Marshal("logic#1", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(exchange)
+	if err := got.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	return got, data
+}
+
+func header(e *exchange) exchange {
+	return exchange{Version: e.Version, From: e.From, To: e.To, Func: e.Func, Seq: e.Seq, Ret: e.Ret, Err: e.Err,
+		TimeLeft: e.TimeLeft, Metadata: e.Metadata, Stream: e.Stream, Credit: e.Credit}
+}
+
+func TestExchangeV1(t *testing.T) {
+	withLegacyExchange(t, false)
+	cases := []struct {
+		name string
+		e    *exchange
+	}{
+		{"request", &exchange{From: "Client", To: "Room", Func: "Join", Seq: 7, TimeLeft: 1500,
+			args: &exchangeArgs{Text: "hi", Count: 3}}},
+		{"event", &exchange{From: "Client", To: "Room", Func: "Leave", args: &exchangeArgs{Text: "bye"}}},
+		{"reply", &exchange{From: "Room", To: "Client", Func: "7", Seq: 7, Ret: 1, args: &exchangeArgs{Count: 1}}},
+		{"error", &exchange{From: "Room", To: "Client", Func: "7", Seq: 7, Ret: 1, Err: "房间已满"}},
+		{"cancel", &exchange{From: "Client", To: "Room", Func: "Join", Seq: 7, Ret: retCancel}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, data := roundTrip(t, c.e)
+			if int32(binary.LittleEndian.Uint32(data)) != EXCHANGE_MARK || data[4] != 1 {
+				t.Fatalf("应以V1格式发送: %x", data[:5])
+			}
+			want := header(c.e)
+			want.Version = 1
+			if !reflect.DeepEqual(header(got), want) {
+				t.Fatalf("数据头不符: got %+v, want %+v", header(got), want)
+			}
+			if c.e.args == nil {
+				if got.parser.Buf().Len() != 0 {
+					t.Fatalf("不应携带数据体: %d", got.parser.Buf().Len())
+				}
+				return
+			}
+			args := new(exchangeArgs)
+			if err := getCodec("logic#1").Decode(got.parser.Buf().Bytes(), args); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, c.e.args) {
+				t.Fatalf("数据体不符: %+v", args)
+			}
+		})
+	}
+}
+
+// 旧版节点发出及开启LegacyExchange后发出的数据均为旧版格式
+func TestExchangeLegacy(t *testing.T) {
+	withLegacyExchange(t, true)
+	e := &exchange{From: "Client", To: "Room", Func: "Join", Seq: 7, TimeLeft: 1500, args: &exchangeArgs{Text: "hi"}}
+	got, data := roundTrip(t, e)
+	if size := int32(binary.LittleEndian.Uint32(data)); size != int32(len(e.From)) {
+		t.Fatalf("旧版格式应以From的长度开头: %d", size)
+	}
+	if got.Version != 0 || got.TimeLeft != 0 || got.From != e.From || got.Func != e.Func || got.Seq != e.Seq {
+		t.Fatalf("数据头不符: %+v", header(got))
+	}
+
+	// 按旧版节点的写法逐字段编码
+	buffer := buffutil.NewBuffer(64)
+	buffer.WriteString("Room").WriteString("Client").WriteString("7").WriteLong(7).WriteByte(1).WriteString("房间已满")
+	legacy, err := buffer.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	withLegacyExchange(t, false)
+	got = new(exchange)
+	if err := got.Unmarshal(legacy); err != nil {
+		t.Fatal(err)
+	}
+	want := exchange{From: "Room", To: "Client", Func: "7", Seq: 7, Ret: 1, Err: "房间已满"}
+	if !reflect.DeepEqual(header(got), want) {
+		t.Fatalf("数据头不符: got %+v, want %+v", header(got), want)
+	}
+}
+
+func TestExchangeRejects(t *testing.T) {
+	withLegacyExchange(t, false)
+	data, err := (&exchange{From: "Client", To: "Room", Func: "Join", Seq: 7, TimeLeft: 100}).Marshal("logic#1", 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := bytes.Clone(data)
+	future[4] = EXCHANGE_VERSION + 1
+	cases := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"unknown version", future, "版本"},
+		{"truncated", data[:len(data)-6], "反序列化"},
+		{"mark only", data[:5], "反序列化"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := new(exchange).Unmarshal(c.data)
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("期望包含%q的错误, 实际为%v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestExchangeMetadata(t *testing.T) {
+	withLegacyExchange(t, false)
+	md := NewMetadata("user", "u-9", "lang", "zh", "empty", "")
+	e := &exchange{From: "Client", To: "Room", Func: "Join", Seq: 7, TimeLeft: 100, Metadata: md, args: &exchangeArgs{Text: "hi"}}
+	got, data := roundTrip(t, e)
+	if !reflect.DeepEqual(got.Metadata, md) {
+		t.Fatalf("元数据不符: %v", got.Metadata)
+	}
+	args := new(exchangeArgs)
+	if err := getCodec("logic#1").Decode(got.parser.Buf().Bytes(), args); err != nil || args.Text != "hi" {
+		t.Fatalf("数据体不符: %+v %v", args, err)
+	}
+	for i := 0; i < 10; i++ { // 按键排序写入，结果固定
+		again, _ := e.Marshal("logic#1", 64)
+		if !bytes.Equal(again, data) {
+			t.Fatal("同一请求的序列化结果不一致")
+		}
+	}
+
+	full := make(Metadata, MAX_METADATA+1)
+	for i := int32(0); i <= MAX_METADATA; i++ {
+		full[string(rune('a'+i%26))+string(rune('0'+i/26))] = "v"
+	}
+	if _, err := (&exchange{From: "Client", To: "Room", Func: "Join", Metadata: full}).Marshal("logic#1", 64); err == nil {
+		t.Fatal("元数据条数超出上限时应返回错误")
+	}
+	delete(full, "a0")
+	if got, _ := roundTrip(t, &exchange{From: "Client", To: "Room", Func: "Join", Metadata: full}); len(got.Metadata) != int(MAX_METADATA) {
+		t.Fatalf("元数据条数不符: %d", len(got.Metadata))
+	}
+
+	// 对端发来的元数据条数非法
+	buffer := buffutil.NewBuffer(64)
+	buffer.WriteInt(EXCHANGE_MARK).WriteByte(1).WriteString("Client").WriteString("Room").WriteString("Join").
+		WriteLong(7).WriteByte(0).WriteString("").WriteLong(100).WriteInt(MAX_METADATA + 1)
+	bad, _ := buffer.Flush()
+	if err := new(exchange).Unmarshal(bad); err == nil || !strings.Contains(err.Error(), "元数据") {
+		t.Fatalf("期望元数据条数非法的错误, 实际为%v", err)
+	}
+
+	// 旧版格式不携带元数据
+	withLegacyExchange(t, true)
+	if got, _ := roundTrip(t, e); got.Metadata != nil {
+		t.Fatalf("旧版格式不应携带元数据: %v", got.Metadata)
+	}
+}
+
+// 仅流的交互数据以V2发送
+func TestExchangeStream(t *testing.T) {
+	withLegacyExchange(t, false)
+	cases := []struct {
+		name string
+		e    *exchange
+	}{
+		{"open", &exchange{From: "Client", To: "Match", Func: "Replay", TimeLeft: 100, Stream: 42, args: &exchangeArgs{Count: 3}}},
+		{"data", &exchange{From: "Match", To: "Client", Stream: -42, Ret: retStreamData, args: &exchangeArgs{Text: "frame"}}},
+		{"credit", &exchange{From: "Client", To: "Match", Stream: 42, Ret: retStreamCredit, Credit: 16}},
+		{"end", &exchange{From: "Match", To: "Client", Stream: -42, Ret: retStreamEnd, Err: "回放中断"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, data := roundTrip(t, c.e)
+			if data[4] != 2 {
+				t.Fatalf("应以V2格式发送: %d", data[4])
+			}
+			want := header(c.e)
+			want.Version = 2
+			if !reflect.DeepEqual(header(got), want) {
+				t.Fatalf("数据头不符: got %+v, want %+v", header(got), want)
+			}
+		})
+	}
+
+	withLegacyExchange(t, true)
+	if _, err := cases[0].e.Marshal("logic#1", 64); err == nil {
+		t.Fatal("旧版格式不应支持流")
+	}
+}
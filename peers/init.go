@@ -1,10 +1,12 @@
 package peers
 
 import (
+	"context"
+
 	silvernode "github.com/silvernodes/silvernode-go"
+	"github.com/silvernodes/silvernode-go/board"
 	"github.com/silvernodes/silvernode-go/process"
 	"github.com/silvernodes/silvernode-go/utils/errutil"
-	"github.com/silvernodes/silvernode-go/utils/timeutil"
 )
 
 type Peer interface {
@@ -13,19 +15,34 @@ type Peer interface {
 	Processor() process.Processor
 	Invoke(node string, method string, args interface{}, reply interface{}) error
 	Call(node string, method string, args interface{}, reply interface{}, done func(error))
+	InvokeCtx(c context.Context, node string, method string, args interface{}, reply interface{}) error
+	CallCtx(c context.Context, node string, method string, args interface{}, reply interface{}, done func(error))
+	InvokeAny(name string, method string, args interface{}, reply interface{}) error
+	CallAny(name string, method string, args interface{}, reply interface{}, done func(error))
+	InvokeAnyCtx(c context.Context, name string, method string, args interface{}, reply interface{}) error
+	CallAnyCtx(c context.Context, name string, method string, args interface{}, reply interface{}, done func(error))
+	Broadcast(name string, method string, args interface{}) error
+	BroadcastCtx(c context.Context, name string, method string, args interface{}) error
+	Gather(name string, method string, args interface{}, newReply func() interface{}) []*GatherResult
+	GatherCtx(c context.Context, name string, method string, args interface{}, newReply func() interface{}) []*GatherResult
+	OpenStream(c context.Context, node string, method string, args interface{}) (Stream, error)
 	SendEvent(node string, method string, args interface{}) error
+	SendEventCtx(c context.Context, node string, method string, args interface{}) error
 }
 
 type SetupParam struct {
 	Timeout   int
 	OnPreProc func(nodeId string, peerNick string, funcName string) (interface{}, error)
 	OnMonitor func(info *ExChangeMessage)
+	// 以旧版格式发送交互数据(不含时限及元数据)，供滚动升级期间与旧版节点共存
+	// 新节点可同时解析两种格式，待全部节点升级后关闭即可
+	LegacyExchange bool
 }
 
 var _setup *SetupParam
 
 func init() {
-	_peers = make(map[string]*peer)
+	_hub = NewHub(silvernode.Default())
 	_setup = new(SetupParam)
 	_setup.Timeout = 15000
 	_setup.OnPreProc = func(nodeId string, peerNick string, funcName string) (interface{}, error) {
@@ -45,34 +62,42 @@ func Setup(param *SetupParam) {
 	if param.OnMonitor != nil {
 		_setup.OnMonitor = param.OnMonitor
 	}
+	if param.LegacyExchange {
+		_setup.LegacyExchange = true
+	}
 }
 
 func Boot() {
-	silvernode.BindPipeline(&silvernode.Pipeline{
+	_hub.Boot()
+}
+
+// 将Hub接入所属节点的流水线，并启用超时检测
+func (h *Hub) Boot() {
+	h.node.BindPipeline(&silvernode.Pipeline{
 		OnMessage: func(nodeId string, msg interface{}) error {
 			datas, ok := msg.([]byte)
 			if !ok {
 				return errutil.New("Peer接受来自OnInBound数据格式必须为[]byte")
 			}
-			return onExchange(nodeId, datas)
+			return h.onExchange(nodeId, datas)
 		},
 	})
+	h.node.AddShutdownHook(h.drain)
+	h.wheel.Start()
 	go func() {
-		for {
-			timeutil.Wait(7)
-			loopCheck()
+		panel := ""
+		select {
+		case <-h.node.Ready(): // 节点id于启动时确定
+			panel = "熔断器[" + h.node.NodeId() + "]"
+			board.Register(panel, func() interface{} {
+				return h.Breakers()
+			})
+		case <-h.node.Done():
 		}
-	}()
-}
-
-func loopCheck() {
-	_lock.RLock()
-	defer _lock.RUnlock()
-
-	for _, peer := range _peers {
-		if peer.disposing {
-			continue
+		<-h.node.Done() // 节点关闭后停止检测
+		h.wheel.Stop()
+		if panel != "" {
+			board.Unregister(panel)
 		}
-		peer.checkTimeOut()
-	}
+	}()
 }
@@ -0,0 +1,120 @@
+package peers
+
+import (
+	"context"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/trace"
+)
+
+// 一次发出的Peer请求，由拦截器链逐层传递后发送
+type Request struct {
+	Context context.Context // 请求上下文，其截止时间将传递至被调方
+	From    string          // 发起请求的Peer昵称
+	Node    string          // 目标节点
+	Method  string          // 形如PeerNick.FuncName
+	Args    interface{}     // 请求参数
+	Reply   interface{}     // 应答结构，事件为nil；done回调时已填充应答内容
+
+	peer *peer
+}
+
+// 发送请求，请求结束(收到应答、失败或事件发送完毕)后回调done，且仅回调一次
+type Invoker func(req *Request, done func(error))
+
+// 客户端拦截器，可修改或观察发出的请求及其应答，用于重试、对冲、附加信息及统计等
+// Invoke、Call、SendEvent及本节点内的调用均经过拦截器链
+type Interceptor func(next Invoker) Invoker
+
+// 追加拦截器，先追加者位于外层
+func (h *Hub) Intercept(interceptors ...Interceptor) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.interceptors = append(h.interceptors, interceptors...)
+	invoker := Invoker(sendRequest)
+	for i := len(h.interceptors) - 1; i >= 0; i-- {
+		invoker = h.interceptors[i](invoker)
+	}
+	h.invoker = invoker
+}
+
+func (h *Hub) intercept(req *Request, done func(error)) {
+	h.lock.RLock()
+	invoker := h.invoker
+	h.lock.RUnlock()
+	invoker(req, done)
+}
+
+func Intercept(interceptors ...Interceptor) {
+	_hub.Intercept(interceptors...)
+}
+
+// 拦截器链末端，经熔断器检查后发出请求，幂等方法失败时按重试策略重试
+func sendRequest(req *Request, done func(error)) {
+	if req.Reply == nil { // 事件不等待应答，无从判定目标节点是否可用
+		sendOnce(req, done)
+		return
+	}
+	hub := req.peer.hub
+	retry := hub.retryPolicy(req)
+	attempts := 0
+	var try func()
+	try = func() {
+		attempts++
+		b := hub.getBreaker(req.Node)
+		if b != nil && !b.allow() {
+			_breakerRejects.WithLabelValues(hub.node.NodeId(), req.Node).Inc()
+			done(errBreakerOpen(req.Node))
+			return
+		}
+		start := time.Now()
+		sendOnce(req, func(err error) {
+			if b != nil {
+				b.record(breakerFailed(err), time.Since(start))
+			}
+			if err != nil && retry != nil && attempts < retry.Attempts && !IsRemoteError(err) && req.Context.Err() == nil {
+				_retries.WithLabelValues(hub.node.NodeId(), req.Method).Inc()
+				hub.scheduleRetry(req, retry.delay(attempts), try, done)
+				return
+			}
+			done(err)
+		})
+	}
+	try()
+}
+
+// 发出一次请求；每次发出均对应一个Span，重试等产生的多次请求可分别追踪
+func sendOnce(req *Request, done func(error)) {
+	kind := trace.KIND_CLIENT
+	if req.Reply == nil {
+		kind = trace.KIND_PRODUCER
+	}
+	c, span := trace.Start(req.Context, req.peer.hub.node.Name(), req.Method, kind)
+	if span != nil {
+		span.SetAttribute("rpc.system", "silvernode")
+		span.SetAttribute("peer.node", req.Node)
+		finish := done
+		done = func(err error) {
+			span.SetError(err)
+			span.End()
+			finish(err)
+		}
+	}
+	if req.Reply != nil {
+		hub := req.peer.hub
+		hub.addInflight(req.Node, 1)
+		finish := done
+		done = func(err error) {
+			hub.addInflight(req.Node, -1)
+			finish(err)
+		}
+	}
+	if _, err := req.peer.request(c, req.Node, req.Method, req.Args, req.Reply, done); err != nil {
+		done(err)
+		return
+	}
+	if req.Reply == nil { // 事件发出即结束
+		done(nil)
+	}
+}
@@ -0,0 +1,172 @@
+package peers_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 记录请求发出及结束的拦截器，next之前追加stage以检查替换的上下文是否随请求发出
+func recordInterceptor(tr *trail, name string) peers.Interceptor {
+	return func(next peers.Invoker) peers.Invoker {
+		return func(req *peers.Request, done func(error)) {
+			tr.add("%s>%s@%s(%s)", name, req.Method, ctx.GetNodeNameFromId(req.Node), stage(req.Context))
+			req.Context = peers.AppendMetadata(req.Context, "stage", name)
+			next(req, func(err error) {
+				switch {
+				case err != nil:
+					tr.add("<%s(%v)", name, err)
+				case req.Reply != nil:
+					tr.add("<%s(%s)", name, req.Reply.(*ChainResp).Text)
+				default:
+					tr.add("<%s", name)
+				}
+				done(err)
+			})
+		}
+	}
+}
+
+// 除proc外的执行记录，事件发出即结束，与proc的执行先后不定
+func withoutProc(steps string) (string, bool) {
+	kept := make([]string, 0)
+	found := false
+	for _, step := range strings.Fields(steps) {
+		if strings.HasPrefix(step, "proc(") {
+			found = step == "proc(alice/B)"
+			continue
+		}
+		kept = append(kept, step)
+	}
+	return strings.Join(kept, " "), found
+}
+
+func TestInterceptorChain(t *testing.T) {
+	gate, logic, tr := startGuarded(t, func(tr *trail) []peers.Middleware {
+		return nil
+	})
+	for _, n := range []*silvernodetest.Node{gate, logic} {
+		n.Hub().Intercept(recordInterceptor(tr, "A"), recordInterceptor(tr, "B"))
+	}
+	c := peers.WithMetadata(context.Background(), peers.NewMetadata("user", "alice"))
+	want := "A>Guarded.Echo@logic(alice/) B>Guarded.Echo@logic(alice/A) proc(alice/B) <B(echo:hi) <A(echo:hi)"
+
+	t.Run("Invoke", func(t *testing.T) {
+		reply := new(ChainResp)
+		if err := gate.Caller().InvokeCtx(c, logic.Id(), "Guarded.Echo", &ChainReq{Text: "hi"}, reply); err != nil {
+			t.Fatal(err)
+		}
+		if got := tr.take(); got != want {
+			t.Fatalf("执行顺序不符:\n got %s\nwant %s", got, want)
+		}
+	})
+	t.Run("Call", func(t *testing.T) {
+		reply := new(ChainResp)
+		finished := make(chan error, 1)
+		gate.Caller().CallCtx(c, logic.Id(), "Guarded.Echo", &ChainReq{Text: "hi"}, reply, func(err error) {
+			finished <- err
+		})
+		select {
+		case err := <-finished:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(silvernodetest.DefaultWait):
+			t.Fatal("等待应答超时")
+		}
+		if got := tr.take(); got != want {
+			t.Fatalf("执行顺序不符:\n got %s\nwant %s", got, want)
+		}
+	})
+	t.Run("Local", func(t *testing.T) {
+		reply := new(ChainResp)
+		if err := logic.Caller().InvokeCtx(c, logic.Id(), "Guarded.Echo", &ChainReq{Text: "hi"}, reply); err != nil {
+			t.Fatal(err)
+		}
+		if got := tr.take(); got != want {
+			t.Fatalf("执行顺序不符:\n got %s\nwant %s", got, want)
+		}
+	})
+	t.Run("SendEvent", func(t *testing.T) {
+		if err := gate.Caller().SendEventCtx(c, logic.Id(), "Guarded.Notify", &ChainReq{}); err != nil {
+			t.Fatal(err)
+		}
+		got, found := withoutProc(waitTrail(t, tr, 5))
+		if want := "A>Guarded.Notify@logic(alice/) B>Guarded.Notify@logic(alice/A) <B <A"; got != want || !found {
+			t.Fatalf("执行顺序不符:\n got %s\nwant %s", got, want)
+		}
+	})
+}
+
+// 拦截器不调用next时请求不会发出，以其回调的错误结束
+func TestInterceptorShortCircuit(t *testing.T) {
+	gate, logic, tr := startGuarded(t, func(tr *trail) []peers.Middleware {
+		return nil
+	})
+	gate.Hub().Intercept(recordInterceptor(tr, "A"), func(next peers.Invoker) peers.Invoker {
+		return func(req *peers.Request, done func(error)) {
+			if md, _ := peers.MetadataFrom(req.Context); md.Get("user") == "" {
+				done(errutil.New("未登录"))
+				return
+			}
+			next(req, done)
+		}
+	}, recordInterceptor(tr, "B"))
+
+	reply := new(ChainResp)
+	if err := gate.Caller().Invoke(logic.Id(), "Guarded.Echo", &ChainReq{Text: "hi"}, reply); err == nil || err.Error() != "未登录" {
+		t.Fatalf("应返回拦截器的错误: %v", err)
+	}
+	if err := gate.Caller().SendEvent(logic.Id(), "Guarded.Notify", &ChainReq{}); err == nil || err.Error() != "未登录" {
+		t.Fatalf("应返回拦截器的错误: %v", err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if got, want := tr.take(), "A>Guarded.Echo@logic(/) <A(未登录) A>Guarded.Notify@logic(/) <A(未登录)"; got != want {
+		t.Fatalf("执行顺序不符:\n got %s\nwant %s", got, want)
+	}
+}
+
+// 拦截器可多次调用next，如失败后重试
+func TestInterceptorRetry(t *testing.T) {
+	gate, logic, tr := startGuarded(t, func(tr *trail) []peers.Middleware {
+		return nil
+	})
+	var attempts int32
+	gate.Hub().Intercept(func(next peers.Invoker) peers.Invoker {
+		return func(req *peers.Request, done func(error)) {
+			next(req, func(err error) {
+				if err != nil {
+					next(req, done)
+					return
+				}
+				done(nil)
+			})
+		}
+	}, func(next peers.Invoker) peers.Invoker {
+		return func(req *peers.Request, done func(error)) {
+			if atomic.AddInt32(&attempts, 1) == 1 {
+				done(errutil.New("首次发送失败"))
+				return
+			}
+			next(req, done)
+		}
+	})
+
+	reply := new(ChainResp)
+	if err := gate.Caller().Invoke(logic.Id(), "Guarded.Echo", &ChainReq{Text: "hi"}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Text != "echo:hi" || atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("重试结果不符: %+v %d", reply, attempts)
+	}
+	if got := tr.take(); got != "proc(/)" {
+		t.Fatalf("proc应仅执行一次: %s", got)
+	}
+}
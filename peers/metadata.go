@@ -0,0 +1,56 @@
+package peers
+
+import (
+	"context"
+)
+
+// 随请求传递至被调方的键值对
+// 调用方通过上下文附加，被调方从请求上下文中读取；以该上下文继续发起的请求将沿用其中的元数据
+type Metadata map[string]string
+
+type metadataKey struct{}
+
+// 由键值对依次构成，个数为奇数时忽略最后一个
+func NewMetadata(kv ...string) Metadata {
+	md := make(Metadata, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		md[kv[i]] = kv[i+1]
+	}
+	return md
+}
+
+func (md Metadata) Get(k string) string {
+	return md[k]
+}
+
+func (md Metadata) Clone() Metadata {
+	ret := make(Metadata, len(md))
+	for k, v := range md {
+		ret[k] = v
+	}
+	return ret
+}
+
+// 以md替换上下文中的元数据
+func WithMetadata(c context.Context, md Metadata) context.Context {
+	return context.WithValue(c, metadataKey{}, md)
+}
+
+// 在上下文已有的元数据基础上追加键值对，不影响原上下文
+func AppendMetadata(c context.Context, kv ...string) context.Context {
+	md, _ := MetadataFrom(c)
+	md = md.Clone()
+	for k, v := range NewMetadata(kv...) {
+		md[k] = v
+	}
+	return WithMetadata(c, md)
+}
+
+// 读取上下文中的元数据，被调方可由中间件的Invocation.Context或参数中标记auto:"context"的字段获取
+func MetadataFrom(c context.Context) (Metadata, bool) {
+	if c == nil {
+		return nil, false
+	}
+	md, ok := c.Value(metadataKey{}).(Metadata)
+	return md, ok
+}
@@ -0,0 +1,156 @@
+package peers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+type MetaReq struct {
+	Ctx context.Context `auto:"context"`
+}
+
+type MetaResp struct {
+	User  string
+	Lang  string
+	Inner *MetaResp
+}
+
+func readMeta(c context.Context) *MetaResp {
+	md, _ := peers.MetadataFrom(c)
+	return &MetaResp{User: md.Get("user"), Lang: md.Get("lang")}
+}
+
+// Front读取元数据后以同一上下文调用back节点的Back
+type Front struct {
+	peer   peers.Peer
+	back   string
+	events chan *MetaResp
+	seen   chan string // 中间件读取到的user
+}
+
+func (f *Front) Hello(args *MetaReq, reply *MetaResp) error {
+	*reply = *readMeta(args.Ctx)
+	reply.Inner = new(MetaResp)
+	return f.peer.InvokeCtx(peers.AppendMetadata(args.Ctx, "lang", "en"), f.back, "Back.Hello", &MetaReq{}, reply.Inner)
+}
+
+func (f *Front) Notify(args *MetaReq) error {
+	f.events <- readMeta(args.Ctx)
+	return nil
+}
+
+type Back struct {
+}
+
+func (b *Back) Hello(args *MetaReq, reply *MetaResp) error {
+	*reply = *readMeta(args.Ctx)
+	return nil
+}
+
+func startChain(t *testing.T) (*silvernodetest.Node, *silvernodetest.Node, *Front) {
+	t.Helper()
+	front := &Front{events: make(chan *MetaResp, 16), seen: make(chan string, 16)}
+	c, gate := startGate(t, "front",
+		procSpec("back", func(n *silvernodetest.Node) interface{} {
+			return new(Back)
+		}),
+		&silvernodetest.NodeSpec{Name: "front", BackEnds: []string{"back"}, Init: func(n *silvernodetest.Node) error {
+			n.Hub().Use(func(next peers.Handler) peers.Handler {
+				return func(inv *peers.Invocation) error {
+					md, _ := peers.MetadataFrom(inv.Context)
+					front.seen <- md.Get("user")
+					return next(inv)
+				}
+			})
+			p, err := n.Hub().Register(front, nil)
+			front.peer = p
+			return err
+		}},
+	)
+	front.back = c.NodesByName("back")[0].Id()
+	return gate, c.NodesByName("front")[0], front
+}
+
+func TestMetadataPropagation(t *testing.T) {
+	gate, front, f := startChain(t)
+
+	c := peers.AppendMetadata(context.Background(), "user", "u-9", "lang", "zh")
+	reply := new(MetaResp)
+	if err := gate.Caller().InvokeCtx(c, front.Id(), "Front.Hello", &MetaReq{}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.User != "u-9" || reply.Lang != "zh" {
+		t.Fatalf("被调方读取的元数据不符: %+v", reply)
+	}
+	if reply.Inner == nil || reply.Inner.User != "u-9" || reply.Inner.Lang != "en" {
+		t.Fatalf("继续发起的调用应沿用并可追加元数据: %+v", reply.Inner)
+	}
+	if user := <-f.seen; user != "u-9" {
+		t.Fatalf("中间件读取的元数据不符: %q", user)
+	}
+	if md, _ := peers.MetadataFrom(c); md.Get("lang") != "zh" {
+		t.Fatalf("被调方的追加不应影响调用方: %v", md)
+	}
+
+	// 未附加元数据时被调方读取为空
+	reply = new(MetaResp)
+	if err := gate.Invoke(front.Id(), "Front.Hello", &MetaReq{}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.User != "" || reply.Inner.User != "" || reply.Inner.Lang != "en" {
+		t.Fatalf("不应读取到元数据: %+v %+v", reply, reply.Inner)
+	}
+}
+
+func TestMetadataEventAndLocal(t *testing.T) {
+	gate, front, f := startChain(t)
+
+	c := peers.AppendMetadata(context.Background(), "user", "u-9")
+	if err := gate.Caller().SendEventCtx(c, front.Id(), "Front.Notify", &MetaReq{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-f.events:
+		if got.User != "u-9" {
+			t.Fatalf("事件携带的元数据不符: %+v", got)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("等待事件超时")
+	}
+
+	// 本地调用由被调方持有元数据的副本
+	md := peers.NewMetadata("user", "local")
+	reply := new(MetaResp)
+	if err := f.peer.InvokeCtx(peers.WithMetadata(context.Background(), md), front.Id(), "Front.Hello", &MetaReq{}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.User != "local" || reply.Inner.User != "local" || reply.Inner.Lang != "en" {
+		t.Fatalf("本地调用的元数据不符: %+v %+v", reply, reply.Inner)
+	}
+	if len(md) != 1 {
+		t.Fatalf("调用方的元数据被修改: %v", md)
+	}
+}
+
+func TestMetadataHelpers(t *testing.T) {
+	if md := peers.NewMetadata("a", "1", "b"); len(md) != 1 || md.Get("a") != "1" {
+		t.Fatalf("个数为奇数时应忽略最后一个: %v", md)
+	}
+	if _, ok := peers.MetadataFrom(context.Background()); ok {
+		t.Fatal("未附加时不应读取到元数据")
+	}
+	parent := peers.AppendMetadata(context.Background(), "a", "1")
+	child := peers.AppendMetadata(parent, "a", "2", "b", "3")
+	pmd, _ := peers.MetadataFrom(parent)
+	cmd, _ := peers.MetadataFrom(child)
+	if len(pmd) != 1 || pmd.Get("a") != "1" {
+		t.Fatalf("追加不应影响原上下文: %v", pmd)
+	}
+	if len(cmd) != 2 || cmd.Get("a") != "2" || cmd.Get("b") != "3" {
+		t.Fatalf("追加结果不符: %v", cmd)
+	}
+}
@@ -0,0 +1,25 @@
+package peers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 熔断及重试的监控指标，随节点的/metrics接口导出
+var (
+	_breakerState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "silvernode_peer_breaker_state",
+		Help: "熔断器状态(0:closed 1:open 2:half-open)",
+	}, []string{"node", "target"})
+	_breakerRejects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "silvernode_peer_breaker_rejected_total",
+		Help: "因熔断被拒绝的请求数",
+	}, []string{"node", "target"})
+	_retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "silvernode_peer_retries_total",
+		Help: "幂等方法的重试次数",
+	}, []string{"node", "method"})
+)
+
+func init() {
+	prometheus.MustRegister(_breakerState, _breakerRejects, _retries)
+}
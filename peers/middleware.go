@@ -0,0 +1,68 @@
+package peers
+
+import (
+	"context"
+	"reflect"
+
+	_proc "github.com/silvernodes/silvernode-go/peers/proc"
+)
+
+// 一次Peer方法调用，由中间件链逐层传递至proc方法
+type Invocation struct {
+	NodeId  string          // 调用方节点
+	Peer    string          // 目标Peer昵称
+	Method  string          // 目标方法名
+	Args    interface{}     // 请求参数
+	Reply   interface{}     // 应答结构，事件为nil，流方法为Stream；方法返回后即为应答内容
+	Context context.Context // 请求上下文，替换后将注入参数中标记auto:"context"的字段
+
+	peer  *peer
+	mtype *_proc.MethodType
+}
+
+type Handler func(inv *Invocation) error
+
+// 服务端中间件，可在调用前后执行逻辑，或直接返回错误以拒绝调用
+type Middleware func(next Handler) Handler
+
+// 追加中间件，先追加者位于外层；反射及ProcMeta两种调用方式均经过中间件链
+func (h *Hub) Use(middlewares ...Middleware) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.middlewares = append(h.middlewares, middlewares...)
+	handler := Handler(invokeProc)
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		handler = h.middlewares[i](handler)
+	}
+	h.handler = handler
+}
+
+func (h *Hub) handle(inv *Invocation) error {
+	h.lock.RLock()
+	handler := h.handler
+	h.lock.RUnlock()
+	return handler(inv)
+}
+
+func Use(middlewares ...Middleware) {
+	_hub.Use(middlewares...)
+}
+
+// 中间件链末端，调用proc方法
+func invokeProc(inv *Invocation) error {
+	p := inv.peer
+	autoWiredContext(inv.Args, inv.Context)
+	if p.meta != nil && !inv.mtype.Stream {
+		return p.meta.ProcessFlow(inv.Method, p.Proc(), inv.Args, inv.Reply)
+	}
+	in := []reflect.Value{p.proc, reflect.ValueOf(inv.Args)}
+	if inv.Reply != nil {
+		in = append(in, reflect.ValueOf(inv.Reply))
+	}
+	returnValues := inv.mtype.Method.Func.Call(in)
+	if errInter := returnValues[0].Interface(); errInter != nil {
+		return errInter.(error)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package peers_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	_proc "github.com/silvernodes/silvernode-go/peers/proc"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+type ChainReq struct {
+	Ctx  context.Context `auto:"context"`
+	Text string
+}
+
+type ChainResp struct {
+	Text string
+}
+
+// 依次记录中间件、拦截器及proc各阶段的执行情况
+type trail struct {
+	steps []string
+	sync.Mutex
+}
+
+func (tr *trail) add(format string, a ...interface{}) {
+	tr.Lock()
+	defer tr.Unlock()
+	tr.steps = append(tr.steps, fmt.Sprintf(format, a...))
+}
+
+func (tr *trail) take() string {
+	tr.Lock()
+	defer tr.Unlock()
+	steps := strings.Join(tr.steps, " ")
+	tr.steps = nil
+	return steps
+}
+
+// 上下文中元数据的user及stage
+func stage(c context.Context) string {
+	md, _ := peers.MetadataFrom(c)
+	return md.Get("user") + "/" + md.Get("stage")
+}
+
+// 以反射方式调用的proc
+type Guarded struct {
+	trail *trail
+}
+
+func (g *Guarded) Echo(args *ChainReq, reply *ChainResp) error {
+	g.trail.add("proc(%s)", stage(args.Ctx))
+	reply.Text = "echo:" + args.Text
+	return nil
+}
+
+func (g *Guarded) Notify(args *ChainReq) error {
+	g.trail.add("proc(%s)", stage(args.Ctx))
+	return nil
+}
+
+// 以ProcMeta方式调用的proc
+type Metered struct {
+	Guarded
+}
+
+func (m *Metered) Echo(args *ChainReq, reply *ChainResp) error {
+	return m.Guarded.Echo(args, reply)
+}
+
+func (m *Metered) Notify(args *ChainReq) error {
+	return m.Guarded.Notify(args)
+}
+
+func (m *Metered) GetMeta() _proc.ProcMeta {
+	return new(meteredMeta)
+}
+
+type meteredMeta struct {
+}
+
+func (meteredMeta) CreateBeans(method string, from string, ctx interface{}) (interface{}, interface{}, error) {
+	switch method {
+	case "Echo":
+		return new(ChainReq), new(ChainResp), nil
+	case "Notify":
+		return new(ChainReq), nil, nil
+	}
+	return nil, nil, errutil.New("方法不存在:" + method)
+}
+
+func (meteredMeta) ProcessFlow(method string, proc interface{}, args interface{}, reply interface{}) error {
+	switch method {
+	case "Echo":
+		return proc.(*Metered).Echo(args.(*ChainReq), reply.(*ChainResp))
+	case "Notify":
+		return proc.(*Metered).Notify(args.(*ChainReq))
+	}
+	return errutil.New("方法不存在:" + method)
+}
+
+// 记录调用前后的中间件，next之前追加stage以检查替换的上下文是否传递至内层
+func recordMiddleware(tr *trail, name string) peers.Middleware {
+	return func(next peers.Handler) peers.Handler {
+		return func(inv *peers.Invocation) error {
+			tr.add("%s>%s.%s(%s)", name, inv.Peer, inv.Method, stage(inv.Context))
+			inv.Context = peers.AppendMetadata(inv.Context, "stage", name)
+			err := next(inv)
+			if reply, ok := inv.Reply.(*ChainResp); ok {
+				tr.add("<%s(%s)", name, reply.Text)
+			} else {
+				tr.add("<%s", name)
+			}
+			return err
+		}
+	}
+}
+
+// logic节点依次注册中间件及Guarded、Metered
+func startGuarded(t *testing.T, middlewares func(tr *trail) []peers.Middleware) (*silvernodetest.Node, *silvernodetest.Node, *trail) {
+	t.Helper()
+	tr := new(trail)
+	c, gate := startGate(t, "logic", &silvernodetest.NodeSpec{Name: "logic", Init: func(n *silvernodetest.Node) error {
+		n.Hub().Use(middlewares(tr)...)
+		if _, err := n.Hub().Register(&Guarded{trail: tr}, nil); err != nil {
+			return err
+		}
+		_, err := n.Hub().Register(&Metered{Guarded{trail: tr}}, nil)
+		return err
+	}})
+	return gate, c.NodesByName("logic")[0], tr
+}
+
+// 等待事件执行完毕
+func waitTrail(t *testing.T, tr *trail, num int) string {
+	t.Helper()
+	deadline := time.Now().Add(silvernodetest.DefaultWait)
+	for {
+		tr.Lock()
+		n := len(tr.steps)
+		tr.Unlock()
+		if n >= num {
+			return tr.take()
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("等待执行超时: %s", tr.take())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestMiddlewareChain(t *testing.T) {
+	var invs []*peers.Invocation
+	var lock sync.Mutex
+	gate, logic, tr := startGuarded(t, func(tr *trail) []peers.Middleware {
+		return []peers.Middleware{func(next peers.Handler) peers.Handler {
+			return func(inv *peers.Invocation) error {
+				lock.Lock()
+				invs = append(invs, inv)
+				lock.Unlock()
+				return next(inv)
+			}
+		}, recordMiddleware(tr, "A"), recordMiddleware(tr, "B")}
+	})
+	c := peers.WithMetadata(context.Background(), peers.NewMetadata("user", "alice"))
+
+	for _, nick := range []string{"Guarded", "Metered"} {
+		t.Run(nick, func(t *testing.T) {
+			reply := new(ChainResp)
+			if err := gate.Caller().InvokeCtx(c, logic.Id(), nick+".Echo", &ChainReq{Text: "hi"}, reply); err != nil {
+				t.Fatal(err)
+			}
+			if reply.Text != "echo:hi" {
+				t.Fatalf("应答不符: %+v", reply)
+			}
+			want := fmt.Sprintf("A>%s.Echo(alice/) B>%s.Echo(alice/A) proc(alice/B) <B(echo:hi) <A(echo:hi)", nick, nick)
+			if got := tr.take(); got != want {
+				t.Fatalf("执行顺序不符:\n got %s\nwant %s", got, want)
+			}
+			lock.Lock()
+			inv := invs[len(invs)-1]
+			lock.Unlock()
+			if args, ok := inv.Args.(*ChainReq); inv.NodeId != gate.Id() || inv.Peer != nick || inv.Method != "Echo" || !ok || args.Text != "hi" {
+				t.Fatalf("调用信息不符: %+v", inv)
+			}
+
+			if err := gate.Caller().SendEventCtx(c, logic.Id(), nick+".Notify", &ChainReq{}); err != nil {
+				t.Fatal(err)
+			}
+			want = fmt.Sprintf("A>%s.Notify(alice/) B>%s.Notify(alice/A) proc(alice/B) <B <A", nick, nick)
+			if got := waitTrail(t, tr, 5); got != want {
+				t.Fatalf("事件执行顺序不符:\n got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+// 中间件返回错误时不再调用内层及proc，错误返回至调用方
+func TestMiddlewareShortCircuit(t *testing.T) {
+	gate, logic, tr := startGuarded(t, func(tr *trail) []peers.Middleware {
+		return []peers.Middleware{recordMiddleware(tr, "A"), func(next peers.Handler) peers.Handler {
+			return func(inv *peers.Invocation) error {
+				if md, _ := peers.MetadataFrom(inv.Context); md.Get("user") == "" {
+					return errutil.New("未登录")
+				}
+				return next(inv)
+			}
+		}, recordMiddleware(tr, "B")}
+	})
+
+	for _, nick := range []string{"Guarded", "Metered"} {
+		t.Run(nick, func(t *testing.T) {
+			reply := new(ChainResp)
+			err := gate.Caller().Invoke(logic.Id(), nick+".Echo", &ChainReq{Text: "hi"}, reply)
+			if !peers.IsRemoteError(err) || !strings.Contains(err.Error(), "未登录") {
+				t.Fatalf("应返回中间件的错误: %v", err)
+			}
+			if reply.Text != "" {
+				t.Fatalf("被拒绝的调用不应有应答: %+v", reply)
+			}
+			want := fmt.Sprintf("A>%s.Echo(/) <A()", nick)
+			if got := tr.take(); got != want {
+				t.Fatalf("执行顺序不符:\n got %s\nwant %s", got, want)
+			}
+
+			c := peers.WithMetadata(context.Background(), peers.NewMetadata("user", "bob"))
+			if err := gate.Caller().InvokeCtx(c, logic.Id(), nick+".Echo", &ChainReq{Text: "hi"}, reply); err != nil {
+				t.Fatal(err)
+			}
+			want = fmt.Sprintf("A>%s.Echo(bob/) B>%s.Echo(bob/A) proc(bob/B) <B(echo:hi) <A(echo:hi)", nick, nick)
+			if got := tr.take(); got != want {
+				t.Fatalf("执行顺序不符:\n got %s\nwant %s", got, want)
+			}
+		})
+	}
+}
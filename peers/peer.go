@@ -1,26 +1,31 @@
 package peers
 
 import (
+	"context"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
-	silvernode "github.com/silvernodes/silvernode-go"
 	"github.com/silvernodes/silvernode-go/ctx"
 	_proc "github.com/silvernodes/silvernode-go/peers/proc"
 
 	"github.com/silvernodes/silvernode-go/process"
+	"github.com/silvernodes/silvernode-go/trace"
 	"github.com/silvernodes/silvernode-go/utils/errutil"
 	"github.com/silvernodes/silvernode-go/utils/snowflake"
-	"github.com/silvernodes/silvernode-go/utils/timeutil"
 )
 
 type peer struct {
+	hub       *Hub
 	nick      string
 	typ       reflect.Type
 	proc      reflect.Value
 	methods   map[string]*_proc.MethodType
 	callbacks map[int64]*callFunc
+	serving   map[string]context.CancelFunc // 处理中的请求，用于响应调用方的取消通知
+	streams   map[string]*stream            // 本端参与的流，以对端节点及对端发来的流id索引
 	processor process.Processor
 	procctx   interface{}
 	lock      sync.RWMutex
@@ -29,13 +34,21 @@ type peer struct {
 	meta      _proc.ProcMeta
 }
 
-func copeer(nick string, inner bool, proc interface{}, procTp reflect.Type, processor process.Processor) *peer {
+func copeer(hub *Hub, nick string, inner bool, proc interface{}, procTp reflect.Type, processor process.Processor) *peer {
 	p := new(peer)
+	p.hub = hub
 	p.nick = nick
 	p.typ = procTp
 	p.proc = reflect.ValueOf(proc)
 	p.methods = _proc.SuitableMethods(p.typ)
+	for name, mtype := range p.methods {
+		if mtype.Stream && mtype.ReplyType != typeOfStream {
+			delete(p.methods, name)
+		}
+	}
 	p.callbacks = make(map[int64]*callFunc)
+	p.serving = make(map[string]context.CancelFunc)
+	p.streams = make(map[string]*stream)
 	p.processor = processor
 	p.procctx = proc
 	p.inner = inner
@@ -72,20 +85,109 @@ func (p *peer) Processor() process.Processor {
 }
 
 func (p *peer) onExchange(nodeId string, e *exchange) {
-	if p.processor == nil || !p.processor.Running() {
+	if e.Stream != 0 && e.Ret != 0 { // 流的消息及控制帧不进入任务队列，以免与阻塞在Recv中的处理方法互相等待
+		p.onStreamFrame(nodeId, e)
+		return
+	}
+	if e.Ret == retCancel { // 取消通知不进入任务队列，直接中止对应请求
+		p.cancelServing(nodeId, e.Seq)
+		return
+	}
+	if e.Ret == 0 {
+		p.serve(nodeId, e)
+		if e.Stream != 0 {
+			p.acceptStream(nodeId, e)
+		}
+	}
+	if e.stream != nil { // 流的处理贯穿流的整个生命周期，在独立协程中执行，不占用Processor及链接的读取协程
+		go func() {
+			defer errutil.Catch(func(err error) {
+				p.hub.node.Error(err)
+			})
+			p.dealExchange(nodeId, e)
+		}()
+	} else if p.processor != nil && p.processor.Running() {
+		p.processor.Execute(func() {
+			p.dealExchange(nodeId, e)
+		})
+	} else {
 		defer errutil.Catch(func(err error) {
-			silvernode.Error(err)
+			p.hub.node.Error(err)
 		})
 		p.dealExchange(nodeId, e)
+	}
+}
+
+// 为收到的请求建立上下文，请求超时或调用方放弃时取消
+func (p *peer) serve(nodeId string, e *exchange) {
+	c := context.Background()
+	if e.Metadata != nil {
+		c = WithMetadata(c, e.Metadata)
+		if sc, ok := trace.ParseTraceparent(e.Metadata[trace.TRACEPARENT]); ok {
+			c = trace.WithRemote(c, sc)
+		}
+	}
+	if e.TimeLeft > 0 {
+		e.callCtx, e.cancel = context.WithTimeout(c, time.Duration(e.TimeLeft)*time.Millisecond)
 	} else {
-		p.processor.Execute(func() {
-			p.dealExchange(nodeId, e)
-		})
+		e.callCtx, e.cancel = context.WithCancel(c)
+	}
+	if e.Seq != 0 {
+		p.lock.Lock()
+		p.serving[servingKey(nodeId, e.Seq)] = e.cancel
+		p.lock.Unlock()
+	}
+}
+
+func (p *peer) served(nodeId string, e *exchange) {
+	if e.cancel == nil {
+		return
+	}
+	if e.stream != nil { // 排队期间已超时或被放弃时，以上下文的错误结束
+		e.stream.shutdown(e.callCtx.Err(), true)
 	}
+	if e.Seq != 0 {
+		p.lock.Lock()
+		delete(p.serving, servingKey(nodeId, e.Seq))
+		p.lock.Unlock()
+	}
+	e.cancel()
+}
+
+func (p *peer) cancelServing(nodeId string, seq int64) {
+	key := servingKey(nodeId, seq)
+	p.lock.Lock()
+	cancel, exists := p.serving[key]
+	delete(p.serving, key)
+	p.lock.Unlock()
+	if exists {
+		cancel()
+	}
+}
+
+func servingKey(nodeId string, seq int64) string {
+	return nodeId + "#" + strconv.FormatInt(seq, 10)
 }
 
 func (p *peer) dealExchange(nodeId string, e *exchange) {
 	if e.Ret == 0 {
+		defer p.served(nodeId, e)
+		if e.callCtx != nil && e.callCtx.Err() != nil { // 排队期间已超时或被放弃，调用方不再等待应答
+			return
+		}
+		kind := trace.KIND_SERVER
+		if e.oneway() {
+			kind = trace.KIND_CONSUMER
+		}
+		e.callCtx, e.span = trace.Start(e.callCtx, p.hub.node.Name(), e.To+"."+e.Func, kind)
+		e.span.SetAttribute("rpc.system", "silvernode")
+		e.span.SetAttribute("rpc.service", e.To)
+		e.span.SetAttribute("rpc.method", e.Func)
+		e.span.SetAttribute("peer.node", nodeId)
+		defer e.span.End()
+		if e.stream != nil {
+			e.stream.ctx = e.callCtx
+		}
 		if p.inner && ctx.IsGuest(nodeId) {
 			p.response(nodeId, e, nil, errutil.New("没有访问权限:"+p.nick))
 			return
@@ -97,103 +199,116 @@ func (p *peer) dealExchange(nodeId string, e *exchange) {
 			return
 		}
 
-		// 利用反射调取proc方法
 		mtype, b := p.methods[e.Func]
-		if b {
-			if p.meta != nil {
-				args, reply, err := p.meta.CreateBeans(e.Func, nodeId, ctx)
-				if err != nil {
-					p.response(nodeId, e, nil, err)
-					return
-				}
-				if e.args != nil { // 本地调用
-					args = e.args
-					if e.Seq != 0 {
-						if p, exists := getpeer(e.From); exists {
-							if call, b := p.getCall(e.Seq); b {
-								reply = call.Reply
-							}
-						}
-					}
-				} else if err := e.FetchArgs(nodeId, args); err != nil {
-					p.response(nodeId, e, nil, errutil.Extend("请求数据反序列化出错", err))
-					return
-				}
-				if err := p.meta.ProcessFlow(e.Func, p.Proc(), args, reply); err != nil {
-					p.response(nodeId, e, nil, err)
-					return
-				}
-				p.response(nodeId, e, reply, nil)
-
+		if !b {
+			if e.oneway() {
+				p.hub.node.Error(errutil.New("目标事件不存在:" + e.Func))
 			} else {
-				argv, err := e.FetchArgv(nodeId, mtype, ctx)
-				if err != nil {
-					p.response(nodeId, e, nil, err)
-					return
-				}
-				if e.Seq == 0 {
-					function := mtype.Method.Func
-					returnValues := function.Call([]reflect.Value{p.proc, argv})
-					errInter := returnValues[0].Interface()
-					var reterr error = nil
-					if errInter != nil {
-						reterr = errInter.(error)
-						silvernode.Error(errutil.Extend("目标事件执行异常:"+e.Func, reterr))
-					}
-				} else {
-					replyv := e.FetchReplyv(nodeId, mtype)
-					function := mtype.Method.Func
-					returnValues := function.Call([]reflect.Value{p.proc, argv, replyv})
-					errInter := returnValues[0].Interface()
-					var reterr error = nil
-					if errInter != nil {
-						reterr = errInter.(error)
+				p.response(nodeId, e, nil, errutil.New("方法不存在:"+e.Func))
+			}
+			return
+		}
+		if mtype.Stream != (e.stream != nil) {
+			if e.oneway() {
+				p.hub.node.Error(errutil.New("流方法须以OpenStream调用:" + e.Func))
+			} else if mtype.Stream {
+				p.response(nodeId, e, nil, errutil.New("流方法须以OpenStream调用:"+e.Func))
+			} else {
+				p.response(nodeId, e, nil, errutil.New("方法不支持流式调用:"+e.Func))
+			}
+			return
+		}
+		inv := &Invocation{
+			NodeId:  nodeId,
+			Peer:    p.nick,
+			Method:  e.Func,
+			Context: e.callCtx,
+			peer:    p,
+			mtype:   mtype,
+		}
+		if p.meta != nil && !mtype.Stream {
+			args, reply, err := p.meta.CreateBeans(e.Func, nodeId, ctx)
+			if err != nil {
+				p.response(nodeId, e, nil, err)
+				return
+			}
+			if e.args != nil { // 本地调用
+				args = e.args
+				if e.Seq != 0 {
+					if p, exists := p.hub.getpeer(e.From); exists {
+						if call, b := p.getCall(e.Seq); b {
+							reply = call.Reply
+						}
 					}
-					p.response(nodeId, e, replyv.Interface(), reterr)
 				}
+			} else if err := e.FetchArgs(p.hub, nodeId, args); err != nil {
+				p.response(nodeId, e, nil, errutil.Extend("请求数据反序列化出错", err))
+				return
 			}
+			inv.Args, inv.Reply = args, reply
 		} else {
-			if e.Seq == 0 {
-				silvernode.Error(errutil.New("目标事件不存在:" + e.Func))
-			} else {
-				p.response(nodeId, e, nil, errutil.New("方法不存在:"+e.Func))
+			// 利用反射调取proc方法
+			argv, err := e.FetchArgv(p.hub, nodeId, mtype, ctx)
+			if err != nil {
+				p.response(nodeId, e, nil, err)
+				return
+			}
+			inv.Args = argv.Interface()
+			if e.stream != nil {
+				inv.Reply = Stream(e.stream)
+			} else if e.Seq != 0 {
+				inv.Reply = e.FetchReplyv(p.hub, nodeId, mtype).Interface()
 			}
 		}
+		err = p.hub.handle(inv)
+		if e.oneway() {
+			if err != nil {
+				e.span.SetError(err)
+				p.hub.node.Error(errutil.Extend("目标事件执行异常:"+e.Func, err))
+			}
+		} else if err != nil {
+			p.response(nodeId, e, nil, err)
+		} else {
+			p.response(nodeId, e, inv.Reply, nil)
+		}
 	} else {
 		call, b := p.takeoutCall(e.Seq)
 		if b {
 			if e.Err != "" {
-				call.Error = errutil.New(e.Err)
+				call.Error = &remoteError{text: e.Err}
 			} else {
-				if nodeId != ctx.GetNodeId() {
-					if err := e.FetchArgs(nodeId, call.Reply); err != nil {
+				if nodeId != p.hub.node.NodeId() {
+					if err := e.FetchArgs(p.hub, nodeId, call.Reply); err != nil {
 						call.Error = errutil.Extend("应答结果反序列化出错", err)
 					}
 				}
 			}
-			if call.Done != nil {
-				call.Done(call.Error)
-			} else {
-				call.Chan <- call.Error
-			}
+			call.finish(call.Error)
 		}
 	}
 }
 
-func (p *peer) buildCall(method string, args interface{}, reply interface{}, done func(error), c chan error) (int64, *callFunc) {
+func (p *peer) buildCall(c context.Context, node string, to string, method string, args interface{}, reply interface{}, done func(error), timeout time.Duration) (int64, *callFunc) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
 	seq := snowflake.GenerateRaw()
 	call := &callFunc{
-		Method:  method,
-		Args:    args,
-		Reply:   reply,
-		Error:   nil,
-		Done:    done,
-		Chan:    c,
-		TimeOut: timeutil.Time() + int64(_setup.Timeout),
+		Method: method,
+		Args:   args,
+		Reply:  reply,
+		Error:  nil,
+		Done:   done,
+		seq:    seq,
+		node:   node,
+		to:     to,
+	}
+	if c.Done() != nil {
+		call.over = make(chan struct{})
 	}
+	call.timer = p.hub.wheel.AfterFunc(timeout, func() {
+		p.expireCall(seq)
+	})
 	p.callbacks[seq] = call
 	return seq, call
 }
@@ -211,97 +326,251 @@ func (p *peer) takeoutCall(seq int64) (*callFunc, bool) {
 	call, b := p.callbacks[seq]
 	if b {
 		delete(p.callbacks, seq)
+		p.hub.wheel.Cancel(call.timer)
 	}
 	return call, b
 }
 
-func (p *peer) checkTimeOut() {
-	p.lock.Lock()
-	defer p.lock.Unlock()
+// 由时间轮在请求到期时触发，被调方持有相同的时限，无需另行通知
+func (p *peer) expireCall(seq int64) {
+	if call, b := p.takeoutCall(seq); b {
+		call.finish(errutil.New("请求超时!"))
+	}
+}
 
-	now := timeutil.Time()
-	dirtyList := make([]int64, 0, 0)
-	for seq, call := range p.callbacks {
-		if call.TimeOut > now {
-			err := errutil.New("请求超时!")
-			if call.Done != nil {
-				call.Done(err)
-			} else {
-				call.Chan <- err
-			}
-			dirtyList = append(dirtyList, seq)
-		}
+// 调用方放弃请求，结束等待并通知被调方中止处理
+func (p *peer) abandonCall(seq int64, err error) {
+	call, b := p.takeoutCall(seq)
+	if !b {
+		return
+	}
+	call.finish(err)
+	e := &exchange{
+		From: p.nick,
+		To:   call.to,
+		Seq:  seq,
+		Ret:  retCancel,
 	}
-	for _, seq := range dirtyList {
+	if call.node == p.hub.node.NodeId() {
+		p.hub.localExchange(call.node, e)
+	} else if _setup.LegacyExchange { // 旧版节点无法识别取消通知，会将其误当作应答
+		return
+	} else if data, err := e.Marshal(call.node, 128); err == nil {
+		p.hub.node.Send(call.node, data)
+	}
+}
+
+func (p *peer) pendingNum() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	sum := len(p.callbacks)
+	if p.processor != nil && p.processor.Running() {
+		sum += p.processor.TaskLen()
+	}
+	return sum
+}
+
+func (p *peer) abortCalls(err error) {
+	p.lock.Lock()
+	calls := make([]*callFunc, 0, len(p.callbacks))
+	for seq, call := range p.callbacks {
+		p.hub.wheel.Cancel(call.timer)
+		calls = append(calls, call)
 		delete(p.callbacks, seq)
 	}
-	dirtyList = nil
+	p.lock.Unlock()
+
+	for _, call := range calls {
+		call.finish(err)
+	}
 }
 
-func (p *peer) request(node string, method string, args interface{}, reply interface{}, done func(error), c chan error) (*callFunc, error) {
-	var call *callFunc = nil
-	seq := int64(0)
-	if reply != nil {
-		seq, call = p.buildCall(method, args, reply, done, c)
+// 告知被调方的时限取全局超时与上下文截止时间中较早者
+// 本地仅按全局超时登记时间轮，上下文到期由调用方监听并返回c.Err()
+func (p *peer) timeLeft(c context.Context, timeout time.Duration) time.Duration {
+	if deadline, ok := c.Deadline(); ok {
+		if left := time.Until(deadline); left < timeout {
+			timeout = left
+		}
+	}
+	if timeout < time.Millisecond {
+		timeout = time.Millisecond
 	}
+	return timeout
+}
+
+func (p *peer) request(c context.Context, node string, method string, args interface{}, reply interface{}, done func(error)) (*callFunc, error) {
 	methodInfo := strings.Split(method, ".")
 	if len(methodInfo) != 2 {
 		return nil, errutil.New("方法名必须符合PeerNick.FuncName的规范:" + method)
 	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	var call *callFunc = nil
+	seq := int64(0)
+	timeLeft := int64(0)
+	if reply != nil {
+		timeout := time.Duration(_setup.Timeout) * time.Millisecond
+		seq, call = p.buildCall(c, node, methodInfo[0], method, args, reply, done, timeout)
+		timeLeft = int64((p.timeLeft(c, timeout) + time.Millisecond - 1) / time.Millisecond) // 向上取整，避免被调方先于调用方到期
+	}
 	e := &exchange{
-		From: p.nick,
-		To:   methodInfo[0],
-		Func: methodInfo[1],
-		args: args,
-		Seq:  seq,
-		Ret:  0,
-		Err:  "",
+		From:     p.nick,
+		To:       methodInfo[0],
+		Func:     methodInfo[1],
+		args:     args,
+		Seq:      seq,
+		Ret:      0,
+		Err:      "",
+		TimeLeft: timeLeft,
 	}
-	e.PrintInfo(node, true)
-	if node == ctx.GetNodeId() {
-		localExchange(node, e)
+	attachMetadata(c, e)
+	e.PrintInfo(p.hub.node.NodeId(), node, true)
+	if node == p.hub.node.NodeId() {
+		p.hub.localExchange(node, e)
 	} else {
 		data, err := e.Marshal(node, 1024)
 		if err != nil {
-			return nil, errutil.Extend("交互数据序列化出错:"+ctx.GetNodeId()+" -> "+node, err)
+			p.takeoutCall(seq)
+			return nil, errutil.Extend("交互数据序列化出错:"+p.hub.node.NodeId()+" -> "+node, err)
 		}
 		// 跨节点发送
-		if err := silvernode.Send(node, data); err != nil {
-			return nil, errutil.Extend("跨节点交互出错:"+ctx.GetNodeId()+" -> "+node, err)
+		if err := p.hub.node.Send(node, data); err != nil {
+			p.takeoutCall(seq)
+			return nil, errutil.Extend("跨节点交互出错:"+p.hub.node.NodeId()+" -> "+node, err)
 		}
 	}
+	if call != nil && call.over != nil {
+		go func() {
+			select {
+			case <-c.Done():
+				p.abandonCall(seq, c.Err())
+			case <-call.over:
+			}
+		}()
+	}
 	return call, nil
 }
 
+// 将上下文中的元数据及链路信息附加至请求
+func attachMetadata(c context.Context, e *exchange) {
+	if md, ok := MetadataFrom(c); ok && len(md) > 0 {
+		e.Metadata = md.Clone() // 本地调用时由被调方直接持有，避免与调用方共享
+	}
+	if sc := trace.SpanContextFrom(c); sc.IsValid() {
+		if e.Metadata == nil {
+			e.Metadata = make(Metadata)
+		}
+		e.Metadata[trace.TRACEPARENT] = sc.Traceparent()
+	}
+}
+
 func (p *peer) Do(method string, args interface{}, reply interface{}) error {
-	node := ctx.GetNodeId()
+	node := p.hub.node.NodeId()
 	return p.Invoke(node, method, args, reply)
 }
 
 func (p *peer) Invoke(node string, method string, args interface{}, reply interface{}) error {
-	c := make(chan error, 5)
-	call, err := p.request(node, method, args, reply, nil, c)
+	return p.InvokeCtx(context.Background(), node, method, args, reply)
+}
+
+// 上下文被取消或到达截止时间时立即返回c.Err()，并通知被调方中止处理
+func (p *peer) InvokeCtx(c context.Context, node string, method string, args interface{}, reply interface{}) error {
+	ch := make(chan error, 1)
+	p.CallCtx(c, node, method, args, reply, func(err error) {
+		ch <- err
+	})
+	return <-ch
+}
+
+func (p *peer) Call(node string, method string, args interface{}, reply interface{}, done func(error)) {
+	p.CallCtx(context.Background(), node, method, args, reply, done)
+}
+
+// 上下文被取消时done收到c.Err()，并通知被调方中止处理
+func (p *peer) CallCtx(c context.Context, node string, method string, args interface{}, reply interface{}, done func(error)) {
+	if c.Done() != nil {
+		finish := done
+		done = func(err error) {
+			if err != nil { // 被调方因同一时限中止，以上下文的错误为准
+				if cerr := c.Err(); cerr != nil {
+					err = cerr
+				} else if deadline, ok := c.Deadline(); ok && !time.Now().Before(deadline) { // 应答先于本地计时器到达
+					err = context.DeadlineExceeded
+				}
+			}
+			finish(err)
+		}
+	}
+	p.hub.intercept(&Request{
+		Context: c,
+		From:    p.nick,
+		Node:    node,
+		Method:  method,
+		Args:    args,
+		Reply:   reply,
+		peer:    p,
+	}, done)
+}
+
+// 按节点名称调用，由该名称对应的选择器从已链接的同名节点(含本节点)中选出目标
+func (p *peer) InvokeAny(name string, method string, args interface{}, reply interface{}) error {
+	return p.InvokeAnyCtx(context.Background(), name, method, args, reply)
+}
+
+func (p *peer) CallAny(name string, method string, args interface{}, reply interface{}, done func(error)) {
+	p.CallAnyCtx(context.Background(), name, method, args, reply, done)
+}
+
+// 一致性哈希选择器使用的键由WithHashKey写入上下文
+func (p *peer) InvokeAnyCtx(c context.Context, name string, method string, args interface{}, reply interface{}) error {
+	node, err := p.hub.selectNode(c, name)
 	if err != nil {
 		return err
 	}
-	err2 := <-call.Chan
-	close(call.Chan)
-	call = nil
-	return err2
+	return p.InvokeCtx(c, node, method, args, reply)
 }
 
-func (p *peer) Call(node string, method string, args interface{}, reply interface{}, done func(error)) {
-	if _, err := p.request(node, method, args, reply, done, nil); err != nil {
+func (p *peer) CallAnyCtx(c context.Context, name string, method string, args interface{}, reply interface{}, done func(error)) {
+	node, err := p.hub.selectNode(c, name)
+	if err != nil {
 		done(err)
+		return
 	}
+	p.CallCtx(c, node, method, args, reply, done)
 }
 
 func (p *peer) SendEvent(node string, method string, args interface{}) error {
-	_, err := p.request(node, method, args, nil, nil, nil)
-	return err
+	return p.SendEventCtx(context.Background(), node, method, args)
+}
+
+// 事件不等待应答，上下文仅用于传递元数据及链路信息
+func (p *peer) SendEventCtx(c context.Context, node string, method string, args interface{}) error {
+	ch := make(chan error, 1)
+	p.hub.intercept(&Request{
+		Context: c,
+		From:    p.nick,
+		Node:    node,
+		Method:  method,
+		Args:    args,
+		peer:    p,
+	}, func(err error) {
+		ch <- err
+	})
+	return <-ch
 }
 
 func (p *peer) response(node string, e *exchange, reply interface{}, err error) error {
+	e.span.SetError(err)
+	if e.stream != nil { // 流以处理结果结束
+		e.stream.shutdown(err, true)
+		return nil
+	}
+	if err != nil && e.callCtx != nil && e.callCtx.Err() != nil { // 因时限到期或调用方放弃而中止，由调用方按自身的时限返回
+		return nil
+	}
 	if e.Seq != 0 {
 		Err := ""
 		if err != nil {
@@ -316,19 +585,34 @@ func (p *peer) response(node string, e *exchange, reply interface{}, err error)
 			Ret:  1,
 			Err:  Err,
 		}
-		r.PrintInfo(node, true)
-		if node == ctx.GetNodeId() {
-			localExchange(node, r)
+		r.PrintInfo(p.hub.node.NodeId(), node, true)
+		if node == p.hub.node.NodeId() {
+			p.hub.localExchange(node, r)
 		} else {
 			msg, err := r.Marshal(node, 4096)
 			if err != nil {
-				return errutil.Extend(ctx.GetNodeId()+" -> "+node, err)
+				return errutil.Extend(p.hub.node.NodeId()+" -> "+node, err)
 			}
 			// 跨节点发送
-			if err := silvernode.Send(node, msg); err != nil {
-				return errutil.Extend("跨节点交互出错:"+ctx.GetNodeId()+" -> "+node, err)
+			if err := p.hub.node.Send(node, msg); err != nil {
+				return errutil.Extend("跨节点交互出错:"+p.hub.node.NodeId()+" -> "+node, err)
 			}
 		}
 	}
 	return nil
 }
+
+// 被调方处理请求时返回的错误
+type remoteError struct {
+	text string
+}
+
+func (e *remoteError) Error() string {
+	return e.text
+}
+
+// 是否为被调方返回的错误，用以区别超时、发送失败等未得到应答的情况
+func IsRemoteError(err error) bool {
+	_, ok := err.(*remoteError)
+	return ok
+}
@@ -1,29 +1,80 @@
 package peers
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
+	silvernode "github.com/silvernodes/silvernode-go"
 	_proc "github.com/silvernodes/silvernode-go/peers/proc"
 	"github.com/silvernodes/silvernode-go/process"
 	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/timeutil"
 )
 
-var _peers map[string]*peer
-var _lock sync.RWMutex
+// 节点的Peer表，每个节点实例对应一个独立的Hub
+type Hub struct {
+	node  *silvernode.Node
+	peers map[string]*peer
+	wheel *timeutil.TimeWheel // 请求超时
+	lock  sync.RWMutex
 
-func Register(proc interface{}, processor process.Processor) (Peer, error) {
-	return RegisterWithNick("", false, proc, processor)
+	middlewares []Middleware
+	handler     Handler // 由中间件链组装而成
+
+	interceptors []Interceptor
+	invoker      Invoker // 由拦截器链组装而成
+
+	selector   Selector            // 缺省选择器
+	selectors  map[string]Selector // 按节点名称指定的选择器
+	flights    map[string]int      // 各节点进行中的请求数
+	flightLock sync.Mutex
+
+	retry        *RetryPolicy
+	idempotents  map[string]bool
+	breakerConfs map[string]*BreakerConf // 以节点id或节点名称指定的熔断配置
+	breakers     map[string]*breaker     // 各目标节点的熔断器
+	breakerLock  sync.Mutex
+	retries      map[*retryTask]func(error) // 等待重试的请求
+	draining     bool                       // 节点关闭中，不再重试
+	retryLock    sync.Mutex
 }
 
-func RegisterInner(proc interface{}, processor process.Processor) (Peer, error) {
-	return RegisterWithNick("", true, proc, processor)
+func NewHub(node *silvernode.Node) *Hub {
+	h := new(Hub)
+	h.node = node
+	h.peers = make(map[string]*peer)
+	h.wheel = timeutil.NewTimeWheel(time.Millisecond*10, 512)
+	h.handler = invokeProc
+	h.invoker = sendRequest
+	h.selector = NewRoundRobinSelector()
+	h.selectors = make(map[string]Selector)
+	h.flights = make(map[string]int)
+	h.retry = NewRetryPolicy()
+	h.idempotents = make(map[string]bool)
+	h.breakerConfs = make(map[string]*BreakerConf)
+	h.breakers = make(map[string]*breaker)
+	h.retries = make(map[*retryTask]func(error))
+	return h
 }
 
-func RegisterWithNick(nick string, inner bool, proc interface{}, processor process.Processor) (Peer, error) {
-	_lock.Lock()
-	defer _lock.Unlock()
+func (h *Hub) Node() *silvernode.Node {
+	return h.node
+}
+
+func (h *Hub) Register(proc interface{}, processor process.Processor) (Peer, error) {
+	return h.RegisterWithNick("", false, proc, processor)
+}
+
+func (h *Hub) RegisterInner(proc interface{}, processor process.Processor) (Peer, error) {
+	return h.RegisterWithNick("", true, proc, processor)
+}
+
+func (h *Hub) RegisterWithNick(nick string, inner bool, proc interface{}, processor process.Processor) (Peer, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
 	procTp := reflect.TypeOf(proc)
 	if procTp.Kind() != reflect.Ptr {
 		return nil, errutil.New("proc必须为指针类型!")
@@ -31,15 +82,20 @@ func RegisterWithNick(nick string, inner bool, proc interface{}, processor proce
 	if nick == "" {
 		nick = procTp.Elem().Name()
 	}
-	if _, b := _peers[nick]; b {
+	if _, b := h.peers[nick]; b {
 		return nil, errutil.New("已存在同昵称Peer:" + nick)
 	}
-	p := copeer(nick, inner, proc, procTp, processor)
+	p := copeer(h, nick, inner, proc, procTp, processor)
 	if err := p.filedsAutoLoad(); err != nil {
 		return nil, err
 	}
+	if table, ok := p.meta.(_proc.MethodTable); ok { // 生成代码过期时拒绝注册，以免调用时才出错
+		if err := _proc.CheckMethodTable(table, p.methods); err != nil {
+			return nil, errutil.Extend("Peer["+nick+"]的meta已过期,请重新执行silvernode gen", err)
+		}
+	}
 	_proc.RecordMetaRaw(p.typ, p.methods)
-	_peers[nick] = p
+	h.peers[nick] = p
 	txt := "Peer[" + nick + "]注册完毕."
 	if p.meta != nil {
 		txt += "(!)"
@@ -48,11 +104,11 @@ func RegisterWithNick(nick string, inner bool, proc interface{}, processor proce
 	return p, nil
 }
 
-func Dispose(nick string, withProcessor bool) {
-	_lock.Lock()
-	defer _lock.Unlock()
+func (h *Hub) Dispose(nick string, withProcessor bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
 
-	if peer, b := _peers[nick]; b {
+	if peer, b := h.peers[nick]; b {
 		peer.disposing = true
 		if withProcessor {
 			peer.processor.Terminate()
@@ -60,32 +116,106 @@ func Dispose(nick string, withProcessor bool) {
 	}
 }
 
-func GetPeer(nick string) (Peer, bool) {
-	return getpeer(nick)
+func (h *Hub) GetPeer(nick string) (Peer, bool) {
+	return h.getpeer(nick)
 }
 
-func getpeer(nick string) (*peer, bool) {
-	_lock.Lock()
-	defer _lock.Unlock()
+func (h *Hub) getpeer(nick string) (*peer, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
 
-	peer, b := _peers[nick]
+	peer, b := h.peers[nick]
 	if b && !peer.disposing {
 		return peer, true
 	}
 	return nil, false
 }
 
-func onExchange(nodeId string, data []byte) error {
+func (h *Hub) onExchange(nodeId string, data []byte) error {
 	e := &exchange{}
 	if err := e.Unmarshal(data); err != nil {
 		return err
 	}
-	localExchange(nodeId, e)
+	h.localExchange(nodeId, e)
 	return nil
 }
 
-func localExchange(nodeId string, e *exchange) {
-	if peer, b := getpeer(e.To); b {
+func (h *Hub) localExchange(nodeId string, e *exchange) {
+	if peer, b := h.getpeer(e.To); b {
 		peer.onExchange(nodeId, e)
 	}
 }
+
+// 节点关闭时，等待所有进行中的调用完成，并清空各Peer的任务队列
+// 超时后仍未完成的调用将被直接中断；流通常长期存在，直接中断
+func (h *Hub) drain(c context.Context) error {
+	h.abortStreams(errutil.New("节点已关闭,流被中断!"))
+	h.abortRetries(errNodeClosed())
+	for {
+		if h.pendingNum() <= 0 {
+			return nil
+		}
+		select {
+		case <-c.Done():
+			h.abortCalls(errNodeClosed())
+			return errutil.Extend("等待Peer调用及任务队列清空超时", c.Err())
+		case <-time.After(time.Millisecond * 50):
+		}
+	}
+}
+
+func (h *Hub) pendingNum() int {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	sum := h.retryNum()
+	for _, peer := range h.peers {
+		sum += peer.pendingNum()
+	}
+	return sum
+}
+
+func (h *Hub) abortCalls(err error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, peer := range h.peers {
+		peer.abortCalls(err)
+	}
+}
+
+func (h *Hub) abortStreams(err error) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for _, peer := range h.peers {
+		peer.abortStreams(err)
+	}
+}
+
+var _hub *Hub
+
+// 默认节点对应的Hub，包级函数均作用于该Hub
+func DefaultHub() *Hub {
+	return _hub
+}
+
+func Register(proc interface{}, processor process.Processor) (Peer, error) {
+	return _hub.Register(proc, processor)
+}
+
+func RegisterInner(proc interface{}, processor process.Processor) (Peer, error) {
+	return _hub.RegisterInner(proc, processor)
+}
+
+func RegisterWithNick(nick string, inner bool, proc interface{}, processor process.Processor) (Peer, error) {
+	return _hub.RegisterWithNick(nick, inner, proc, processor)
+}
+
+func Dispose(nick string, withProcessor bool) {
+	_hub.Dispose(nick, withProcessor)
+}
+
+func GetPeer(nick string) (Peer, bool) {
+	return _hub.GetPeer(nick)
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
 )
 
 // 自定义Peer调用形式
@@ -13,6 +15,54 @@ type ProcMeta interface {
 	ProcessFlow(method string, proc interface{}, args interface{}, reply interface{}) error
 }
 
+const (
+	METHOD_CALL   int = 0 // 请求-应答
+	METHOD_EVENT  int = 1 // 事件，无应答
+	METHOD_STREAM int = 2 // 流方法
+)
+
+// 方法表中的一项，类型以reflect.Type.String()的形式记录，如*room.JoinReq
+type MethodDesc struct {
+	Name  string
+	Kind  int
+	Args  string
+	Reply string // 事件为空
+}
+
+// 由silvernode gen生成的ProcMeta同时实现该接口，注册时据此校验生成代码是否与proc一致
+type MethodTable interface {
+	Methods() []MethodDesc
+}
+
+// 方法表与proc的实际方法不一致时返回错误，通常是修改proc后未重新生成
+func CheckMethodTable(table MethodTable, methods map[string]*MethodType) error {
+	descs := table.Methods()
+	if len(descs) != len(methods) {
+		return errutil.New(fmt.Sprintf("方法表与proc的方法数量不一致:%d<-->%d", len(descs), len(methods)))
+	}
+	for _, desc := range descs {
+		mtype, exists := methods[desc.Name]
+		if !exists {
+			return errutil.New("方法表中的方法不存在:" + desc.Name)
+		}
+		if kind, args, reply := describe(mtype); kind != desc.Kind || args != desc.Args || reply != desc.Reply {
+			return errutil.New("方法签名与方法表不一致:" + desc.Name)
+		}
+	}
+	return nil
+}
+
+func describe(mtype *MethodType) (int, string, string) {
+	switch {
+	case mtype.Stream:
+		return METHOD_STREAM, mtype.ArgType.String(), mtype.ReplyType.String()
+	case mtype.ReplyType == nil:
+		return METHOD_EVENT, mtype.ArgType.String(), ""
+	default:
+		return METHOD_CALL, mtype.ArgType.String(), mtype.ReplyType.String()
+	}
+}
+
 type _MetaInfo struct {
 	typ     reflect.Type
 	methods map[string]*MethodType
@@ -49,6 +99,7 @@ func RecordMetaRaw(typ reflect.Type, methods map[string]*MethodType) {
 	}
 }
 
+// Deprecated: 运行时依据工作目录推断源码路径，脱离源码目录运行时无法使用；请改用silvernode gen在构建前生成
 func PublishMetas() error {
 	for _, p := range _metas {
 		if err := buildPeerMeta(p); err != nil {
@@ -2,7 +2,9 @@ package proc
 
 import (
 	"fmt"
+	"go/format"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/silvernodes/silvernode-go/utils/errutil"
@@ -10,6 +12,9 @@ import (
 	"github.com/silvernodes/silvernode-go/utils/stringutil"
 )
 
+// 生成文件的首行，silvernode gen据此识别并替换已生成的文件
+const GENERATED_HEADER = "// Code generated by silvernode. DO NOT EDIT."
+
 func getPkgOnDisk(pkg string) string {
 	curDir := fileutil.CurrentDir()
 	if pkg == "main" {
@@ -52,34 +57,37 @@ func buildPeerMeta(p *_MetaInfo) error {
 	metaName := name + "Meta"
 
 	pkgName := getPkgName(path)
-	txt := "package " + pkgName + "\r\n\r\n"
-	txt += "import (\r\n"
-	txt += "\t\"errors\"\r\n"
-	txt += "\t\"github.com/silvernodes/silvernode-go/peers/proc\"\r\n"
+	txt := GENERATED_HEADER + "\n\n"
+	txt += "package " + pkgName + "\n\n"
+	txt += "import (\n"
+	txt += "\t\"errors\"\n"
+	txt += "\t\"github.com/silvernodes/silvernode-go/peers/proc\"\n"
 	txt += "%s"
-	txt += ")\r\n\r\n"
-	txt += "type " + metaName + " struct {\r\n}\r\n"
-	txt += "\r\n"
-	txt += "func (m *" + metaName + ") CreateBeans(method string, from string, ctx interface{}) (interface{}, interface{}, error) {\r\n"
-	txt += "\tswitch method {\r\n"
+	txt += ")\n\n"
+	txt += "type " + metaName + " struct {\n}\n"
+	txt += "\n"
+	txt += "func (m *" + metaName + ") CreateBeans(method string, from string, ctx interface{}) (interface{}, interface{}, error) {\n"
+	txt += "\tswitch method {\n"
 	txt += "%s"
-	txt += "\t}\r\n"
+	txt += "\t}\n"
 	txt += "\treturn nil, nil, errors.New(\"不支持的方法装配:\" + method)"
-	txt += "\r\n}\r\n\r\n"
-	txt += "func (m *" + metaName + ") ProcessFlow(method string, proc interface{}, args interface{}, reply interface{}) error {\r\n"
-	txt += "\tswitch method {\r\n"
+	txt += "\n}\n\n"
+	txt += "func (m *" + metaName + ") ProcessFlow(method string, proc interface{}, args interface{}, reply interface{}) error {\n"
+	txt += "\tswitch method {\n"
 	txt += "%s"
-	txt += "\t}\r\n"
+	txt += "\t}\n"
 	txt += "\treturn errors.New(\"不支持的方法调用:\" + method)"
-	txt += "\r\n}\r\n\r\n"
-	txt += "func (p *" + name + ") GetMeta() proc.ProcMeta {\r\n"
-	txt += "\treturn new(" + metaName + ")\r\n"
-	txt += "}\r\n"
+	txt += "\n}\n\n"
+	txt += "func (p *" + name + ") GetMeta() proc.ProcMeta {\n"
+	txt += "\treturn new(" + metaName + ")\n"
+	txt += "}\n"
 
 	pkgs, bean, invoke := flushFuncBody(p)
-	txt = fmt.Sprintf(txt, pkgs, bean, invoke)
-
-	if err := fileutil.SaveFile(disk+"/"+file, txt); err != nil {
+	source, err := format.Source([]byte(fmt.Sprintf(txt, pkgs, bean, invoke)))
+	if err != nil {
+		return errutil.Extend("格式化meta文件出错", err)
+	}
+	if err := fileutil.SaveFile(disk+"/"+file, string(source)); err != nil {
 		return errutil.Extend("发布meta文件出错", err)
 	}
 
@@ -94,36 +102,40 @@ func flushFuncBody(p *_MetaInfo) (string, string, string) {
 	invoke := ""
 	typ := p.typ.Elem().Name()
 	pkg := p.typ.Elem().PkgPath()
-	for name, mtype := range p.methods {
-		argsType := mtype.ArgType.Elem().Name()
-		argsPkg := mtype.ArgType.Elem().PkgPath()
-		replyType := mtype.ReplyType.Elem().Name()
-		replyPkg := mtype.ReplyType.Elem().PkgPath()
-
-		if argsPkg != pkg {
-			pkgList[argsPkg] = 1
-			pkgName := getPkgName(argsPkg)
-			argsType = pkgName + "." + argsType
-		}
-		if replyPkg != pkg {
-			pkgList[replyPkg] = 1
-			pkgName := getPkgName(replyPkg)
-			replyType = pkgName + "." + replyType
+	names := make([]string, 0, len(p.methods))
+	for name := range p.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		mtype := p.methods[name]
+		if mtype.Stream { // 流方法始终以反射调用
+			continue
 		}
-
-		bean += "\tcase \"" + name + "\":\r\n"
-		bean += "\t\targs := new(" + argsType + ")\r\n"
+		argsType := qualifiedName(mtype.ArgType.Elem(), pkg, pkgList)
+		bean += "\tcase \"" + name + "\":\n"
+		bean += "\t\targs := new(" + argsType + ")\n"
 		if txt := autoWiredArgsFields(mtype.ArgType.Elem()); txt != "" {
 			bean += txt
 		}
-		bean += "\t\treply := new(" + replyType + ")\r\n"
-		bean += "\t\treturn args, reply, nil\r\n"
-
-		invoke += "\tcase \"" + name + "\":\r\n"
-		invoke += "\t\treturn proc.(*" + typ + ")." + name + "(args.(*" + argsType + "), reply.(*" + replyType + "))\r\n"
+		invoke += "\tcase \"" + name + "\":\n"
+		if mtype.ReplyType == nil { // 事件
+			bean += "\t\treturn args, nil, nil\n"
+			invoke += "\t\treturn proc.(*" + typ + ")." + name + "(args.(*" + argsType + "))\n"
+			continue
+		}
+		replyType := qualifiedName(mtype.ReplyType.Elem(), pkg, pkgList)
+		bean += "\t\treply := new(" + replyType + ")\n"
+		bean += "\t\treturn args, reply, nil\n"
+		invoke += "\t\treturn proc.(*" + typ + ")." + name + "(args.(*" + argsType + "), reply.(*" + replyType + "))\n"
+	}
+	imports := make([]string, 0, len(pkgList))
+	for pkg := range pkgList {
+		imports = append(imports, pkg)
 	}
-	for pkg, _ := range pkgList {
-		pkgs += "\t\"" + pkg + "\"\r\n"
+	sort.Strings(imports)
+	for _, pkg := range imports {
+		pkgs += "\t\"" + pkg + "\"\n"
 	}
 	return pkgs, bean, invoke
 }
@@ -139,14 +151,23 @@ func autoWiredArgsFields(argt reflect.Type) string {
 		auto := field.Tag.Get("auto")
 		if auto == "node" {
 			if field.Type.Kind() == reflect.String {
-				txt += "\t\targs." + field.Name + " = from\r\n"
+				txt += "\t\targs." + field.Name + " = from\n"
 			}
 		} else if auto == "ctx" {
 			typeKind := field.Type.Kind()
 			if typeKind == reflect.Ptr || typeKind == reflect.Interface {
-				txt += "\t\targs." + field.Name + " = ctx\r\n"
+				txt += "\t\targs." + field.Name + " = ctx\n"
 			}
 		}
 	}
 	return txt
 }
+
+// 类型在生成文件中的引用名，跨包类型会登记对应的import
+func qualifiedName(t reflect.Type, pkg string, pkgList map[string]byte) string {
+	if t.PkgPath() == "" || t.PkgPath() == pkg {
+		return t.Name()
+	}
+	pkgList[t.PkgPath()] = 1
+	return getPkgName(t.PkgPath()) + "." + t.Name()
+}
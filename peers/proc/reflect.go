@@ -11,6 +11,7 @@ type MethodType struct {
 	Method    reflect.Method
 	ArgType   reflect.Type
 	ReplyType reflect.Type
+	Stream    bool // 流方法，第二个参数为流接口(peers.Stream)而非应答结构
 }
 
 func WalkSuitableMethods(proc interface{}, on func(reflect.Method, reflect.Type, reflect.Type)) {
@@ -71,8 +72,8 @@ func SuitableMethods(typ reflect.Type) map[string]*MethodType {
 		var replyType reflect.Type
 		if numIn > 2 {
 			replyType = mtype.In(2)
-			if replyType.Kind() != reflect.Ptr {
-				continue // Second arg must be a pointer.
+			if replyType.Kind() != reflect.Ptr && replyType.Kind() != reflect.Interface {
+				continue // Second arg must be a pointer or a stream.
 			}
 			if !isExportedOrBuiltinType(replyType) {
 				continue // Reply type must be exported.
@@ -86,7 +87,8 @@ func SuitableMethods(typ reflect.Type) map[string]*MethodType {
 			continue // The return type of the method must be error.
 		}
 		if numIn > 2 {
-			methods[mname] = &MethodType{Method: method, ArgType: argType, ReplyType: replyType}
+			stream := replyType.Kind() == reflect.Interface // 具体的流接口类型由peers校验
+			methods[mname] = &MethodType{Method: method, ArgType: argType, ReplyType: replyType, Stream: stream}
 		} else {
 			methods[mname] = &MethodType{Method: method, ArgType: argType}
 		}
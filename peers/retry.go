@@ -0,0 +1,175 @@
+package peers
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 幂等方法的重试策略，仅在超时、发送失败等未得到被调方应答的情况下重试
+// 被调方返回的错误、调用方放弃及熔断拒绝均不重试
+type RetryPolicy struct {
+	Attempts   int // 总尝试次数(含首次)
+	Backoff    int // 首次重试前的等待时间(毫秒)，之后逐次翻倍
+	MaxBackoff int // 等待时间上限(毫秒)
+}
+
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		Attempts:   3,
+		Backoff:    50,
+		MaxBackoff: 1000,
+	}
+}
+
+// 第attempts次失败后的等待时间
+func (r *RetryPolicy) delay(attempts int) time.Duration {
+	delay := r.Backoff
+	for i := 1; i < attempts && delay < r.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		delay = r.MaxBackoff
+	}
+	return time.Duration(delay) * time.Millisecond
+}
+
+// 设置幂等方法的重试策略，policy为nil时关闭重试
+func (h *Hub) SetRetryPolicy(policy *RetryPolicy) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.retry = policy
+}
+
+func SetRetryPolicy(policy *RetryPolicy) {
+	_hub.SetRetryPolicy(policy)
+}
+
+// 登记幂等方法(形如PeerNick.FuncName)，其请求失败时按重试策略重试
+// 也可在参数结构中以标签peer:"idempotent"标记，例如 _ struct{} `peer:"idempotent"`
+func (h *Hub) RegisterIdempotent(methods ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for _, method := range methods {
+		h.idempotents[method] = true
+	}
+}
+
+func RegisterIdempotent(methods ...string) {
+	_hub.RegisterIdempotent(methods...)
+}
+
+// 请求适用的重试策略，非幂等方法返回nil
+func (h *Hub) retryPolicy(req *Request) *RetryPolicy {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	if h.retry == nil || h.retry.Attempts <= 1 {
+		return nil
+	}
+	if h.idempotents[req.Method] || idempotentArgs(req.Args) {
+		return h.retry
+	}
+	return nil
+}
+
+var _idempotentTypes sync.Map // 参数类型 -> 是否标记为幂等
+
+func idempotentArgs(args interface{}) bool {
+	t := reflect.TypeOf(args)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return false
+	}
+	if marked, exists := _idempotentTypes.Load(t); exists {
+		return marked.(bool)
+	}
+	marked := false
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("peer") == "idempotent" {
+			marked = true
+			break
+		}
+	}
+	_idempotentTypes.Store(t, marked)
+	return marked
+}
+
+// 等待重试的请求，节点关闭或上下文结束时直接以错误结束
+type retryTask struct {
+	timer int64
+	over  chan struct{}
+}
+
+// 于delay后重新发出请求；等待期间计入进行中的调用，关闭节点时不再等待
+func (h *Hub) scheduleRetry(req *Request, delay time.Duration, try func(), done func(error)) {
+	t := &retryTask{over: make(chan struct{})}
+	h.retryLock.Lock()
+	if h.draining {
+		h.retryLock.Unlock()
+		done(errNodeClosed())
+		return
+	}
+	t.timer = h.wheel.AfterFunc(delay, func() {
+		if h.takeoutRetry(t) {
+			go try() // 本节点内的调用可能同步执行，避免阻塞时间轮
+		}
+	})
+	h.retries[t] = done
+	h.retryLock.Unlock()
+
+	if c := req.Context.Done(); c != nil {
+		go func() {
+			select {
+			case <-c:
+				if h.takeoutRetry(t) {
+					done(req.Context.Err())
+				}
+			case <-t.over:
+			}
+		}()
+	}
+}
+
+func (h *Hub) takeoutRetry(t *retryTask) bool {
+	h.retryLock.Lock()
+	defer h.retryLock.Unlock()
+	if _, exists := h.retries[t]; !exists {
+		return false
+	}
+	delete(h.retries, t)
+	h.wheel.Cancel(t.timer)
+	close(t.over)
+	return true
+}
+
+func (h *Hub) retryNum() int {
+	h.retryLock.Lock()
+	defer h.retryLock.Unlock()
+	return len(h.retries)
+}
+
+// 节点关闭时结束所有等待中的重试，此后失败的请求不再重试
+func (h *Hub) abortRetries(err error) {
+	h.retryLock.Lock()
+	h.draining = true
+	dones := make([]func(error), 0, len(h.retries))
+	for t, done := range h.retries {
+		h.wheel.Cancel(t.timer)
+		close(t.over)
+		dones = append(dones, done)
+		delete(h.retries, t)
+	}
+	h.retryLock.Unlock()
+
+	for _, done := range dones {
+		done(err)
+	}
+}
+
+func errNodeClosed() error {
+	return errutil.New("节点已关闭,调用被中断!")
+}
@@ -0,0 +1,39 @@
+package peers
+
+import (
+	"sort"
+
+	"github.com/silvernodes/silvernode-go/peers/schema"
+)
+
+// 本节点对外开放(非Inner)的Peer的接口描述，不含无方法的Peer
+// 导出后可由silvernode sdk生成TypeScript/C#客户端
+func (h *Hub) Schema() *schema.Document {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	nicks := make([]string, 0, len(h.peers))
+	for nick, p := range h.peers {
+		if !p.inner && len(p.methods) > 0 {
+			nicks = append(nicks, nick)
+		}
+	}
+	sort.Strings(nicks)
+	builder := schema.NewBuilder(h.node.Name())
+	for _, nick := range nicks {
+		builder.AddService(nick, h.peers[nick].methods)
+	}
+	return builder.Document()
+}
+
+// 将接口描述写入JSON文件
+func (h *Hub) ExportSchema(file string) error {
+	return h.Schema().Save(file)
+}
+
+func Schema() *schema.Document {
+	return _hub.Schema()
+}
+
+func ExportSchema(file string) error {
+	return _hub.ExportSchema(file)
+}
@@ -0,0 +1,567 @@
+package schema
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/silvernodes/silvernode-go/peers/proc"
+)
+
+// 生成C#客户端(单文件)，基于ClientWebSocket及System.Text.Json，适用于.NET 6以上版本
+func CSharp(doc *Document, namespace string) string {
+	var sb strings.Builder
+	sb.WriteString(proc.GENERATED_HEADER + "\n")
+	if doc.Title != "" {
+		sb.WriteString("// source: " + doc.Title + "\n")
+	}
+	sb.WriteString(csUsings)
+	sb.WriteString("\nnamespace " + namespace + "\n{")
+	sb.WriteString(csRuntime)
+	for _, name := range doc.DefinitionNames() {
+		def := doc.Definitions[name]
+		sb.WriteString("\n")
+		if def.GoType != "" {
+			sb.WriteString("    /// <summary>" + def.GoType + "</summary>\n")
+		}
+		sb.WriteString("    public class " + name + "\n    {\n")
+		members := map[string]bool{name: true} // 成员名不能与类名相同
+		for i, prop := range def.PropertyNames() {
+			member := identifier(prop)
+			for member == "" || members[member] {
+				member += "_"
+			}
+			members[member] = true
+			if i > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString("        [JsonPropertyName(" + strconv.Quote(prop) + ")]\n")
+			sb.WriteString("        public " + csType(def.Properties[prop]) + " " + member + " { get; set; }\n")
+		}
+		sb.WriteString("    }\n")
+	}
+	for _, service := range doc.Services {
+		className := identifier(service.Name) + "Client"
+		sb.WriteString("\n")
+		sb.WriteString("    /// <summary>" + service.Name + "的类型化客户端，目标Peer以其他昵称注册时可指定nick</summary>\n")
+		sb.WriteString("    public sealed class " + className + "\n    {\n")
+		sb.WriteString("        private readonly SilvernodeClient client;\n")
+		sb.WriteString("        private readonly string nick;\n\n")
+		sb.WriteString("        public " + className + "(SilvernodeClient client, string nick = " + strconv.Quote(service.Name) + ")\n        {\n")
+		sb.WriteString("            this.client = client;\n")
+		sb.WriteString("            this.nick = nick;\n")
+		sb.WriteString("        }\n")
+		for _, method := range service.Methods {
+			call := "nick + \"." + method.Name + "\""
+			args := csType(method.Args)
+			sb.WriteString("\n")
+			switch method.Kind {
+			case KIND_CALL:
+				reply := csType(method.Reply)
+				sb.WriteString(fmt.Sprintf("        public Task<%s> %sAsync(%s args, CallOptions options = null, CancellationToken cancellation = default)\n", reply, method.Name, args))
+				sb.WriteString(fmt.Sprintf("            => client.InvokeAsync<%s>(%s, args, options, cancellation);\n", reply, call))
+			case KIND_EVENT:
+				sb.WriteString(fmt.Sprintf("        public Task %sAsync(%s args, CallOptions options = null)\n", method.Name, args))
+				sb.WriteString(fmt.Sprintf("            => client.SendEventAsync(%s, args, options);\n", call))
+			default:
+				sb.WriteString("        // " + method.Name + "为流方法，暂不支持\n")
+			}
+		}
+		sb.WriteString("    }\n")
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func csType(t *Type) string {
+	var ret string
+	value := true // 值类型可空时需加?
+	switch {
+	case t.Ref != "":
+		ret, value = t.RefName(), false
+	case t.Type == "string" && t.Format == "date-time":
+		ret = "DateTimeOffset"
+	case t.Type == "string" && t.Format == "byte":
+		ret, value = "byte[]", false
+	case t.Type == "string":
+		ret, value = "string", false
+	case t.Type == "integer":
+		ret = csIntegers[t.Format]
+		if ret == "" {
+			ret = "long"
+		}
+	case t.Type == "number" && t.Format == "float":
+		ret = "float"
+	case t.Type == "number":
+		ret = "double"
+	case t.Type == "boolean":
+		ret = "bool"
+	case t.Type == "array":
+		ret, value = "List<"+csType(t.Items)+">", false
+	case t.Type == "object" && t.AdditionalProperties != nil:
+		ret, value = "Dictionary<string, "+csType(t.AdditionalProperties)+">", false
+	default:
+		ret, value = "object", false // 反序列化为JsonElement
+	}
+	if t.Nullable && value {
+		ret += "?"
+	}
+	return ret
+}
+
+var csIntegers = map[string]string{
+	"int8":   "sbyte",
+	"int16":  "short",
+	"int32":  "int",
+	"int64":  "long",
+	"uint8":  "byte",
+	"uint16": "ushort",
+	"uint32": "uint",
+	"uint64": "ulong",
+}
+
+const csUsings = `
+using System;
+using System.Collections.Concurrent;
+using System.Collections.Generic;
+using System.IO;
+using System.Linq;
+using System.Net.WebSockets;
+using System.Text;
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using System.Threading;
+using System.Threading.Tasks;
+`
+
+// 与peers的交互数据格式一致：int32及int64均为小端序，字符串为int32字节长度+UTF-8内容
+const csRuntime = `
+    /// <summary>交互数据，字段与服务端的exchange一致</summary>
+    public sealed class Exchange
+    {
+        public const int Mark = -0x21676f76;
+        public const byte Version = 1;
+        public const byte RetRequest = 0;
+        public const byte RetResponse = 1;
+        public const byte RetCancel = 2;
+
+        public string From = "";
+        public string To = "";
+        public string Func = "";
+        public long Seq;
+        public byte Ret;
+        public string Err = "";
+        public long TimeLeft;
+        public IDictionary<string, string> Metadata;
+        public byte[] Body;
+
+        /// <summary>以V1格式编码，与exchange.Marshal一致</summary>
+        public byte[] Encode()
+        {
+            using (var stream = new MemoryStream(256))
+            using (var writer = new BinaryWriter(stream))
+            {
+                writer.Write(Mark);
+                writer.Write(Version);
+                WriteString(writer, From);
+                WriteString(writer, To);
+                WriteString(writer, Func);
+                writer.Write(Seq);
+                writer.Write(Ret);
+                WriteString(writer, Err);
+                writer.Write(TimeLeft);
+                var keys = Metadata == null ? new List<string>() : Metadata.Keys.OrderBy(k => k, StringComparer.Ordinal).ToList();
+                writer.Write(keys.Count);
+                foreach (var key in keys)
+                {
+                    WriteString(writer, key);
+                    WriteString(writer, Metadata[key]);
+                }
+                if (Body != null)
+                {
+                    writer.Write(Body);
+                }
+                writer.Flush();
+                return stream.ToArray();
+            }
+        }
+
+        /// <summary>可解析旧版、V1及V2格式，与exchange.Unmarshal一致</summary>
+        public static Exchange Decode(byte[] data, int length)
+        {
+            using (var reader = new BinaryReader(new MemoryStream(data, 0, length)))
+            {
+                var version = 0;
+                if (length >= 4 && BitConverter.ToInt32(data, 0) == Mark)
+                {
+                    reader.ReadInt32();
+                    version = reader.ReadByte();
+                    if (version > 2)
+                    {
+                        throw new InvalidDataException("不支持的交互数据版本:" + version);
+                    }
+                }
+                var e = new Exchange
+                {
+                    From = ReadString(reader),
+                    To = ReadString(reader),
+                    Func = ReadString(reader),
+                    Seq = reader.ReadInt64(),
+                    Ret = reader.ReadByte(),
+                    Err = ReadString(reader),
+                };
+                if (version >= 1)
+                {
+                    e.TimeLeft = reader.ReadInt64();
+                    var count = reader.ReadInt32();
+                    e.Metadata = new Dictionary<string, string>();
+                    for (var i = 0; i < count; i++)
+                    {
+                        var key = ReadString(reader);
+                        e.Metadata[key] = ReadString(reader);
+                    }
+                }
+                if (version >= 2)
+                {
+                    reader.ReadInt64(); // 流id
+                    reader.ReadInt32(); // 流量额度
+                }
+                var rest = length - (int)reader.BaseStream.Position;
+                if (rest > 0)
+                {
+                    e.Body = reader.ReadBytes(rest);
+                }
+                return e;
+            }
+        }
+
+        private static void WriteString(BinaryWriter writer, string value)
+        {
+            var bytes = Encoding.UTF8.GetBytes(value ?? "");
+            writer.Write(bytes.Length);
+            writer.Write(bytes);
+        }
+
+        private static string ReadString(BinaryReader reader)
+        {
+            var length = reader.ReadInt32();
+            if (length < 0 || length > reader.BaseStream.Length - reader.BaseStream.Position)
+            {
+                throw new InvalidDataException("交互数据长度不足");
+            }
+            return Encoding.UTF8.GetString(reader.ReadBytes(length));
+        }
+    }
+
+    public sealed class CallOptions
+    {
+        /// <summary>请求超时，随请求传递至服务端</summary>
+        public TimeSpan? Timeout;
+        /// <summary>随请求传递的元数据，服务端以peers.MetadataFrom读取</summary>
+        public IDictionary<string, string> Metadata;
+    }
+
+    public sealed class SilvernodeException : Exception
+    {
+        /// <summary>服务端返回的错误，否则为本端的超时、取消或链接错误</summary>
+        public bool Remote { get; }
+
+        public SilvernodeException(string message, bool remote) : base(message)
+        {
+            Remote = remote;
+        }
+    }
+
+    /// <summary>以访客身份链接到对外开放(IsPub)的节点，交互数据体以JSON编码(服务端缺省的OuterCodec)</summary>
+    public sealed class SilvernodeClient : IDisposable
+    {
+        /// <summary>本端的Peer昵称，服务端的应答及推送以此为目标</summary>
+        public string Nick { get; }
+        public TimeSpan Timeout { get; }
+        public JsonSerializerOptions JsonOptions { get; } = new JsonSerializerOptions();
+        public event Action<Exception> Closed;
+        public event Action<Exception> Error;
+
+        private readonly ClientWebSocket socket = new ClientWebSocket();
+        private readonly SemaphoreSlim sendLock = new SemaphoreSlim(1, 1);
+        private readonly ConcurrentDictionary<long, TaskCompletionSource<Exchange>> pending = new ConcurrentDictionary<long, TaskCompletionSource<Exchange>>();
+        private readonly ConcurrentDictionary<string, Func<Exchange, Task<object>>> handlers = new ConcurrentDictionary<string, Func<Exchange, Task<object>>>();
+        private long seq;
+
+        public SilvernodeClient(string nick = "Client", TimeSpan? timeout = null)
+        {
+            Nick = nick;
+            Timeout = timeout ?? TimeSpan.FromSeconds(10);
+        }
+
+        /// <summary>如ws://127.0.0.1:8080/ws</summary>
+        public async Task ConnectAsync(Uri uri, CancellationToken cancellation = default)
+        {
+            await socket.ConnectAsync(uri, cancellation).ConfigureAwait(false);
+            _ = Task.Run(ReceiveLoop);
+        }
+
+        public Task CloseAsync()
+        {
+            return socket.CloseAsync(WebSocketCloseStatus.NormalClosure, "", CancellationToken.None);
+        }
+
+        public void Dispose()
+        {
+            socket.Dispose();
+            sendLock.Dispose();
+        }
+
+        /// <summary>登记服务端向本端发送的事件的处理方法，method形如"Client.OnChat"</summary>
+        public void Handle<TArgs>(string method, Action<TArgs> handler)
+        {
+            SplitMethod(method);
+            handlers[method] = e =>
+            {
+                handler(DecodeBody<TArgs>(e.Body));
+                return Task.FromResult<object>(null);
+            };
+        }
+
+        /// <summary>登记服务端向本端发起的请求的处理方法，返回值作为应答</summary>
+        public void Handle<TArgs, TReply>(string method, Func<TArgs, Task<TReply>> handler)
+        {
+            SplitMethod(method);
+            handlers[method] = async e => await handler(DecodeBody<TArgs>(e.Body)).ConfigureAwait(false);
+        }
+
+        /// <summary>发起请求并等待应答，method形如"Room.Join"</summary>
+        public async Task<TReply> InvokeAsync<TReply>(string method, object args, CallOptions options = null, CancellationToken cancellation = default)
+        {
+            var (to, func) = SplitMethod(method);
+            var timeout = options?.Timeout ?? Timeout;
+            var seq = Interlocked.Increment(ref this.seq);
+            var tcs = new TaskCompletionSource<Exchange>(TaskCreationOptions.RunContinuationsAsynchronously);
+            pending[seq] = tcs;
+            using (var timer = CancellationTokenSource.CreateLinkedTokenSource(cancellation))
+            using (timer.Token.Register(() => tcs.TrySetCanceled()))
+            {
+                timer.CancelAfter(timeout);
+                Exchange reply;
+                try
+                {
+                    await PostAsync(new Exchange
+                    {
+                        From = Nick,
+                        To = to,
+                        Func = func,
+                        Seq = seq,
+                        Ret = Exchange.RetRequest,
+                        TimeLeft = (long)Math.Ceiling(timeout.TotalMilliseconds),
+                        Metadata = options?.Metadata,
+                        Body = EncodeBody(args),
+                    }).ConfigureAwait(false);
+                    reply = await tcs.Task.ConfigureAwait(false);
+                }
+                catch (OperationCanceledException)
+                {
+                    // 超时或放弃时通知服务端中止处理
+                    await TryPostAsync(new Exchange { From = Nick, To = to, Seq = seq, Ret = Exchange.RetCancel }).ConfigureAwait(false);
+                    var reason = cancellation.IsCancellationRequested ? "请求已取消:" : "请求超时:";
+                    throw new SilvernodeException(reason + method, false);
+                }
+                finally
+                {
+                    pending.TryRemove(seq, out _);
+                }
+                if (reply.Err != "")
+                {
+                    throw new SilvernodeException(reply.Err, true);
+                }
+                return DecodeBody<TReply>(reply.Body);
+            }
+        }
+
+        /// <summary>发送事件，不等待应答</summary>
+        public Task SendEventAsync(string method, object args, CallOptions options = null)
+        {
+            var (to, func) = SplitMethod(method);
+            return PostAsync(new Exchange
+            {
+                From = Nick,
+                To = to,
+                Func = func,
+                Ret = Exchange.RetRequest,
+                Metadata = options?.Metadata,
+                Body = EncodeBody(args),
+            });
+        }
+
+        private static (string, string) SplitMethod(string method)
+        {
+            var parts = method.Split('.');
+            if (parts.Length != 2)
+            {
+                throw new SilvernodeException("方法名必须符合PeerNick.FuncName的规范:" + method, false);
+            }
+            return (parts[0], parts[1]);
+        }
+
+        private byte[] EncodeBody(object value)
+        {
+            return JsonSerializer.SerializeToUtf8Bytes(value, value?.GetType() ?? typeof(object), JsonOptions);
+        }
+
+        private T DecodeBody<T>(byte[] body)
+        {
+            return body == null ? default : JsonSerializer.Deserialize<T>(body, JsonOptions);
+        }
+
+        private async Task PostAsync(Exchange e)
+        {
+            var data = e.Encode();
+            await sendLock.WaitAsync().ConfigureAwait(false);
+            try
+            {
+                await socket.SendAsync(new ArraySegment<byte>(data), WebSocketMessageType.Binary, true, CancellationToken.None).ConfigureAwait(false);
+            }
+            finally
+            {
+                sendLock.Release();
+            }
+        }
+
+        private async Task TryPostAsync(Exchange e)
+        {
+            try
+            {
+                await PostAsync(e).ConfigureAwait(false);
+            }
+            catch (Exception)
+            {
+                // 链接已断开
+            }
+        }
+
+        private async Task ReceiveLoop()
+        {
+            var buffer = new byte[4096];
+            Exception reason;
+            try
+            {
+                while (true)
+                {
+                    var length = 0;
+                    WebSocketReceiveResult result;
+                    do
+                    {
+                        if (length == buffer.Length)
+                        {
+                            Array.Resize(ref buffer, buffer.Length * 2);
+                        }
+                        result = await socket.ReceiveAsync(new ArraySegment<byte>(buffer, length, buffer.Length - length), CancellationToken.None).ConfigureAwait(false);
+                        length += result.Count;
+                    } while (!result.EndOfMessage);
+                    if (result.MessageType == WebSocketMessageType.Close)
+                    {
+                        reason = new SilvernodeException("链接已关闭:" + result.CloseStatusDescription, false);
+                        break;
+                    }
+                    if (result.MessageType == WebSocketMessageType.Binary)
+                    {
+                        Dispatch(buffer, length);
+                    }
+                }
+            }
+            catch (Exception ex)
+            {
+                reason = new SilvernodeException("链接已关闭:" + ex.Message, false);
+            }
+            foreach (var seq in pending.Keys)
+            {
+                if (pending.TryRemove(seq, out var tcs))
+                {
+                    tcs.TrySetException(reason);
+                }
+            }
+            Closed?.Invoke(reason);
+        }
+
+        private void Dispatch(byte[] data, int length)
+        {
+            Exchange e;
+            try
+            {
+                e = Exchange.Decode(data, length);
+            }
+            catch (Exception ex)
+            {
+                Error?.Invoke(ex);
+                return;
+            }
+            if (e.Ret == Exchange.RetResponse)
+            {
+                if (pending.TryRemove(e.Seq, out var tcs))
+                {
+                    tcs.TrySetResult(e);
+                }
+            }
+            else if (e.Ret == Exchange.RetRequest)
+            {
+                _ = ServeAsync(e);
+            }
+        }
+
+        private async Task ServeAsync(Exchange e)
+        {
+            object reply = null;
+            var err = "";
+            if (!handlers.TryGetValue(e.To + "." + e.Func, out var handler))
+            {
+                err = "方法不存在:" + e.Func;
+            }
+            else
+            {
+                try
+                {
+                    reply = await handler(e).ConfigureAwait(false);
+                }
+                catch (Exception ex)
+                {
+                    err = ex.Message;
+                }
+            }
+            if (e.Seq == 0)
+            {
+                if (err != "")
+                {
+                    Error?.Invoke(new SilvernodeException("目标事件执行异常:" + e.To + "." + e.Func + ":" + err, false));
+                }
+                return;
+            }
+            byte[] body = null;
+            if (err == "")
+            {
+                try
+                {
+                    body = EncodeBody(reply);
+                }
+                catch (Exception ex)
+                {
+                    err = "应答结果序列化出错:" + ex.Message;
+                }
+            }
+            try
+            {
+                await PostAsync(new Exchange
+                {
+                    From = e.To,
+                    To = e.From,
+                    Seq = e.Seq,
+                    Ret = Exchange.RetResponse,
+                    Err = err,
+                    Body = body,
+                }).ConfigureAwait(false);
+            }
+            catch (Exception ex)
+            {
+                Error?.Invoke(ex);
+            }
+        }
+    }
+`
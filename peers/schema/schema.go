@@ -0,0 +1,379 @@
+// 对外开放的Peer的接口描述(JSON Schema)，供生成前端SDK使用
+//
+// 文档由各Peer的方法表(proc.SuitableMethods)经反射得到，结构体按encoding/json的规则描述：
+// 字段名取json标签，标签为"-"、未导出及带auto标签(由服务端自动填充)的字段不出现在文档中，
+// 匿名嵌入的结构体字段展开至外层，匿名结构体以"外层类型名+字段名"命名后列入definitions
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/silvernodes/silvernode-go/peers/proc"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+const (
+	SCHEMA_DRAFT = "http://json-schema.org/draft-07/schema#"
+	REF_PREFIX   = "#/definitions/"
+)
+
+// 方法类型
+const (
+	KIND_CALL   = "call"   // 请求-应答
+	KIND_EVENT  = "event"  // 事件，不等待应答
+	KIND_STREAM = "stream" // 流方法，前端SDK暂不支持
+)
+
+type Document struct {
+	Schema      string           `json:"$schema"`
+	Title       string           `json:"title,omitempty"`
+	Services    []*Service       `json:"services"`
+	Definitions map[string]*Type `json:"definitions"`
+}
+
+// 对应一个Peer，Name为其注册昵称
+type Service struct {
+	Name    string    `json:"name"`
+	Methods []*Method `json:"methods"`
+}
+
+type Method struct {
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Args  *Type  `json:"args"`
+	Reply *Type  `json:"reply,omitempty"` // 事件及流方法无应答
+}
+
+// JSON Schema的子集，结构体均以$ref引用definitions中的定义
+type Type struct {
+	Ref                  string           `json:"$ref,omitempty"`
+	Type                 string           `json:"type,omitempty"` // 为空时表示任意类型
+	Format               string           `json:"format,omitempty"`
+	Nullable             bool             `json:"nullable,omitempty"`
+	Items                *Type            `json:"items,omitempty"`
+	AdditionalProperties *Type            `json:"additionalProperties,omitempty"`
+	Properties           map[string]*Type `json:"properties,omitempty"`
+	Order                []string         `json:"x-order,omitempty"` // 属性的声明顺序
+	Required             []string         `json:"required,omitempty"`
+	GoType               string           `json:"x-go-type,omitempty"`
+}
+
+// 引用的定义名，非引用时为空
+func (t *Type) RefName() string {
+	return strings.TrimPrefix(t.Ref, REF_PREFIX)
+}
+
+// 按声明顺序排列的属性名
+func (t *Type) PropertyNames() []string {
+	if len(t.Order) == len(t.Properties) {
+		return t.Order
+	}
+	names := make([]string, 0, len(t.Properties))
+	for name := range t.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *Type) IsRequired(name string) bool {
+	for _, r := range t.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 按名称排列的定义名
+func (d *Document) DefinitionNames() []string {
+	names := make([]string, 0, len(d.Definitions))
+	for name := range d.Definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *Document) Marshal() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+func (d *Document) Save(file string) error {
+	data, err := d.Marshal()
+	if err != nil {
+		return errutil.Extend("接口描述序列化出错", err)
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+func Load(file string) (*Document, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	doc := new(Document)
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, errutil.Extend("接口描述解析出错:"+file, err)
+	}
+	if doc.Definitions == nil {
+		doc.Definitions = make(map[string]*Type)
+	}
+	return doc, nil
+}
+
+var (
+	typeOfTime          = reflect.TypeOf(time.Time{})
+	typeOfJsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeOfTextMarshaler = reflect.TypeOf((*interface{ MarshalText() ([]byte, error) })(nil)).Elem()
+)
+
+// 由方法表逐个添加Peer，同一Go类型只生成一份定义
+type Builder struct {
+	doc   *Document
+	names map[reflect.Type]string
+}
+
+func NewBuilder(title string) *Builder {
+	b := new(Builder)
+	b.doc = &Document{
+		Schema:      SCHEMA_DRAFT,
+		Title:       title,
+		Services:    make([]*Service, 0, 8),
+		Definitions: make(map[string]*Type),
+	}
+	b.names = make(map[reflect.Type]string)
+	return b
+}
+
+// 方法按名称排列，流方法仅记录参数
+func (b *Builder) AddService(nick string, methods map[string]*proc.MethodType) {
+	names := make([]string, 0, len(methods))
+	for name := range methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	service := &Service{Name: nick, Methods: make([]*Method, 0, len(names))}
+	for _, name := range names {
+		mtype := methods[name]
+		method := &Method{Name: name, Args: b.typeOf(mtype.ArgType, "")}
+		switch {
+		case mtype.Stream:
+			method.Kind = KIND_STREAM
+		case mtype.ReplyType == nil:
+			method.Kind = KIND_EVENT
+		default:
+			method.Kind = KIND_CALL
+			method.Reply = b.typeOf(mtype.ReplyType, "")
+		}
+		method.Args.Nullable = false // 顶层的参数及应答总是存在
+		if method.Reply != nil {
+			method.Reply.Nullable = false
+		}
+		service.Methods = append(service.Methods, method)
+	}
+	b.doc.Services = append(b.doc.Services, service)
+}
+
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+// hint为匿名结构体的命名
+func (b *Builder) typeOf(t reflect.Type, hint string) *Type {
+	if t.Kind() == reflect.Ptr {
+		ret := b.typeOf(t.Elem(), hint)
+		ret.Nullable = true
+		return ret
+	}
+	switch {
+	case t == typeOfTime:
+		return &Type{Type: "string", Format: "date-time"}
+	case implements(t, typeOfJsonMarshaler):
+		return &Type{} // 自定义的JSON格式无法推断
+	case implements(t, typeOfTextMarshaler):
+		return &Type{Type: "string"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && !implements(t.Elem(), typeOfJsonMarshaler) && !implements(t.Elem(), typeOfTextMarshaler):
+		return &Type{Type: "string", Format: "byte"} // base64
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Type{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Type{Type: "integer", Format: t.Kind().String()}
+	case reflect.Int, reflect.Uint:
+		return &Type{Type: "integer", Format: t.Kind().String() + "64"}
+	case reflect.Float32:
+		return &Type{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Type{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Type{Type: "string"}
+	case reflect.Slice:
+		return &Type{Type: "array", Items: b.typeOf(t.Elem(), hint), Nullable: true}
+	case reflect.Array:
+		return &Type{Type: "array", Items: b.typeOf(t.Elem(), hint)}
+	case reflect.Map:
+		return &Type{Type: "object", AdditionalProperties: b.typeOf(t.Elem(), hint), Nullable: true}
+	case reflect.Struct:
+		return &Type{Ref: REF_PREFIX + b.define(t, hint)}
+	default: // interface{}等
+		return &Type{}
+	}
+}
+
+func (b *Builder) define(t reflect.Type, hint string) string {
+	if name, exists := b.names[t]; exists {
+		return name
+	}
+	name := b.nameOf(t, hint)
+	b.names[t] = name
+	def := &Type{Type: "object", Properties: make(map[string]*Type), GoType: t.String()}
+	b.doc.Definitions[name] = def // 先占位，以支持自引用
+	b.fields(def, t, name, 0, make(map[string]int))
+	return name
+}
+
+// 同名的不同类型以包名区分
+func (b *Builder) nameOf(t reflect.Type, hint string) string {
+	name := t.Name()
+	if i := strings.IndexByte(name, '['); i >= 0 { // 泛型实例
+		name = name[:i]
+	}
+	if name == "" {
+		name = hint
+	}
+	if name == "" {
+		name = "Anonymous"
+	}
+	if _, exists := b.doc.Definitions[name]; !exists {
+		return name
+	}
+	if pkg := t.PkgPath(); pkg != "" {
+		pkg = pkg[strings.LastIndexByte(pkg, '/')+1:]
+		qualified := identifier(pkg) + name
+		if _, exists := b.doc.Definitions[qualified]; !exists {
+			return qualified
+		}
+		name = qualified
+	}
+	for i := 2; ; i++ {
+		numbered := name + strconv.Itoa(i)
+		if _, exists := b.doc.Definitions[numbered]; !exists {
+			return numbered
+		}
+	}
+}
+
+// depths记录各属性所在的嵌入层级，同名时层级浅的优先
+func (b *Builder) fields(def *Type, t reflect.Type, owner string, depth int, depths map[string]int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("auto") != "" {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, opts = tag[:i], tag[i:]
+		}
+		ft := field.Type
+		if field.Anonymous {
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if name == "" && ft.Kind() == reflect.Struct {
+				b.fields(def, ft, owner, depth+1, depths) // 嵌入的结构体展开至外层
+				continue
+			}
+			if !field.IsExported() && ft.Kind() != reflect.Struct {
+				continue
+			}
+		} else if !field.IsExported() {
+			continue
+		}
+		switch ft.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+			continue // encoding/json不支持
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if d, exists := depths[name]; exists && d <= depth {
+			continue
+		}
+		var prop *Type
+		if strings.Contains(opts, ",string") && isQuotable(field.Type) {
+			prop = &Type{Type: "string"}
+		} else {
+			prop = b.typeOf(field.Type, owner+field.Name)
+		}
+		if _, exists := depths[name]; !exists {
+			def.Order = append(def.Order, name)
+		}
+		depths[name] = depth
+		def.Properties[name] = prop
+		def.Required = remove(def.Required, name)
+		if !strings.Contains(opts, ",omitempty") {
+			def.Required = append(def.Required, name)
+		}
+	}
+}
+
+func implements(t reflect.Type, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+func remove(names []string, name string) []string {
+	for i, n := range names {
+		if n == name {
+			return append(names[:i], names[i+1:]...)
+		}
+	}
+	return names
+}
+
+// 可用",string"选项以字符串编码的类型
+func isQuotable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return false
+}
+
+// 转换为合法的标识符，首字母大写
+func identifier(s string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			upper = true
+			continue
+		}
+		if sb.Len() == 0 && unicode.IsDigit(r) {
+			sb.WriteByte('_')
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
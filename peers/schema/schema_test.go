@@ -0,0 +1,171 @@
+package schema
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers/proc"
+)
+
+var update = flag.Bool("update", false, "以生成结果更新testdata中的golden文件")
+
+type Base struct {
+	Id      int64 `json:"id"`
+	Version int   `json:"version,omitempty"`
+}
+
+type Player struct {
+	Name  string            `json:"name"`
+	Level int               `json:"level,string"`
+	Tags  []string          `json:"tags,omitempty"`
+	Stats map[string]uint32 `json:"stats"`
+	Guild *Player           `json:"guild,omitempty"` // 自引用
+}
+
+type JoinReq struct {
+	Base
+	Ctx     context.Context `auto:"context"`
+	From    string          `auto:"node"`
+	Room    string          `json:"room"`
+	Player  *Player         `json:"player"`
+	Secret  string          `json:"-"`
+	Options struct {
+		Spectate bool    `json:"spectate"`
+		Ratio    float32 `json:"ratio,omitempty"`
+	} `json:"options"`
+	Id    string `json:"id"` // 与嵌入的Base.Id同名，外层优先
+	local int
+}
+
+type JoinResp struct {
+	Seat    int       `json:"seat"`
+	Avatar  []byte    `json:"avatar"`
+	Joined  time.Time `json:"joined"`
+	Players []Player  `json:"players"`
+	Any     interface{}
+}
+
+type Room struct {
+}
+
+func (r *Room) Join(args *JoinReq, reply *JoinResp) error {
+	return nil
+}
+
+func (r *Room) Leave(args *JoinReq) error {
+	return nil
+}
+
+type Lobby struct {
+}
+
+func (l *Lobby) Ping(args *Base, reply *Base) error {
+	return nil
+}
+
+func buildDoc() *Document {
+	b := NewBuilder("room")
+	b.AddService("Lobby", proc.SuitableMethods(reflect.TypeOf(new(Lobby))))
+	b.AddService("Room", proc.SuitableMethods(reflect.TypeOf(new(Room))))
+	return b.Document()
+}
+
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Fatalf("生成结果与%s不一致，确认无误后以-update更新:\n%s", golden, got)
+	}
+}
+
+func TestSchemaGolden(t *testing.T) {
+	data, err := buildDoc().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "schema.json", string(data)+"\n")
+	checkGolden(t, "silvernode.ts", TypeScript(buildDoc()))
+	checkGolden(t, "Silvernode.cs", CSharp(buildDoc(), "Silvernode"))
+}
+
+// 多次生成及保存后重新加载，结果均一致
+func TestSchemaDeterministic(t *testing.T) {
+	doc := buildDoc()
+	data, _ := doc.Marshal()
+	ts, cs := TypeScript(doc), CSharp(doc, "Silvernode")
+	for i := 0; i < 5; i++ {
+		again := buildDoc()
+		if next, _ := again.Marshal(); string(next) != string(data) {
+			t.Fatal("两次导出的接口描述不一致")
+		}
+		if TypeScript(again) != ts || CSharp(again, "Silvernode") != cs {
+			t.Fatal("两次生成的客户端不一致")
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "schema.json")
+	if err := doc.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if TypeScript(loaded) != ts || CSharp(loaded, "Silvernode") != cs {
+		t.Fatal("由导出文件生成的客户端不一致")
+	}
+}
+
+// 结构体按encoding/json的规则描述
+func TestSchemaFields(t *testing.T) {
+	doc := buildDoc()
+	join := doc.Definitions["JoinReq"]
+	if join == nil {
+		t.Fatalf("缺少JoinReq的定义: %v", doc.DefinitionNames())
+	}
+	if got := strings.Join(join.PropertyNames(), ","); got != "id,version,room,player,options" {
+		t.Fatalf("JoinReq的属性不符: %s", got)
+	}
+	if join.Properties["id"].Type != "string" {
+		t.Fatalf("外层同名字段应优先: %+v", join.Properties["id"])
+	}
+	if join.IsRequired("version") || !join.IsRequired("room") {
+		t.Fatalf("omitempty的字段不应为必需: %v", join.Required)
+	}
+	if ref := join.Properties["options"].RefName(); ref != "JoinReqOptions" || doc.Definitions[ref] == nil {
+		t.Fatalf("匿名结构体应以外层类型名+字段名命名: %s", ref)
+	}
+	if player := join.Properties["player"]; player.RefName() != "Player" || !player.Nullable {
+		t.Fatalf("指针应可为null: %+v", player)
+	}
+	if level := doc.Definitions["Player"].Properties["level"]; level.Type != "string" {
+		t.Fatalf(",string选项应描述为字符串: %+v", level)
+	}
+	resp := doc.Definitions["JoinResp"]
+	if avatar := resp.Properties["avatar"]; avatar.Type != "string" || avatar.Format != "byte" {
+		t.Fatalf("[]byte应描述为base64字符串: %+v", avatar)
+	}
+	if joined := resp.Properties["joined"]; joined.Format != "date-time" {
+		t.Fatalf("time.Time应描述为date-time: %+v", joined)
+	}
+
+	methods := doc.Services[1].Methods
+	if doc.Services[1].Name != "Room" || len(methods) != 2 || methods[0].Kind != KIND_CALL || methods[1].Kind != KIND_EVENT || methods[1].Reply != nil {
+		t.Fatalf("Room的方法不符: %+v", methods)
+	}
+}
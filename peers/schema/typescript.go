@@ -0,0 +1,568 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/silvernodes/silvernode-go/peers/proc"
+)
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// 生成TypeScript客户端(单文件)，包含交互数据的编解码、WebSocket链接及各Peer的类型化客户端
+// 仅使用可直接擦除的类型语法，可由tsc编译，也可由Node.js 22以上版本直接执行
+func TypeScript(doc *Document) string {
+	var sb strings.Builder
+	sb.WriteString(proc.GENERATED_HEADER + "\n")
+	if doc.Title != "" {
+		sb.WriteString("// source: " + doc.Title + "\n")
+	}
+	sb.WriteString(tsRuntime)
+	for _, name := range doc.DefinitionNames() {
+		def := doc.Definitions[name]
+		sb.WriteString("\n")
+		if def.GoType != "" {
+			sb.WriteString("/** " + def.GoType + " */\n")
+		}
+		sb.WriteString("export interface " + name + " {\n")
+		for _, prop := range def.PropertyNames() {
+			optional := ""
+			if !def.IsRequired(prop) {
+				optional = "?"
+			}
+			key := prop
+			if !tsIdentifier.MatchString(key) {
+				key = fmt.Sprintf("%q", key)
+			}
+			sb.WriteString("  " + key + optional + ": " + tsType(def.Properties[prop]) + ";\n")
+		}
+		sb.WriteString("}\n")
+	}
+	for _, service := range doc.Services {
+		className := identifier(service.Name) + "Client"
+		sb.WriteString("\n")
+		sb.WriteString("/** " + service.Name + "的类型化客户端，目标Peer以其他昵称注册时可指定nick */\n")
+		sb.WriteString("export class " + className + " {\n")
+		sb.WriteString("  private readonly client: SilvernodeClient;\n")
+		sb.WriteString("  private readonly nick: string;\n\n")
+		sb.WriteString("  constructor(client: SilvernodeClient, nick: string = " + fmt.Sprintf("%q", service.Name) + ") {\n")
+		sb.WriteString("    this.client = client;\n")
+		sb.WriteString("    this.nick = nick;\n")
+		sb.WriteString("  }\n")
+		for _, method := range service.Methods {
+			name := lowerFirst(method.Name)
+			call := "this.nick + \"." + method.Name + "\""
+			args := tsType(method.Args)
+			sb.WriteString("\n")
+			switch method.Kind {
+			case KIND_CALL:
+				reply := tsType(method.Reply)
+				sb.WriteString("  " + name + "(args: " + args + ", options?: CallOptions): Promise<" + reply + "> {\n")
+				sb.WriteString("    return this.client.invoke<" + reply + ">(" + call + ", args, options);\n")
+				sb.WriteString("  }\n")
+			case KIND_EVENT:
+				sb.WriteString("  " + name + "(args: " + args + ", options?: CallOptions): void {\n")
+				sb.WriteString("    this.client.sendEvent(" + call + ", args, options);\n")
+				sb.WriteString("  }\n")
+			default:
+				sb.WriteString("  // " + method.Name + "为流方法，暂不支持\n")
+			}
+		}
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+func tsType(t *Type) string {
+	var ret string
+	switch {
+	case t.Ref != "":
+		ret = t.RefName()
+	case t.Type == "string":
+		ret = "string"
+	case t.Type == "integer" || t.Type == "number":
+		ret = "number"
+	case t.Type == "boolean":
+		ret = "boolean"
+	case t.Type == "array":
+		ret = tsType(t.Items)
+		if strings.Contains(ret, " ") {
+			ret = "(" + ret + ")"
+		}
+		ret += "[]"
+	case t.Type == "object" && t.AdditionalProperties != nil:
+		ret = "Record<string, " + tsType(t.AdditionalProperties) + ">"
+	case t.Type == "object":
+		ret = "Record<string, any>"
+	default:
+		ret = "any"
+	}
+	if t.Nullable && ret != "any" {
+		ret += " | null"
+	}
+	return ret
+}
+
+func lowerFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[size:]
+}
+
+// 与peers的交互数据格式一致：int32及int64均为小端序，字符串为int32字节长度+UTF-8内容
+const tsRuntime = `
+export const EXCHANGE_MARK = -0x21676f76;
+export const EXCHANGE_VERSION = 1;
+const RET_REQUEST = 0;
+const RET_RESPONSE = 1;
+const RET_CANCEL = 2;
+
+/** 交互数据，字段与服务端的exchange一致 */
+export interface Exchange {
+  from: string;
+  to: string;
+  func: string;
+  seq: bigint;
+  ret: number;
+  err: string;
+  timeLeft: bigint;
+  metadata: Record<string, string>;
+  body: Uint8Array | null;
+}
+
+const textEncoder = new TextEncoder();
+const textDecoder = new TextDecoder();
+
+class Writer {
+  private buffer: Uint8Array;
+  private view: DataView;
+  private offset = 0;
+
+  constructor(capacity: number) {
+    this.buffer = new Uint8Array(Math.max(capacity, 64));
+    this.view = new DataView(this.buffer.buffer);
+  }
+
+  private ensure(size: number): void {
+    if (this.offset + size <= this.buffer.length) {
+      return;
+    }
+    let capacity = this.buffer.length * 2;
+    while (capacity < this.offset + size) {
+      capacity *= 2;
+    }
+    const buffer = new Uint8Array(capacity);
+    buffer.set(this.buffer.subarray(0, this.offset));
+    this.buffer = buffer;
+    this.view = new DataView(buffer.buffer);
+  }
+
+  byte(value: number): this {
+    this.ensure(1);
+    this.view.setUint8(this.offset, value);
+    this.offset += 1;
+    return this;
+  }
+
+  int32(value: number): this {
+    this.ensure(4);
+    this.view.setInt32(this.offset, value, true);
+    this.offset += 4;
+    return this;
+  }
+
+  int64(value: bigint): this {
+    this.ensure(8);
+    this.view.setBigInt64(this.offset, value, true);
+    this.offset += 8;
+    return this;
+  }
+
+  bytes(value: Uint8Array): this {
+    this.ensure(value.length);
+    this.buffer.set(value, this.offset);
+    this.offset += value.length;
+    return this;
+  }
+
+  string(value: string): this {
+    const data = textEncoder.encode(value);
+    return this.int32(data.length).bytes(data);
+  }
+
+  flush(): Uint8Array {
+    return this.buffer.slice(0, this.offset);
+  }
+}
+
+class Reader {
+  private readonly data: Uint8Array;
+  private readonly view: DataView;
+  private offset = 0;
+
+  constructor(data: Uint8Array) {
+    this.data = data;
+    this.view = new DataView(data.buffer, data.byteOffset, data.byteLength);
+  }
+
+  private check(size: number): void {
+    if (size < 0 || this.offset + size > this.data.length) {
+      throw new Error("交互数据长度不足");
+    }
+  }
+
+  peekInt32(): number {
+    this.check(4);
+    return this.view.getInt32(this.offset, true);
+  }
+
+  byte(): number {
+    this.check(1);
+    const value = this.view.getUint8(this.offset);
+    this.offset += 1;
+    return value;
+  }
+
+  int32(): number {
+    const value = this.peekInt32();
+    this.offset += 4;
+    return value;
+  }
+
+  int64(): bigint {
+    this.check(8);
+    const value = this.view.getBigInt64(this.offset, true);
+    this.offset += 8;
+    return value;
+  }
+
+  string(): string {
+    const length = this.int32();
+    this.check(length);
+    const value = textDecoder.decode(this.data.subarray(this.offset, this.offset + length));
+    this.offset += length;
+    return value;
+  }
+
+  rest(): Uint8Array {
+    return this.data.subarray(this.offset);
+  }
+}
+
+/** 以V1格式编码，与exchange.Marshal一致 */
+export function encodeExchange(e: Exchange): Uint8Array {
+  const writer = new Writer(256);
+  writer.int32(EXCHANGE_MARK).byte(EXCHANGE_VERSION)
+    .string(e.from).string(e.to).string(e.func)
+    .int64(e.seq).byte(e.ret).string(e.err)
+    .int64(e.timeLeft);
+  const keys = Object.keys(e.metadata).sort();
+  writer.int32(keys.length);
+  for (const key of keys) {
+    writer.string(key).string(e.metadata[key]);
+  }
+  if (e.body !== null) {
+    writer.bytes(e.body);
+  }
+  return writer.flush();
+}
+
+/** 可解析旧版、V1及V2格式，与exchange.Unmarshal一致 */
+export function decodeExchange(data: Uint8Array): Exchange {
+  const reader = new Reader(data);
+  let version = 0;
+  if (data.length >= 4 && reader.peekInt32() === EXCHANGE_MARK) {
+    reader.int32();
+    version = reader.byte();
+    if (version > 2) {
+      throw new Error("不支持的交互数据版本:" + version);
+    }
+  }
+  const e: Exchange = {
+    from: reader.string(),
+    to: reader.string(),
+    func: reader.string(),
+    seq: reader.int64(),
+    ret: reader.byte(),
+    err: reader.string(),
+    timeLeft: 0n,
+    metadata: {},
+    body: null,
+  };
+  if (version >= 1) {
+    e.timeLeft = reader.int64();
+    const count = reader.int32();
+    for (let i = 0; i < count; i++) {
+      const key = reader.string();
+      e.metadata[key] = reader.string();
+    }
+  }
+  if (version >= 2) {
+    reader.int64(); // 流id
+    reader.int32(); // 流量额度
+  }
+  const body = reader.rest();
+  e.body = body.length > 0 ? body : null;
+  return e;
+}
+
+type WebSocketConstructor = new (url: string) => WebSocket;
+
+export interface ClientOptions {
+  /** 本端的Peer昵称，服务端的应答及推送以此为目标，缺省为"Client" */
+  nick?: string;
+  /** 请求超时(毫秒)，缺省为10000 */
+  timeout?: number;
+  /** WebSocket的实现，缺省使用全局的WebSocket */
+  WebSocket?: WebSocketConstructor;
+}
+
+export interface CallOptions {
+  /** 请求超时(毫秒)，随请求传递至服务端 */
+  timeout?: number;
+  /** 随请求传递的元数据，服务端以peers.MetadataFrom读取 */
+  metadata?: Record<string, string>;
+  /** 放弃请求，服务端随之中止处理 */
+  signal?: AbortSignal;
+}
+
+export class SilvernodeError extends Error {
+  /** 服务端返回的错误，否则为本端的超时、取消或链接错误 */
+  readonly remote: boolean;
+
+  constructor(message: string, remote: boolean) {
+    super(message);
+    this.name = "SilvernodeError";
+    this.remote = remote;
+  }
+}
+
+/** 处理服务端发来的请求或事件，返回值作为请求的应答 */
+export type Handler = (args: any, from: string) => unknown;
+
+interface Pending {
+  resolve: (reply: any) => void;
+  reject: (err: Error) => void;
+  dispose: () => void;
+}
+
+function splitMethod(method: string): [string, string] {
+  const parts = method.split(".");
+  if (parts.length !== 2) {
+    throw new SilvernodeError("方法名必须符合PeerNick.FuncName的规范:" + method, false);
+  }
+  return [parts[0], parts[1]];
+}
+
+function encodeBody(value: unknown): Uint8Array {
+  return textEncoder.encode(JSON.stringify(value === undefined ? null : value));
+}
+
+// 超出2^53的整数将丢失精度
+function decodeBody(body: Uint8Array | null): any {
+  return body === null ? null : JSON.parse(textDecoder.decode(body));
+}
+
+/** 以访客身份链接到对外开放(IsPub)的节点，交互数据体以JSON编码(服务端缺省的OuterCodec) */
+export class SilvernodeClient {
+  readonly nick: string;
+  readonly timeout: number;
+  onclose: ((reason: Error) => void) | null = null;
+  onerror: ((err: Error) => void) | null = null;
+  private readonly WebSocketImpl: WebSocketConstructor;
+  private socket: WebSocket | null = null;
+  private seq = 0n;
+  private readonly pending = new Map<bigint, Pending>();
+  private readonly handlers = new Map<string, Handler>();
+
+  constructor(options: ClientOptions = {}) {
+    this.nick = options.nick ?? "Client";
+    this.timeout = options.timeout ?? 10000;
+    this.WebSocketImpl = options.WebSocket ?? (globalThis as any).WebSocket;
+  }
+
+  get connected(): boolean {
+    return this.socket !== null;
+  }
+
+  /** 如ws://127.0.0.1:8080/ws */
+  connect(url: string): Promise<void> {
+    return new Promise<void>((resolve, reject) => {
+      const socket = new this.WebSocketImpl(url);
+      socket.binaryType = "arraybuffer";
+      let opened = false;
+      socket.onopen = () => {
+        opened = true;
+        this.socket = socket;
+        resolve();
+      };
+      socket.onmessage = (ev: MessageEvent) => {
+        if (ev.data instanceof ArrayBuffer) {
+          this.dispatch(new Uint8Array(ev.data));
+        }
+      };
+      socket.onerror = () => {
+        if (!opened) {
+          reject(new SilvernodeError("链接失败:" + url, false));
+        }
+      };
+      socket.onclose = (ev: CloseEvent) => {
+        const reason = new SilvernodeError("链接已关闭:" + (ev.reason || ev.code), false);
+        if (!opened) {
+          reject(reason);
+          return;
+        }
+        if (this.socket === socket) {
+          this.socket = null;
+        }
+        for (const seq of Array.from(this.pending.keys())) {
+          this.settle(seq)?.reject(reason);
+        }
+        this.onclose?.(reason);
+      };
+    });
+  }
+
+  close(): void {
+    this.socket?.close();
+  }
+
+  /** 登记服务端向本端发起的请求或事件的处理方法，method形如"Client.OnChat" */
+  handle(method: string, handler: Handler): void {
+    splitMethod(method);
+    this.handlers.set(method, handler);
+  }
+
+  /** 发起请求并等待应答，method形如"Room.Join" */
+  invoke<T>(method: string, args?: unknown, options: CallOptions = {}): Promise<T> {
+    const [to, func] = splitMethod(method);
+    const timeout = options.timeout ?? this.timeout;
+    const signal = options.signal;
+    this.seq += 1n;
+    const seq = this.seq;
+    return new Promise<T>((resolve, reject) => {
+      if (signal?.aborted) {
+        reject(new SilvernodeError("请求已取消:" + method, false));
+        return;
+      }
+      const timer = setTimeout(() => this.cancel(seq, to, new SilvernodeError("请求超时:" + method, false)), timeout);
+      const onAbort = () => this.cancel(seq, to, new SilvernodeError("请求已取消:" + method, false));
+      signal?.addEventListener("abort", onAbort);
+      this.pending.set(seq, {
+        resolve,
+        reject,
+        dispose: () => {
+          clearTimeout(timer);
+          signal?.removeEventListener("abort", onAbort);
+        },
+      });
+      try {
+        this.post(this.nick, to, func, seq, RET_REQUEST, "", BigInt(timeout), options.metadata, encodeBody(args));
+      } catch (err) {
+        this.settle(seq);
+        reject(err);
+      }
+    });
+  }
+
+  /** 发送事件，不等待应答 */
+  sendEvent(method: string, args?: unknown, options: CallOptions = {}): void {
+    const [to, func] = splitMethod(method);
+    this.post(this.nick, to, func, 0n, RET_REQUEST, "", 0n, options.metadata, encodeBody(args));
+  }
+
+  private post(from: string, to: string, func: string, seq: bigint, ret: number, err: string,
+    timeLeft: bigint, metadata: Record<string, string> | undefined, body: Uint8Array | null): void {
+    if (this.socket === null) {
+      throw new SilvernodeError("尚未链接", false);
+    }
+    this.socket.send(encodeExchange({ from, to, func, seq, ret, err, timeLeft, metadata: metadata ?? {}, body }));
+  }
+
+  private settle(seq: bigint): Pending | undefined {
+    const pending = this.pending.get(seq);
+    if (pending !== undefined) {
+      this.pending.delete(seq);
+      pending.dispose();
+    }
+    return pending;
+  }
+
+  // 超时或放弃时通知服务端中止处理
+  private cancel(seq: bigint, to: string, reason: Error): void {
+    const pending = this.settle(seq);
+    if (pending === undefined) {
+      return;
+    }
+    pending.reject(reason);
+    try {
+      this.post(this.nick, to, "", seq, RET_CANCEL, "", 0n, undefined, null);
+    } catch {
+      // 链接已断开
+    }
+  }
+
+  private dispatch(data: Uint8Array): void {
+    let e: Exchange;
+    try {
+      e = decodeExchange(data);
+    } catch (err) {
+      this.onerror?.(err as Error);
+      return;
+    }
+    if (e.ret === RET_RESPONSE) {
+      const pending = this.settle(e.seq);
+      if (pending === undefined) {
+        return; // 已超时或放弃
+      }
+      if (e.err !== "") {
+        pending.reject(new SilvernodeError(e.err, true));
+        return;
+      }
+      try {
+        pending.resolve(decodeBody(e.body));
+      } catch (err) {
+        pending.reject(new SilvernodeError("应答结果反序列化出错:" + err, false));
+      }
+    } else if (e.ret === RET_REQUEST) {
+      this.serve(e);
+    }
+  }
+
+  private async serve(e: Exchange): Promise<void> {
+    const handler = this.handlers.get(e.to + "." + e.func);
+    let reply: unknown = null;
+    let err = "";
+    if (handler === undefined) {
+      err = "方法不存在:" + e.func;
+    } else {
+      try {
+        reply = await handler(decodeBody(e.body), e.from);
+      } catch (ex) {
+        err = ex instanceof Error ? ex.message : String(ex);
+      }
+    }
+    if (e.seq === 0n) {
+      if (err !== "") {
+        this.onerror?.(new SilvernodeError("目标事件执行异常:" + e.to + "." + e.func + ":" + err, false));
+      }
+      return;
+    }
+    let body: Uint8Array | null = null;
+    if (err === "") {
+      try {
+        body = encodeBody(reply);
+      } catch (ex) {
+        err = "应答结果序列化出错:" + ex;
+      }
+    }
+    try {
+      this.post(e.to, e.from, "", e.seq, RET_RESPONSE, err, 0n, undefined, body);
+    } catch (ex) {
+      this.onerror?.(ex as Error);
+    }
+  }
+}
+`
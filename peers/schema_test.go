@@ -0,0 +1,44 @@
+package peers_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/silvernodes/silvernode-go/peers/schema"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+// 无方法的Peer
+type Idle struct {
+}
+
+// 导出的接口描述仅包含对外开放且含有方法的Peer
+func TestHubSchema(t *testing.T) {
+	c := silvernodetest.Run(t, &silvernodetest.NodeSpec{Name: "gate", Init: func(n *silvernodetest.Node) error {
+		if _, err := n.Hub().Register(new(Calc), nil); err != nil {
+			return err
+		}
+		if _, err := n.Hub().Register(new(Idle), nil); err != nil {
+			return err
+		}
+		_, err := n.Hub().RegisterInner(new(Back), nil)
+		return err
+	}})
+	gate := c.NodesByName("gate")[0]
+
+	doc := gate.Hub().Schema()
+	if doc.Title != "gate" || len(doc.Services) != 1 || doc.Services[0].Name != "Calc" {
+		t.Fatalf("接口描述中的Peer不符: %+v", doc.Services)
+	}
+	file := filepath.Join(t.TempDir(), "schema.json")
+	if err := gate.Hub().ExportSchema(file); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := schema.Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Services) != 1 || len(loaded.Services[0].Methods) != 2 || loaded.Definitions["SumReq"] == nil {
+		t.Fatalf("导出的接口描述不符: %+v", loaded.Services)
+	}
+}
@@ -0,0 +1,242 @@
+package peers
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 按节点名称调用时的候选节点
+type Candidate struct {
+	NodeId   string
+	Weight   int // 节点权重，取自注册中心中的NodeInfo.Weight
+	Inflight int // 本节点发往该节点且尚未结束的请求数
+}
+
+// 节点选择器，candidates非空且按NodeId排序，返回其中之一
+// key为上下文中通过WithHashKey指定的键，未指定时为空
+type Selector interface {
+	Select(name string, key string, candidates []*Candidate) *Candidate
+}
+
+// 轮询，各节点名称分别计数
+func NewRoundRobinSelector() Selector {
+	s := new(roundRobinSelector)
+	s.counters = make(map[string]int)
+	return s
+}
+
+type roundRobinSelector struct {
+	counters map[string]int
+	lock     sync.Mutex
+}
+
+func (s *roundRobinSelector) Select(name string, key string, candidates []*Candidate) *Candidate {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	index := s.counters[name] % len(candidates)
+	s.counters[name] = index + 1
+	return candidates[index]
+}
+
+// 随机
+func NewRandomSelector() Selector {
+	return new(randomSelector)
+}
+
+type randomSelector struct {
+}
+
+func (s *randomSelector) Select(name string, key string, candidates []*Candidate) *Candidate {
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// 最少进行中请求，数量相同时随机选择
+func NewLeastInflightSelector() Selector {
+	return new(leastInflightSelector)
+}
+
+type leastInflightSelector struct {
+}
+
+func (s *leastInflightSelector) Select(name string, key string, candidates []*Candidate) *Candidate {
+	least := make([]*Candidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if len(least) > 0 && candidate.Inflight > least[0].Inflight {
+			continue
+		}
+		if len(least) > 0 && candidate.Inflight < least[0].Inflight {
+			least = least[:0]
+		}
+		least = append(least, candidate)
+	}
+	return least[rand.Intn(len(least))]
+}
+
+// 按权重随机，权重<=0的节点按100计
+func NewWeightedSelector() Selector {
+	return new(weightedSelector)
+}
+
+type weightedSelector struct {
+}
+
+func (s *weightedSelector) Select(name string, key string, candidates []*Candidate) *Candidate {
+	total := 0
+	for _, candidate := range candidates {
+		total += weightOf(candidate)
+	}
+	n := rand.Intn(total)
+	for _, candidate := range candidates {
+		if n -= weightOf(candidate); n < 0 {
+			return candidate
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+func weightOf(candidate *Candidate) int {
+	if candidate.Weight <= 0 {
+		return 100
+	}
+	return candidate.Weight
+}
+
+// 按键一致性哈希(最高随机权重算法)，相同的键落在同一节点
+// 节点增减时仅影响原本落在该节点上的键，未指定键时随机选择
+func NewHashSelector() Selector {
+	return new(hashSelector)
+}
+
+type hashSelector struct {
+}
+
+func (s *hashSelector) Select(name string, key string, candidates []*Candidate) *Candidate {
+	if key == "" {
+		return candidates[rand.Intn(len(candidates))]
+	}
+	var chosen *Candidate
+	var max uint64
+	for _, candidate := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(candidate.NodeId))
+		if score := mix64(h.Sum64()); chosen == nil || score > max {
+			chosen, max = candidate, score
+		}
+	}
+	return chosen
+}
+
+// fnv对末尾字节的扩散较弱，节点id通常仅末尾不同，需再做一次混淆
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+type hashKey struct{}
+
+// 指定一致性哈希选择节点时使用的键，例如房间号、玩家id
+func WithHashKey(c context.Context, key string) context.Context {
+	return context.WithValue(c, hashKey{}, key)
+}
+
+func HashKeyFrom(c context.Context) string {
+	key, _ := c.Value(hashKey{}).(string)
+	return key
+}
+
+// 指定某一节点名称使用的选择器，name为空时设置缺省选择器(缺省为轮询)
+func (h *Hub) SetSelector(name string, selector Selector) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if name == "" {
+		h.selector = selector
+	} else {
+		h.selectors[name] = selector
+	}
+}
+
+func SetSelector(name string, selector Selector) {
+	_hub.SetSelector(name, selector)
+}
+
+func (h *Hub) getSelector(name string) Selector {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if selector, exists := h.selectors[name]; exists {
+		return selector
+	}
+	return h.selector
+}
+
+// 从指定名称的节点中选出一个，已选中但链接缺失的节点将被排除并重新选择
+func (h *Hub) selectNode(c context.Context, name string) (string, error) {
+	nodeIds := h.linkedNodes(name)
+	if h.node.Name() == name {
+		nodeIds = append(nodeIds, h.node.NodeId())
+	}
+	candidates := make([]*Candidate, 0, len(nodeIds))
+	for _, nodeId := range nodeIds {
+		candidate := &Candidate{NodeId: nodeId, Inflight: h.inflight(nodeId)}
+		if nodeId == h.node.NodeId() {
+			candidate.Weight = h.node.Weight()
+		} else if info, exists := h.node.GetNodeInfo(nodeId); exists {
+			candidate.Weight = info.Weight
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].NodeId < candidates[j].NodeId
+	})
+	selector := h.getSelector(name)
+	key := HashKeyFrom(c)
+	for len(candidates) > 0 {
+		chosen := selector.Select(name, key, candidates)
+		if chosen == nil {
+			break
+		}
+		if chosen.NodeId == h.node.NodeId() {
+			return chosen.NodeId, nil
+		}
+		if _, exists := h.node.ConnectManager().GetConnectInfo(chosen.NodeId); exists {
+			return chosen.NodeId, nil
+		}
+		rest := candidates[:0]
+		for _, candidate := range candidates {
+			if candidate.NodeId != chosen.NodeId {
+				rest = append(rest, candidate)
+			}
+		}
+		if len(rest) == len(candidates) {
+			break
+		}
+		candidates = rest
+	}
+	return "", errutil.New("没有可用的节点:" + name)
+}
+
+func (h *Hub) inflight(nodeId string) int {
+	h.flightLock.Lock()
+	defer h.flightLock.Unlock()
+	return h.flights[nodeId]
+}
+
+func (h *Hub) addInflight(nodeId string, delta int) {
+	h.flightLock.Lock()
+	defer h.flightLock.Unlock()
+	if h.flights[nodeId] += delta; h.flights[nodeId] <= 0 {
+		delete(h.flights, nodeId)
+	}
+}
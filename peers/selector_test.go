@@ -0,0 +1,234 @@
+package peers_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+type Room struct {
+	nodeId string
+	delay  time.Duration
+}
+
+type JoinReq struct {
+	Room string
+}
+
+type JoinResp struct {
+	Node string
+}
+
+func (r *Room) Join(args *JoinReq, reply *JoinResp) error {
+	time.Sleep(r.delay)
+	reply.Node = r.nodeId
+	return nil
+}
+
+// gate节点以logic为后端，logic节点按weights依次启动
+func startRooms(t *testing.T, delay time.Duration, weights ...int) (*silvernodetest.Cluster, *silvernodetest.Node) {
+	t.Helper()
+	specs := make([]*silvernodetest.NodeSpec, 0, len(weights))
+	for _, weight := range weights {
+		spec := procSpec("logic", func(n *silvernodetest.Node) interface{} {
+			return &Room{nodeId: n.Id(), delay: delay}
+		})
+		spec.Weight = weight
+		specs = append(specs, spec)
+	}
+	return startGate(t, "logic", specs...)
+}
+
+func joinAny(t *testing.T, p peers.Peer, c context.Context, times int) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	for i := 0; i < times; i++ {
+		reply := new(JoinResp)
+		if err := p.InvokeAnyCtx(c, "logic", "Room.Join", &JoinReq{}, reply); err != nil {
+			t.Fatal(err)
+		}
+		counts[reply.Node]++
+	}
+	return counts
+}
+
+func TestInvokeAnyRoundRobin(t *testing.T) {
+	c, gate := startRooms(t, 0, 0, 0, 0)
+	counts := joinAny(t, gate.Caller(), context.Background(), 30)
+	for _, n := range c.NodesByName("logic") {
+		if counts[n.Id()] != 10 {
+			t.Fatalf("轮询应平均分配: %v", counts)
+		}
+	}
+
+	if err := gate.Caller().InvokeAny("nope", "Room.Join", &JoinReq{}, new(JoinResp)); err == nil {
+		t.Fatal("没有同名节点时应返回错误")
+	}
+}
+
+func TestInvokeAnyWeighted(t *testing.T) {
+	c, gate := startRooms(t, 0, 100, 300)
+	gate.Hub().SetSelector("logic", peers.NewWeightedSelector())
+	counts := joinAny(t, gate.Caller(), context.Background(), 800)
+	light, heavy := c.NodesByName("logic")[0].Id(), c.NodesByName("logic")[1].Id()
+	if counts[light] < 100 || counts[light] > 300 || counts[heavy] < 500 {
+		t.Fatalf("按权重分配的比例不符: %v", counts)
+	}
+}
+
+func TestInvokeAnyHash(t *testing.T) {
+	c, gate := startRooms(t, 0, 0, 0, 0)
+	gate.Hub().SetSelector("logic", peers.NewHashSelector())
+	bg := context.Background()
+
+	owners := make(map[string]string)
+	spread := make(map[string]int)
+	for i := 0; i < 60; i++ {
+		room := fmt.Sprintf("room-%d", i)
+		counts := joinAny(t, gate.Caller(), peers.WithHashKey(bg, room), 3)
+		if len(counts) != 1 {
+			t.Fatalf("相同的键应落在同一节点: %s %v", room, counts)
+		}
+		for nodeId := range counts {
+			owners[room] = nodeId
+			spread[nodeId]++
+		}
+	}
+	if len(spread) != 3 {
+		t.Fatalf("不同的键应分散至各节点: %v", spread)
+	}
+
+	// 节点下线后仅原本落在该节点上的键改选其他节点
+	victim, _ := c.Node(owners["room-0"])
+	if err := victim.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gate.WaitClose(victim.Id(), silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	for room, owner := range owners {
+		counts := joinAny(t, gate.Caller(), peers.WithHashKey(bg, room), 1)
+		if owner == victim.Id() {
+			if counts[victim.Id()] != 0 {
+				t.Fatalf("不应选中已下线的节点: %s %v", room, counts)
+			}
+		} else if counts[owner] != 1 {
+			t.Fatalf("%s不应改选其他节点: %s -> %v", room, owner, counts)
+		}
+	}
+}
+
+func TestInvokeAnyLeastInflight(t *testing.T) {
+	c, gate := startRooms(t, time.Millisecond*200, 0, 0, 0)
+	gate.Hub().SetSelector("logic", peers.NewLeastInflightSelector())
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		reply := new(JoinResp)
+		gate.Caller().CallAny("logic", "Room.Join", &JoinReq{}, reply, func(err error) {
+			defer wg.Done()
+			if err != nil {
+				t.Error(err)
+			}
+			lock.Lock()
+			counts[reply.Node]++
+			lock.Unlock()
+		})
+	}
+	wg.Wait()
+	for _, n := range c.NodesByName("logic") {
+		if counts[n.Id()] != 10 {
+			t.Fatalf("应优先选择进行中请求最少的节点: %v", counts)
+		}
+	}
+}
+
+// 选中后链接断开的节点被排除，并从其余节点中重新选择
+type closingSelector struct {
+	gate   *silvernodetest.Node
+	closed []string
+}
+
+func (s *closingSelector) Select(name string, key string, candidates []*peers.Candidate) *peers.Candidate {
+	chosen := candidates[0]
+	if len(s.closed) == 0 {
+		s.gate.CloseLink(chosen.NodeId)
+		s.closed = append(s.closed, chosen.NodeId)
+	}
+	return chosen
+}
+
+func TestInvokeAnyFailover(t *testing.T) {
+	c, gate := startRooms(t, 0, 0, 0)
+	selector := &closingSelector{gate: gate}
+	gate.Hub().SetSelector("logic", selector)
+	reply := new(JoinResp)
+	if err := gate.Caller().InvokeAny("logic", "Room.Join", &JoinReq{}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if len(selector.closed) != 1 || reply.Node == selector.closed[0] || reply.Node == "" {
+		t.Fatalf("应改选其他节点: closed=%v chosen=%s", selector.closed, reply.Node)
+	}
+
+	// 本节点与目标同名时也作为候选(logic节点之间未建立链接，仅本节点可选)
+	logic := c.NodesByName("logic")[0]
+	p, _ := logic.Hub().GetPeer("Room")
+	if counts := joinAny(t, p, context.Background(), 4); counts[logic.Id()] != 4 {
+		t.Fatalf("应选中本节点: %v", counts)
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	candidates := []*peers.Candidate{
+		{NodeId: "logic#1", Weight: 1, Inflight: 2},
+		{NodeId: "logic#2", Weight: 0, Inflight: 0},
+		{NodeId: "logic#3", Weight: 300, Inflight: 0},
+	}
+	rr := peers.NewRoundRobinSelector()
+	for i := 0; i < 6; i++ {
+		if got := rr.Select("logic", "", candidates); got != candidates[i%3] {
+			t.Fatalf("轮询顺序不符: %d %s", i, got.NodeId)
+		}
+	}
+	if got := rr.Select("other", "", candidates); got != candidates[0] {
+		t.Fatalf("各节点名称应分别计数: %s", got.NodeId)
+	}
+
+	least := peers.NewLeastInflightSelector()
+	for i := 0; i < 20; i++ {
+		if got := least.Select("logic", "", candidates); got == candidates[0] {
+			t.Fatal("不应选中进行中请求较多的节点")
+		}
+	}
+
+	weighted := peers.NewWeightedSelector()
+	counts := make(map[string]int)
+	for i := 0; i < 4010; i++ {
+		counts[weighted.Select("logic", "", candidates).NodeId]++
+	}
+	if counts["logic#1"] > 40 || counts["logic#2"] < 700 || counts["logic#3"] < 2500 {
+		t.Fatalf("按权重分配的比例不符: %v", counts)
+	}
+
+	// 一致性哈希：移除节点只影响落在该节点上的键
+	hash := peers.NewHashSelector()
+	rest := candidates[1:]
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprint("key-", i)
+		before := hash.Select("logic", key, candidates)
+		if again := hash.Select("logic", key, candidates); again != before {
+			t.Fatalf("相同的键应选中同一节点: %s", key)
+		}
+		if after := hash.Select("logic", key, rest); before != candidates[0] && after != before {
+			t.Fatalf("移除其他节点后%s不应改选: %s -> %s", key, before.NodeId, after.NodeId)
+		}
+	}
+}
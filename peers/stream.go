@@ -0,0 +1,398 @@
+package peers
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/trace"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/snowflake"
+)
+
+const STREAM_WINDOW int = 64 // 流的接收窗口(消息数)，发送方最多领先接收方读取的消息数
+
+// 双向流，由OpenStream打开，被调方以形如func(args *Req, stream peers.Stream) error的方法处理
+// 被调方方法返回即结束该流，返回的错误由调用方的Recv获得
+// Send在对端的接收窗口耗尽时阻塞，直至对端读取消息后归还额度
+type Stream interface {
+	Context() context.Context
+	Send(msg interface{}) error
+	// 对端结束发送后返回io.EOF，被调方处理出错时返回对应错误
+	Recv(msg interface{}) error
+	// 结束本端的发送，对端仍可继续发送
+	CloseSend() error
+}
+
+var typeOfStream = reflect.TypeOf((*Stream)(nil)).Elem()
+
+type stream struct {
+	p        *peer
+	key      string
+	node     string // 对端节点
+	remote   string // 对端Peer昵称
+	id       int64  // 本端发出的帧所携带的流id
+	opener   bool
+	ctx      context.Context
+	origin   context.Context // 发起方传入的上下文
+	cancel   context.CancelFunc
+	span     *trace.Span
+	queue    []*exchange // 已收到尚未读取的消息
+	credit   int         // 对端尚可接收的消息数
+	consumed int         // 已读取尚未归还额度的消息数
+	acked    bool        // 被调方已确认
+	recvEOF  bool        // 对端已结束发送
+	sendEOF  bool        // 本端已结束发送
+	ended    bool
+	err      error
+	changed  chan struct{} // 状态变化时关闭并替换，用于唤醒等待中的Send/Recv
+	lock     sync.Mutex
+}
+
+func newStream(p *peer, node string, remote string, id int64, opener bool) *stream {
+	s := new(stream)
+	s.p = p
+	s.node = node
+	s.remote = remote
+	s.id = id
+	s.opener = opener
+	s.key = servingKey(node, -id) // 对端发来的帧携带相反的流id
+	s.queue = make([]*exchange, 0, 8)
+	s.changed = make(chan struct{})
+	return s
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stream) Send(msg interface{}) error {
+	s.lock.Lock()
+	for {
+		if s.ended {
+			s.lock.Unlock()
+			return s.result()
+		}
+		if s.sendEOF {
+			s.lock.Unlock()
+			return errutil.New("流已结束发送")
+		}
+		if s.credit > 0 {
+			s.credit--
+			break
+		}
+		if err := s.wait(); err != nil {
+			return err
+		}
+	}
+	s.lock.Unlock()
+	return s.post(retStreamData, msg, 0, "")
+}
+
+func (s *stream) Recv(msg interface{}) error {
+	s.lock.Lock()
+	for {
+		if len(s.queue) > 0 {
+			e := s.queue[0]
+			s.queue = s.queue[1:]
+			s.consumed++
+			grant := 0
+			if s.consumed >= STREAM_WINDOW/2 && !s.ended && !s.recvEOF {
+				grant, s.consumed = s.consumed, 0
+			}
+			s.lock.Unlock()
+			if grant > 0 {
+				s.post(retStreamCredit, nil, int32(grant), "")
+			}
+			return s.decode(e, msg)
+		}
+		if s.ended {
+			s.lock.Unlock()
+			return s.result()
+		}
+		if s.recvEOF {
+			s.lock.Unlock()
+			return io.EOF
+		}
+		if err := s.wait(); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *stream) CloseSend() error {
+	s.lock.Lock()
+	if s.ended || s.sendEOF {
+		s.lock.Unlock()
+		return nil
+	}
+	s.sendEOF = true
+	s.lock.Unlock()
+	return s.post(retStreamClose, nil, 0, "")
+}
+
+// 被调方因同一时限中止时，以发起方上下文的错误为准
+func (s *stream) result() error {
+	if s.opener && s.err != io.EOF && s.origin.Err() != nil {
+		return s.origin.Err()
+	}
+	return s.err
+}
+
+// 持有锁时调用，等待状态变化后重新持有锁；上下文结束时释放锁并返回其错误
+func (s *stream) wait() error {
+	changed := s.changed
+	s.lock.Unlock()
+	select {
+	case <-changed:
+		s.lock.Lock()
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// 持有锁时调用
+func (s *stream) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *stream) decode(e *exchange, msg interface{}) error {
+	if e.args == nil { // 远端
+		return e.FetchArgs(s.p.hub, s.node, msg)
+	}
+	dst := reflect.ValueOf(msg)
+	src := reflect.ValueOf(e.args)
+	if dst.Kind() != reflect.Ptr || dst.IsNil() {
+		return errutil.New("流消息的接收结构必须为指针")
+	}
+	if src.Type() == dst.Type() {
+		dst.Elem().Set(src.Elem())
+	} else if src.Type() == dst.Type().Elem() {
+		dst.Elem().Set(src)
+	} else {
+		return errutil.New("流消息类型不匹配:" + dst.Type().String() + "<-->" + src.Type().String())
+	}
+	return nil
+}
+
+func (s *stream) post(ret byte, args interface{}, credit int32, errText string) error {
+	e := &exchange{
+		From:   s.p.nick,
+		To:     s.remote,
+		Ret:    ret,
+		Err:    errText,
+		Stream: s.id,
+		Credit: credit,
+		args:   args,
+	}
+	return s.p.deliver(s.node, e)
+}
+
+// 结束流，notify为true时告知对端：发起方发送取消通知，被调方发送处理结果
+func (s *stream) shutdown(err error, notify bool) {
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	if err == nil {
+		err = io.EOF
+	}
+	s.err = err
+	if notify {
+		s.queue = s.queue[:0] // 本端中止时丢弃未读取的消息，对端正常结束时仍可读完
+	}
+	s.notify()
+	s.lock.Unlock()
+
+	s.p.removeStream(s.key)
+	if notify {
+		if s.opener {
+			s.post(retCancel, nil, 0, "")
+		} else {
+			errText := ""
+			if err != io.EOF {
+				errText = err.Error()
+			}
+			s.post(retStreamEnd, nil, 0, errText)
+		}
+	}
+	if err != io.EOF {
+		s.span.SetError(err)
+	}
+	s.span.End()
+	s.cancel()
+}
+
+func (s *stream) onFrame(e *exchange) {
+	switch e.Ret {
+	case retStreamData:
+		s.lock.Lock()
+		if s.ended || s.recvEOF {
+			s.lock.Unlock()
+			return
+		}
+		if len(s.queue) >= STREAM_WINDOW {
+			s.lock.Unlock()
+			s.shutdown(errutil.New("流消息超出接收窗口"), true)
+			return
+		}
+		s.queue = append(s.queue, e)
+		s.notify()
+		s.lock.Unlock()
+	case retStreamCredit:
+		s.lock.Lock()
+		s.credit += int(e.Credit)
+		s.acked = true
+		s.notify()
+		s.lock.Unlock()
+	case retStreamClose:
+		s.lock.Lock()
+		s.recvEOF = true
+		s.notify()
+		s.lock.Unlock()
+	case retStreamEnd:
+		var err error = nil
+		if e.Err != "" {
+			err = &remoteError{text: e.Err}
+		}
+		s.shutdown(err, false)
+	case retCancel:
+		s.shutdown(context.Canceled, false)
+	}
+}
+
+// 打开到目标节点指定方法的流，流的生命周期由上下文控制，不受全局超时限制
+// 被调方在全局超时内未确认时返回错误；不再使用时应取消上下文以通知被调方中止处理
+func (p *peer) OpenStream(c context.Context, node string, method string, args interface{}) (Stream, error) {
+	methodInfo := strings.Split(method, ".")
+	if len(methodInfo) != 2 {
+		return nil, errutil.New("方法名必须符合PeerNick.FuncName的规范:" + method)
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	s := newStream(p, node, methodInfo[0], snowflake.GenerateRaw(), true)
+	c, s.span = trace.Start(c, p.hub.node.Name(), method, trace.KIND_CLIENT)
+	s.span.SetAttribute("rpc.system", "silvernode")
+	s.span.SetAttribute("peer.node", node)
+	s.origin = c
+	s.ctx, s.cancel = context.WithCancel(c)
+	timeLeft := int64(0)
+	if deadline, ok := c.Deadline(); ok {
+		timeLeft = int64((time.Until(deadline) + time.Millisecond - 1) / time.Millisecond)
+		if timeLeft <= 0 {
+			timeLeft = 1
+		}
+	}
+	e := &exchange{
+		From:     p.nick,
+		To:       methodInfo[0],
+		Func:     methodInfo[1],
+		args:     args,
+		TimeLeft: timeLeft,
+		Stream:   s.id,
+	}
+	attachMetadata(c, e)
+	p.addStream(s)
+	e.PrintInfo(p.hub.node.NodeId(), node, true)
+	if err := p.deliver(node, e); err != nil {
+		p.removeStream(s.key)
+		s.span.SetError(err)
+		s.span.End()
+		s.cancel()
+		return nil, err
+	}
+	go func() {
+		<-s.ctx.Done() // 流正常结束时同样会取消，此时shutdown不再生效
+		s.shutdown(c.Err(), true)
+	}()
+
+	timer := time.NewTimer(time.Duration(_setup.Timeout) * time.Millisecond)
+	defer timer.Stop()
+	s.lock.Lock()
+	for !s.acked && !s.ended {
+		changed := s.changed
+		s.lock.Unlock()
+		select {
+		case <-changed:
+		case <-s.ctx.Done():
+			s.shutdown(c.Err(), true)
+		case <-timer.C:
+			s.shutdown(errutil.New("打开流超时:"+method), true)
+		}
+		s.lock.Lock()
+	}
+	acked, err := s.acked, s.result()
+	s.lock.Unlock()
+	if !acked {
+		return nil, err
+	}
+	return s, nil // 确认后即便已结束，也交由Recv读取剩余消息及处理结果
+}
+
+// 被调方收到流请求时建立流并确认，此后对端发来的消息直接进入流的接收队列，不经过任务队列
+func (p *peer) acceptStream(nodeId string, e *exchange) {
+	s := newStream(p, nodeId, e.From, -e.Stream, false)
+	s.ctx, s.cancel = e.callCtx, e.cancel
+	s.credit = STREAM_WINDOW // 打开流即视为授予初始额度
+	e.stream = s
+	p.addStream(s)
+	s.post(retStreamCredit, nil, int32(STREAM_WINDOW), "")
+}
+
+func (p *peer) onStreamFrame(nodeId string, e *exchange) {
+	p.lock.RLock()
+	s, exists := p.streams[servingKey(nodeId, e.Stream)]
+	p.lock.RUnlock()
+	if exists {
+		s.onFrame(e)
+	}
+}
+
+func (p *peer) addStream(s *stream) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.streams[s.key] = s
+}
+
+func (p *peer) removeStream(key string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.streams, key)
+}
+
+func (p *peer) abortStreams(err error) {
+	p.lock.Lock()
+	streams := make([]*stream, 0, len(p.streams))
+	for _, s := range p.streams {
+		streams = append(streams, s)
+	}
+	p.lock.Unlock()
+
+	for _, s := range streams {
+		s.shutdown(err, true)
+	}
+}
+
+// 发送交互数据，目标为本节点时直接投递
+func (p *peer) deliver(node string, e *exchange) error {
+	if node == p.hub.node.NodeId() {
+		p.hub.localExchange(node, e)
+		return nil
+	}
+	data, err := e.Marshal(node, 256)
+	if err != nil {
+		return errutil.Extend("交互数据序列化出错:"+p.hub.node.NodeId()+" -> "+node, err)
+	}
+	if err := p.hub.node.Send(node, data); err != nil {
+		return errutil.Extend("跨节点交互出错:"+p.hub.node.NodeId()+" -> "+node, err)
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package peers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/process"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+const STREAM_MESSAGES = peers.STREAM_WINDOW*3 + 7 // 超出接收窗口，需归还额度后才能发完
+
+type ReplayReq struct {
+	N      int
+	FailAt int // 发送第FailAt条前以错误结束，0为不出错
+}
+
+type Frame struct {
+	I int
+}
+
+type Match struct {
+	sent    int64 // Replay已发出的消息数
+	aborted chan error
+}
+
+func (m *Match) Replay(args *ReplayReq, s peers.Stream) error {
+	for i := 0; i < args.N; i++ {
+		if args.FailAt > 0 && i == args.FailAt {
+			return fmt.Errorf("回放中断:%d", i)
+		}
+		if err := s.Send(&Frame{I: i}); err != nil {
+			m.aborted <- err
+			return err
+		}
+		atomic.StoreInt64(&m.sent, int64(i+1))
+	}
+	return nil
+}
+
+// 逐条回复收到的消息，对端结束发送后返回
+func (m *Match) Echo(args *ReplayReq, s peers.Stream) error {
+	for {
+		f := new(Frame)
+		if err := s.Recv(f); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		f.I *= 10
+		if err := s.Send(f); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *Match) Watch(args *ReplayReq, s peers.Stream) error {
+	<-s.Context().Done()
+	m.aborted <- s.Context().Err()
+	return s.Context().Err()
+}
+
+func (m *Match) Get(args *ReplayReq, reply *Frame) error {
+	reply.I = args.N
+	return nil
+}
+
+// match节点开放Match(独立协程处理)及Queued(单协程依次处理)，gate节点以match为后端
+func startMatch(t *testing.T) (*silvernodetest.Node, *silvernodetest.Node, *Match) {
+	t.Helper()
+	match := &Match{aborted: make(chan error, 16)}
+	c, gate := startGate(t, "match", &silvernodetest.NodeSpec{Name: "match", Init: func(n *silvernodetest.Node) error {
+		if _, err := n.Hub().Register(match, nil); err != nil {
+			return err
+		}
+		_, err := n.Hub().RegisterWithNick("Queued", false, match, process.Spawn(64))
+		return err
+	}})
+	return gate, c.NodesByName("match")[0], match
+}
+
+// 读取至流结束，返回读取的消息数及结束时的错误，消息须按序到达
+func readFrames(s peers.Stream, step int) (int, error) {
+	n := 0
+	for {
+		f := new(Frame)
+		if err := s.Recv(f); err != nil {
+			return n, err
+		}
+		if f.I != n*step {
+			return n, fmt.Errorf("消息乱序: got %d, want %d", f.I, n*step)
+		}
+		n++
+	}
+}
+
+func TestStream(t *testing.T) {
+	gate, match, _ := startMatch(t)
+	bg := context.Background()
+
+	for _, nick := range []string{"Match", "Queued"} {
+		t.Run(nick, func(t *testing.T) {
+			s, err := gate.Caller().OpenStream(bg, match.Id(), nick+".Replay", &ReplayReq{N: 500})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, err := readFrames(s, 1); n != 500 || err != io.EOF {
+				t.Fatalf("服务端流: n=%d err=%v", n, err)
+			}
+
+			s, err = gate.Caller().OpenStream(bg, match.Id(), nick+".Replay", &ReplayReq{N: 500, FailAt: 3})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n, err := readFrames(s, 1); n != 3 || err == nil || !strings.Contains(err.Error(), "回放中断:3") {
+				t.Fatalf("被调方的错误应由Recv获得: n=%d err=%v", n, err)
+			}
+
+			s, err = gate.Caller().OpenStream(bg, match.Id(), nick+".Echo", &ReplayReq{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sendErr := make(chan error, 1)
+			go func() {
+				for i := 0; i < STREAM_MESSAGES; i++ {
+					if err := s.Send(&Frame{I: i}); err != nil {
+						sendErr <- err
+						return
+					}
+				}
+				sendErr <- s.CloseSend()
+			}()
+			if n, err := readFrames(s, 10); n != STREAM_MESSAGES || err != io.EOF {
+				t.Fatalf("双向流: n=%d err=%v", n, err)
+			}
+			if err := <-sendErr; err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Send(&Frame{}); err == nil {
+				t.Fatal("流结束后发送应返回错误")
+			}
+		})
+	}
+}
+
+// 接收方不读取时发送方最多领先一个接收窗口
+func TestStreamFlowControl(t *testing.T) {
+	for _, nick := range []string{"Match", "Queued"} {
+		t.Run(nick, func(t *testing.T) {
+			testFlowControl(t, nick)
+		})
+	}
+}
+
+func testFlowControl(t *testing.T, nick string) {
+	gate, match, m := startMatch(t)
+
+	c, cancel := context.WithCancel(context.Background())
+	s, err := gate.Caller().OpenStream(c, match.Id(), nick+".Replay", &ReplayReq{N: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 200)
+	if sent := atomic.LoadInt64(&m.sent); sent != int64(peers.STREAM_WINDOW) {
+		t.Fatalf("未读取时应发出一个窗口的消息: %d", sent)
+	}
+	for i := 0; i < peers.STREAM_WINDOW; i++ {
+		if err := s.Recv(new(Frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(silvernodetest.DefaultWait)
+	for atomic.LoadInt64(&m.sent) <= int64(peers.STREAM_WINDOW) {
+		if time.Now().After(deadline) {
+			t.Fatal("读取后未归还额度")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if sent := atomic.LoadInt64(&m.sent); sent > int64(peers.STREAM_WINDOW*2) {
+		t.Fatalf("发送方领先超出接收窗口: %d", sent)
+	}
+
+	// 取消后阻塞中的发送方随之结束
+	cancel()
+	select {
+	case err := <-m.aborted:
+		if err == nil {
+			t.Fatal("发送方应以错误结束")
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("取消后发送方未结束")
+	}
+	if err := s.Recv(new(Frame)); err == nil {
+		t.Fatal("取消后Recv应返回错误")
+	}
+}
+
+// 流的处理方法阻塞期间，同一Processor中的其他请求照常处理
+func TestStreamOffProcessor(t *testing.T) {
+	gate, match, m := startMatch(t)
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := gate.Caller().OpenStream(c, match.Id(), "Queued.Watch", &ReplayReq{}); err != nil {
+		t.Fatal(err)
+	}
+	reply := new(Frame)
+	if err := gate.Invoke(match.Id(), "Queued.Get", &ReplayReq{N: 7}, reply); err != nil || reply.I != 7 {
+		t.Fatalf("流处理期间的请求应照常处理: %+v %v", reply, err)
+	}
+	cancel()
+	select {
+	case err := <-m.aborted:
+		if err != context.Canceled {
+			t.Fatalf("处理方法应随取消结束: %v", err)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("取消后处理方法未结束")
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	gate, match, m := startMatch(t)
+
+	c, cancel := context.WithCancel(context.Background())
+	s, err := gate.Caller().OpenStream(c, match.Id(), "Match.Watch", &ReplayReq{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := <-m.aborted; !errors.Is(err, context.Canceled) {
+		t.Fatalf("被调方应收到取消: %v", err)
+	}
+	if err := s.Recv(new(Frame)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("调用方应得到上下文的错误: %v", err)
+	}
+
+	c, cancel = context.WithTimeout(context.Background(), time.Millisecond*150)
+	defer cancel()
+	s, err = gate.Caller().OpenStream(c, match.Id(), "Match.Watch", &ReplayReq{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Recv(new(Frame)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("调用方应得到上下文超时: %v", err)
+	}
+	select {
+	case err := <-m.aborted:
+		if !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+			t.Fatalf("被调方上下文的错误不符: %v", err)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("被调方未中止处理")
+	}
+
+	// 非流方法及不存在的方法以错误结束
+	for method, text := range map[string]string{"Match.Get": "不支持流式调用", "Match.Missing": "方法不存在"} {
+		s, err := gate.Caller().OpenStream(context.Background(), match.Id(), method, &ReplayReq{})
+		if err == nil {
+			err = s.Recv(new(Frame))
+		}
+		if err == nil || !strings.Contains(err.Error(), text) {
+			t.Fatalf("%s应返回包含%q的错误, 实际为%v", method, text, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package peers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+	"github.com/silvernodes/silvernode-go/trace"
+)
+
+type TraceReq struct {
+	Ctx context.Context `auto:"context"`
+}
+
+type TraceResp struct {
+	TraceId string
+	SpanId  string
+}
+
+// 返回处理请求时上下文中的链路信息
+type Traced struct {
+	events chan *TraceResp
+}
+
+func readSpan(c context.Context) *TraceResp {
+	sc := trace.SpanContextFrom(c)
+	return &TraceResp{TraceId: sc.TraceId.String(), SpanId: sc.SpanId.String()}
+}
+
+func (t *Traced) Hello(args *TraceReq, reply *TraceResp) error {
+	*reply = *readSpan(args.Ctx)
+	return nil
+}
+
+func (t *Traced) Notify(args *TraceReq) error {
+	t.events <- readSpan(args.Ctx)
+	return nil
+}
+
+// 被调方的Span在处理函数返回后结束，可能晚于调用方收到应答
+func waitSpans(exporter *trace.MemoryExporter, num int) []*trace.SpanData {
+	deadline := time.Now().Add(silvernodetest.DefaultWait)
+	for time.Now().Before(deadline) {
+		trace.Flush()
+		if spans := exporter.Spans(); len(spans) >= num {
+			return spans
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return exporter.Spans()
+}
+
+func findSpan(t *testing.T, spans []*trace.SpanData, kind trace.Kind) *trace.SpanData {
+	t.Helper()
+	for _, s := range spans {
+		if s.Kind == kind {
+			return s
+		}
+	}
+	t.Fatalf("未导出类型为%v的Span: %d", kind, len(spans))
+	return nil
+}
+
+// 校验本地根Span -> 调用方Span -> 被调方Span的父子关系
+func checkChain(t *testing.T, root *trace.Span, spans []*trace.SpanData, client trace.Kind, server trace.Kind, got *TraceResp) {
+	t.Helper()
+	cs, ss := findSpan(t, spans, client), findSpan(t, spans, server)
+	traceId := root.TraceId.String()
+	if cs.TraceId != traceId || ss.TraceId != traceId || got.TraceId != traceId {
+		t.Fatalf("跨节点的TraceId不一致: %s %s %s %s", traceId, cs.TraceId, ss.TraceId, got.TraceId)
+	}
+	if cs.ParentId != root.SpanId.String() {
+		t.Fatalf("调用方Span的父级应为本地Span: %s/%s", cs.ParentId, root.SpanId)
+	}
+	if ss.ParentId != cs.SpanId {
+		t.Fatalf("被调方Span的父级应为调用方Span: %s/%s", ss.ParentId, cs.SpanId)
+	}
+	if got.SpanId != ss.SpanId {
+		t.Fatalf("处理函数应运行于被调方Span中: %s/%s", got.SpanId, ss.SpanId)
+	}
+	if cs.Service != "gate" || ss.Service != "logic" {
+		t.Fatalf("Span所属的服务不符: %s %s", cs.Service, ss.Service)
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	exporter := trace.NewMemoryExporter()
+	trace.Setup(&trace.SetupParam{Exporters: []trace.Exporter{exporter}, Interval: 60000})
+	t.Cleanup(func() {
+		trace.Shutdown(context.Background())
+	})
+	traced := &Traced{events: make(chan *TraceResp, 1)}
+	c, gate := startGate(t, "logic", procSpec("logic", func(n *silvernodetest.Node) interface{} {
+		return traced
+	}))
+	logic := c.NodesByName("logic")[0]
+
+	rc, root := trace.Start(context.Background(), "test", "root", trace.KIND_INTERNAL)
+	reply := new(TraceResp)
+	if err := gate.Caller().InvokeCtx(rc, logic.Id(), "Traced.Hello", &TraceReq{}, reply); err != nil {
+		t.Fatal(err)
+	}
+	checkChain(t, root, waitSpans(exporter, 2), trace.KIND_CLIENT, trace.KIND_SERVER, reply)
+
+	exporter.Reset()
+	if err := gate.Caller().SendEventCtx(rc, logic.Id(), "Traced.Notify", &TraceReq{}); err != nil {
+		t.Fatal(err)
+	}
+	var got *TraceResp
+	select {
+	case got = <-traced.events:
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("等待事件超时")
+	}
+	checkChain(t, root, waitSpans(exporter, 2), trace.KIND_PRODUCER, trace.KIND_CONSUMER, got)
+	root.End()
+}
@@ -0,0 +1,22 @@
+package peers
+
+// 类型化的调用辅助函数，请求及应答类型由编译器检查
+// Req一般为参数结构体指针，Resp为应答结构体(非指针)，例如:
+//	resp, err := peers.InvokeT[*JoinReq, JoinResp](p, node, "Room.Join", req)
+
+func InvokeT[Req any, Resp any](p Peer, node string, method string, req Req) (Resp, error) {
+	reply := new(Resp)
+	err := p.Invoke(node, method, req, reply)
+	return *reply, err
+}
+
+func CallT[Req any, Resp any](p Peer, node string, method string, req Req, done func(Resp, error)) {
+	reply := new(Resp)
+	p.Call(node, method, req, reply, func(err error) {
+		done(*reply, err)
+	})
+}
+
+func SendEventT[Req any](p Peer, node string, method string, req Req) error {
+	return p.SendEvent(node, method, req)
+}
@@ -0,0 +1,118 @@
+package peers_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+type EnterReq struct {
+	User string
+}
+
+type EnterResp struct {
+	Room string
+	User string
+}
+
+type OtherReq struct {
+	Id int
+}
+
+type Lobby struct {
+	joined chan string
+}
+
+func (r *Lobby) Enter(args *EnterReq, reply *EnterResp) error {
+	reply.Room = "r-1"
+	reply.User = args.User
+	return nil
+}
+
+func (r *Lobby) Notify(args *EnterReq) error {
+	r.joined <- args.User
+	return nil
+}
+
+func startLobby(t *testing.T) (*silvernodetest.Node, *Lobby, string) {
+	t.Helper()
+	lobby := &Lobby{joined: make(chan string, 4)}
+	c, gate := startGate(t, "lobby", procSpec("lobby", func(n *silvernodetest.Node) interface{} {
+		return lobby
+	}))
+	return gate, lobby, c.NodesByName("lobby")[0].Id()
+}
+
+func TestTypedInvoke(t *testing.T) {
+	gate, lobby, node := startLobby(t)
+
+	resp, err := peers.InvokeT[*EnterReq, EnterResp](gate.Caller(), node, "Lobby.Enter", &EnterReq{User: "u-1"})
+	if err != nil || resp.Room != "r-1" || resp.User != "u-1" {
+		t.Fatalf("类型化调用结果不符: %+v %v", resp, err)
+	}
+
+	done := make(chan error, 1)
+	peers.CallT[*EnterReq, EnterResp](gate.Caller(), node, "Lobby.Enter", &EnterReq{User: "u-2"}, func(resp EnterResp, err error) {
+		if err == nil && resp.User != "u-2" {
+			err = errutil.New("异步调用的应答不符:" + resp.User)
+		}
+		done <- err
+	})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("等待异步调用超时")
+	}
+
+	if err := peers.SendEventT(gate.Caller(), node, "Lobby.Notify", &EnterReq{User: "u-3"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case user := <-lobby.joined:
+		if user != "u-3" {
+			t.Fatalf("事件参数不符: %s", user)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("等待事件超时")
+	}
+}
+
+func TestTypedMismatch(t *testing.T) {
+	gate, _, node := startLobby(t)
+	local, err := gate.Hub().Register(&Lobby{joined: make(chan string, 4)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 本地调用直接比对参数类型
+	if _, err := peers.InvokeT[*OtherReq, EnterResp](local, gate.Id(), "Lobby.Enter", &OtherReq{Id: 1}); err == nil || !strings.Contains(err.Error(), "参数类型不匹配") {
+		t.Fatalf("本地调用参数类型不符时应返回错误: %v", err)
+	}
+	done := make(chan error, 1)
+	peers.CallT[*OtherReq, EnterResp](local, gate.Id(), "Lobby.Enter", &OtherReq{Id: 1}, func(resp EnterResp, err error) {
+		done <- err
+	})
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "参数类型不匹配") {
+			t.Fatalf("异步调用参数类型不符时应返回错误: %v", err)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("等待异步调用超时")
+	}
+
+	// 远端调用在解码时发现不匹配
+	if _, err := peers.InvokeT[string, EnterResp](gate.Caller(), node, "Lobby.Enter", "u-1"); err == nil {
+		t.Fatal("远端调用参数无法解码时应返回错误")
+	}
+	if _, err := peers.InvokeT[*EnterReq, int](gate.Caller(), node, "Lobby.Enter", &EnterReq{User: "u-1"}); err == nil {
+		t.Fatal("应答无法解码为指定类型时应返回错误")
+	}
+}
@@ -0,0 +1,282 @@
+package silvernode
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/cluster"
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/log"
+	"github.com/silvernodes/silvernode-go/nets"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 断线期间暂存的消息
+type pending struct {
+	data []byte
+	at   time.Time
+}
+
+// 单个后端链接的重连状态
+type relink struct {
+	nodeId   string
+	url      string
+	conf     ctx.ReconnectConf
+	attempts int
+	since    time.Time
+	queue    []*pending
+	flushing bool // 链接已恢复，正在补发暂存的消息
+	wake     chan struct{}
+	quit     chan struct{}
+}
+
+type relinkSet struct {
+	items   map[string]*relink
+	size    atomic.Int32 // 进行中的重连数，无重连时发送消息无需加锁检查
+	stopped bool
+	sync.Mutex
+}
+
+func newRelinkSet() *relinkSet {
+	s := new(relinkSet)
+	s.items = make(map[string]*relink)
+	return s
+}
+
+func (s *relinkSet) add(nodeId string, url string, conf ctx.ReconnectConf) (*relink, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if s.stopped {
+		return nil, false
+	}
+	queue := make([]*pending, 0)
+	if old, exists := s.items[nodeId]; exists {
+		if !old.flushing {
+			return nil, false
+		}
+		queue, old.queue = old.queue, nil // 补发期间链接再次断开，尚未补发的消息转入新的重连
+	} else {
+		s.size.Add(1)
+	}
+	r := &relink{
+		nodeId: nodeId,
+		url:    url,
+		conf:   conf,
+		since:  time.Now(),
+		queue:  queue,
+		wake:   make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+	s.items[nodeId] = r
+	return r, true
+}
+
+func (s *relinkSet) exists(nodeId string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, exists := s.items[nodeId]
+	return exists
+}
+
+// 重连期间暂存待发送的消息，超出缓存时长或条数时返回false
+// 补发完成前新的消息同样排在队尾，以免先于暂存的消息发出
+func (s *relinkSet) enqueue(nodeId string, data []byte) bool {
+	if s.size.Load() == 0 {
+		return false
+	}
+	s.Lock()
+	defer s.Unlock()
+	r, exists := s.items[nodeId]
+	if !exists || r.conf.QueueSize < 0 {
+		return false
+	}
+	now := time.Now()
+	if !r.flushing && (now.Sub(r.since) > time.Millisecond*time.Duration(r.conf.QueueTimeout) || len(r.queue) >= r.conf.QueueSize) {
+		return false
+	}
+	r.queue = append(r.queue, &pending{data: data, at: now})
+	return true
+}
+
+// 结束重连并取出暂存的消息
+func (s *relinkSet) remove(r *relink) []*pending {
+	s.Lock()
+	defer s.Unlock()
+	if s.items[r.nodeId] != r {
+		return nil
+	}
+	s.delete(r.nodeId)
+	queue := r.queue
+	r.queue = nil
+	return queue
+}
+
+// 链接由对端或节点扫描恢复时，通知重连流程立即补发暂存的消息
+func (s *relinkSet) wake(nodeId string) {
+	if s.size.Load() == 0 {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if r, exists := s.items[nodeId]; exists {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 链接恢复后分批取出暂存的消息，队列为空时结束重连并返回nil
+func (s *relinkSet) takeout(r *relink) []*pending {
+	s.Lock()
+	defer s.Unlock()
+	if s.items[r.nodeId] != r {
+		return nil
+	}
+	r.flushing = true
+	if len(r.queue) == 0 {
+		s.delete(r.nodeId)
+		return nil
+	}
+	queue := r.queue
+	r.queue = make([]*pending, 0)
+	return queue
+}
+
+func (s *relinkSet) delete(nodeId string) {
+	delete(s.items, nodeId)
+	s.size.Add(-1)
+}
+
+// 取消指定节点的重连，暂存的消息随之丢弃
+func (s *relinkSet) cancel(nodeId string) {
+	s.Lock()
+	defer s.Unlock()
+	if r, exists := s.items[nodeId]; exists {
+		close(r.quit)
+		s.delete(nodeId)
+	}
+}
+
+// 停止全部重连，节点关闭时调用
+func (s *relinkSet) stop() {
+	s.Lock()
+	defer s.Unlock()
+	s.stopped = true
+	for nodeId, r := range s.items {
+		close(r.quit)
+		s.delete(nodeId)
+	}
+}
+
+// 后端链接断开或链接失败后开始重连，同一节点同时只会有一个重连流程
+// 返回false表示该链接不参与重连
+func (n *Node) reconnect(nodeId string, url string) bool {
+	if strings.Contains(nodeId, "@") || !n.isBackEnd(nodeId) { // 附加链接不参与重连
+		return false
+	}
+	conf := n.info.Load().ReconnectPolicy(ctx.GetNodeNameFromId(nodeId))
+	if conf.Disable {
+		return false
+	}
+	r, ok := n.relinks.add(nodeId, url, conf)
+	if !ok {
+		return n.relinks.exists(nodeId)
+	}
+	n.log.Log(log.WARN, "开始重连节点:"+nodeId)
+	go n.relinkLoop(r)
+	return true
+}
+
+func (n *Node) relinkLoop(r *relink) {
+	defer errutil.Catch(n.pipe.OnError)
+	var lasterr error = nil
+	for {
+		select {
+		case <-time.After(r.conf.Backoff(r.attempts)):
+		case <-r.wake:
+		case <-r.quit:
+			return
+		}
+		if _, exists := n.conns.GetConnectInfo(r.nodeId); exists { // 已由对端或节点扫描恢复链接
+			n.onRelinked(r, nil)
+			return
+		}
+		r.attempts++
+		err := n.locate(r)
+		if cluster.IsNotFound(err) {
+			n.onRelinked(r, errutil.Extend("节点已注销，放弃重连:"+r.nodeId, err))
+			return
+		}
+		if err == nil {
+			n.Lock() // 拨号可能持续至握手超时，期间不持有节点锁，以免阻塞关闭流程
+			if n.closing {
+				n.Unlock()
+				return
+			}
+			var netWorker nets.INetWorker
+			netWorker, err = n.getNetWorker(r.url)
+			n.Unlock()
+			if err == nil {
+				err = n.dial(netWorker, r.nodeId, r.url)
+			}
+		}
+		if err == nil {
+			n.onRelinked(r, nil)
+			return
+		}
+		lasterr = err
+		n.log.Log(log.DEBUG, fmt.Sprintf("第%d次重连节点失败:%s|%s", r.attempts, r.nodeId, err.Error()))
+		if r.conf.MaxAttempts > 0 && r.attempts >= r.conf.MaxAttempts {
+			n.onRelinked(r, errutil.Extend(fmt.Sprintf("重连%d次后放弃:%s", r.attempts, r.nodeId), lasterr))
+			return
+		}
+	}
+}
+
+// 从注册中心获取节点的最新地址，注册中心暂时无法访问时保留上次的地址并计为一次失败
+func (n *Node) locate(r *relink) error {
+	if n.reg == nil {
+		return nil
+	}
+	info, err := n.reg.GetNodeById(r.nodeId)
+	if err != nil {
+		return err
+	}
+	r.url = n.selectEndPoint(info)
+	return nil
+}
+
+// 重连结束，成功时按序补发暂存的消息，失败时丢弃
+func (n *Node) onRelinked(r *relink, err error) {
+	if err != nil {
+		queue := n.relinks.remove(r)
+		n.log.Log(log.ERROR, err)
+		if len(queue) > 0 {
+			n.pipe.OnError(errutil.New(fmt.Sprintf("重连失败，丢弃暂存的%d条消息:%s", len(queue), r.nodeId)))
+		}
+		n.pipe.OnReconnect(r.nodeId, r.attempts, err)
+		return
+	}
+	n.log.Log(log.INFO, "节点重连成功:"+r.nodeId)
+	connInfo, exists := n.conns.GetConnectInfo(r.nodeId)
+	expired := 0
+	for queue := n.relinks.takeout(r); queue != nil; queue = n.relinks.takeout(r) {
+		for _, p := range queue {
+			if !exists || time.Since(p.at) > time.Millisecond*time.Duration(r.conf.QueueTimeout) {
+				expired++
+				continue
+			}
+			if err := connInfo.Send(p.data); err != nil {
+				n.pipe.OnError(errutil.Extend("补发暂存消息失败:"+r.nodeId, err))
+			}
+		}
+	}
+	if expired > 0 {
+		n.pipe.OnError(errutil.New(fmt.Sprintf("丢弃已过期的%d条暂存消息:%s", expired, r.nodeId)))
+	}
+	n.pipe.OnReconnect(r.nodeId, r.attempts, nil)
+}
@@ -0,0 +1,224 @@
+package silvernode_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	silvernode "github.com/silvernodes/silvernode-go"
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/process"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+type Item struct {
+	I int
+}
+
+// 按到达顺序记录收到的事件
+type Sink struct {
+	got []int
+	sync.Mutex
+}
+
+func (s *Sink) Put(args *Item) error {
+	s.Lock()
+	defer s.Unlock()
+	s.got = append(s.got, args.I)
+	return nil
+}
+
+func (s *Sink) items() []int {
+	s.Lock()
+	defer s.Unlock()
+	return append([]int(nil), s.got...)
+}
+
+type relinked struct {
+	attempts int
+	err      error
+}
+
+// 注册中心中已登记但尚未监听的ghost节点，gate链接失败后进入重连
+func startGhost(t *testing.T, conf *ctx.ReconnectConf) (*silvernodetest.Node, string, chan *relinked) {
+	t.Helper()
+	c := silvernodetest.Run(t)
+	ghost := ctx.NewNodeInfo()
+	ghost.Name = "ghost"
+	ghost.NodeId = "ghost#1"
+	ghost.EndPoints = []string{"loop://127.0.0.1:1"}
+	if err := c.Registry().RegNodeInfo(ghost); err != nil {
+		t.Fatal(err)
+	}
+	results := make(chan *relinked, 4)
+	gate, err := c.AddNode(&silvernodetest.NodeSpec{Name: "gate", BackEnds: []string{"ghost"}, Reconnect: conf, Init: func(n *silvernodetest.Node) error {
+		n.Node().BindPipeline(&silvernode.Pipeline{OnReconnect: func(nodeId string, attempts int, err error) {
+			if nodeId == ghost.NodeId {
+				results <- &relinked{attempts: attempts, err: err}
+			}
+		}})
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gate, ghost.NodeId, results
+}
+
+// gate以logic为后端，logic断开链接后gate按conf重连
+func startLogic(t *testing.T, conf *ctx.ReconnectConf) (*silvernodetest.Node, *silvernodetest.Node, *Sink, chan *relinked) {
+	t.Helper()
+	sink := new(Sink)
+	results := make(chan *relinked, 4)
+	c := silvernodetest.Run(t,
+		&silvernodetest.NodeSpec{Name: "logic", Init: func(n *silvernodetest.Node) error {
+			_, err := n.Hub().Register(sink, process.Spawn(8192))
+			return err
+		}},
+		&silvernodetest.NodeSpec{Name: "gate", BackEnds: []string{"logic"}, Reconnect: conf, Init: func(n *silvernodetest.Node) error {
+			n.Node().BindPipeline(&silvernode.Pipeline{OnReconnect: func(nodeId string, attempts int, err error) {
+				results <- &relinked{attempts: attempts, err: err}
+			}})
+			return nil
+		}},
+	)
+	if err := c.WaitLinked(silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	gate, logic := c.NodesByName("gate")[0], c.NodesByName("logic")[0]
+	if err := logic.CloseLink(gate.Id()); err != nil { // 由后端断开的链接视为异常断开
+		t.Fatal(err)
+	}
+	if err := gate.WaitClose(logic.Id(), silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	return gate, logic, sink, results
+}
+
+func waitItems(sink *Sink, num int) []int {
+	deadline := time.Now().Add(silvernodetest.DefaultWait)
+	for len(sink.items()) < num && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	return sink.items()
+}
+
+// 等待重连开始，之后发往ghost的事件被暂存
+func waitQueued(t *testing.T, gate *silvernodetest.Node, nodeId string, item *Item) {
+	t.Helper()
+	deadline := time.Now().Add(silvernodetest.DefaultWait)
+	for gate.SendEvent(nodeId, "Sink.Put", item) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("未进入重连流程")
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func waitRelinked(t *testing.T, results chan *relinked) *relinked {
+	t.Helper()
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("未收到重连结果")
+		return nil
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	conf := ctx.ReconnectConf{MinInterval: 100, MaxInterval: 1000}
+	for attempts, want := range []int{100, 200, 400, 800, 1000, 1000} {
+		if got := conf.Backoff(attempts); got != time.Duration(want)*time.Millisecond {
+			t.Fatalf("第%d次重连前的等待时间不符: %v", attempts, got)
+		}
+	}
+	conf.Jitter = 0.2
+	for i := 0; i < 100; i++ {
+		if got := conf.Backoff(2); got < time.Millisecond*320 || got > time.Millisecond*480 {
+			t.Fatalf("抖动超出范围: %v", got)
+		}
+	}
+}
+
+// 断线期间暂存的消息在链接恢复后先于新消息按序送达
+func TestReconnectQueue(t *testing.T) {
+	gate, logic, sink, results := startLogic(t, &ctx.ReconnectConf{MinInterval: 300, MaxInterval: 300, QueueSize: 1000000})
+	waitQueued(t, gate, logic.Id(), &Item{I: 0})
+
+	// 持续发送至重连完成之后，覆盖断线、补发及恢复直接发送三个阶段
+	num := 1
+	var r *relinked
+	for tail := 0; tail < 1000; num++ {
+		if err := gate.SendEvent(logic.Id(), "Sink.Put", &Item{I: num}); err != nil {
+			t.Fatal(err)
+		}
+		if r == nil {
+			select {
+			case r = <-results:
+			default:
+			}
+		} else {
+			tail++
+		}
+	}
+	if r.err != nil || r.attempts != 1 {
+		t.Fatalf("应重连成功: %+v", r)
+	}
+	got := waitItems(sink, num)
+	if len(got) != num {
+		t.Fatalf("消息数量不符: %d/%d", len(got), num)
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("消息乱序: 第%d条为%d", i, v)
+		}
+	}
+}
+
+func TestReconnectGiveUp(t *testing.T) {
+	begin := time.Now()
+	gate, ghostId, results := startGhost(t, &ctx.ReconnectConf{MinInterval: 50, MaxInterval: 1000, Jitter: 0.01, MaxAttempts: 3})
+	waitQueued(t, gate, ghostId, &Item{I: 0})
+
+	r := waitRelinked(t, results)
+	if r.err == nil || r.attempts != 3 || !strings.Contains(r.err.Error(), "放弃") {
+		t.Fatalf("应在重连3次后放弃: %+v", r)
+	}
+	if elapsed := time.Since(begin); elapsed < time.Millisecond*300 { // 50+100+200毫秒的退避
+		t.Fatalf("重连间隔应逐次翻倍: %v", elapsed)
+	}
+	if err := gate.SendEvent(ghostId, "Sink.Put", &Item{I: 1}); err == nil {
+		t.Fatal("放弃重连后发送应返回错误")
+	}
+}
+
+// 超出缓存时长后不再暂存，已暂存的过期消息在链接恢复后丢弃
+func TestReconnectQueueExpired(t *testing.T) {
+	gate, logic, sink, results := startLogic(t, &ctx.ReconnectConf{MinInterval: 400, MaxInterval: 400, Jitter: 0.01, QueueTimeout: 150})
+	waitQueued(t, gate, logic.Id(), &Item{I: 0})
+	time.Sleep(time.Millisecond * 200)
+	if err := gate.SendEvent(logic.Id(), "Sink.Put", &Item{I: 1}); err == nil {
+		t.Fatal("超出缓存时长后发送应返回错误")
+	}
+
+	if r := waitRelinked(t, results); r.err != nil {
+		t.Fatalf("应重连成功: %+v", r)
+	}
+	if err := gate.SendEvent(logic.Id(), "Sink.Put", &Item{I: 2}); err != nil {
+		t.Fatal(err)
+	}
+	waitItems(sink, 1)
+	time.Sleep(time.Millisecond * 100)
+	if got := sink.items(); len(got) != 1 || got[0] != 2 {
+		t.Fatalf("过期的消息不应补发: %v", got)
+	}
+	found := false
+	for _, err := range gate.Errors() {
+		found = found || strings.Contains(err.Error(), "过期")
+	}
+	if !found {
+		t.Fatal("应上报丢弃过期消息的错误")
+	}
+}
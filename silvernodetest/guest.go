@@ -0,0 +1,100 @@
+package silvernodetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/nets"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+// 模拟集群外部的访客，不携带任何节点身份信息
+type Guest struct {
+	target  string
+	manager *nets.ConnectManager
+	inbox   chan []byte
+	closed  chan struct{}
+	reason  error
+	once    sync.Once
+}
+
+// 以访客身份链接到节点，节点须对外开放(IsPub)
+func (n *Node) ConnectGuest() (*Guest, error) {
+	g := new(Guest)
+	g.target = n.Id()
+	g.manager = nets.NewConnectManager()
+	g.inbox = make(chan []byte, 1024)
+	g.closed = make(chan struct{})
+	g.manager.BindEventListener(&nets.NetEventListener{
+		OnConnect: func(nodeId string) {},
+		OnMessage: func(nodeId string, msg []byte) {
+			select {
+			case g.inbox <- msg:
+			default: // 未及时读取的消息直接丢弃
+			}
+		},
+		OnClose: func(nodeId string, err error) {
+			g.once.Do(func() {
+				g.reason = err
+				close(g.closed)
+			})
+		},
+		OnError: func(err error) {},
+		OnCheckNode: func(origin string) (string, error) {
+			return "", errutil.New("访客不接受任何链接!")
+		},
+	})
+	netWorker, err := nets.CreateNetWorkerWith(nets.LOOP, g.manager)
+	if err != nil {
+		return nil, err
+	}
+	if err := netWorker.Connect(g.target, n.node.NodeInfo().MainEndPoint(), ""); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *Guest) Send(msg []byte) error {
+	info, exists := g.manager.GetConnectInfo(g.target)
+	if !exists {
+		return errutil.New("访客链接已关闭:" + g.target)
+	}
+	return info.Send(msg)
+}
+
+func (g *Guest) Recv(timeout time.Duration) ([]byte, error) {
+	select {
+	case msg := <-g.inbox:
+		return msg, nil
+	case <-time.After(timeout):
+		return nil, errutil.New("访客等待消息超时:" + g.target)
+	}
+}
+
+func (g *Guest) Close() error {
+	info, exists := g.manager.GetConnectInfo(g.target)
+	if !exists {
+		return nil
+	}
+	return info.Close(errutil.EOF())
+}
+
+// 等待链接被关闭，关闭原因由CloseReason获取
+func (g *Guest) WaitClose(timeout time.Duration) error {
+	select {
+	case <-g.closed:
+		return nil
+	case <-time.After(timeout):
+		return errutil.New("访客等待链接关闭超时:" + g.target)
+	}
+}
+
+// 链接关闭的原因，链接未关闭时为nil
+func (g *Guest) CloseReason() error {
+	select {
+	case <-g.closed:
+		return g.reason
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,12 @@
+package silvernodetest
+
+import (
+	"github.com/silvernodes/silvernode-go/cluster"
+)
+
+// 基于内存的注册中心，供同一进程内的测试节点共享
+type MemoryRegistry = cluster.MemoryIns
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return cluster.NewMemoryIns()
+}
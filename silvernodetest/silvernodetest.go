@@ -0,0 +1,364 @@
+// silvernodetest 在单个进程内启动多个节点，节点之间通过内存注册中心及回环设备互联，
+// 无需依赖外部注册中心及真实套接字，便于编写覆盖握手、访客识别、RPC等流程的集成测试
+package silvernodetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	silvernode "github.com/silvernodes/silvernode-go"
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/log"
+	"github.com/silvernodes/silvernode-go/peers"
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+const (
+	HarnessNick   string        = "Harness" // 测试节点内置的调用方Peer
+	DefaultWait   time.Duration = time.Second * 10
+	LoopEndPoint  string        = "loop://127.0.0.1:0"
+	closeDeadline time.Duration = time.Second * 5
+)
+
+type NodeSpec struct {
+	Name      string
+	NodeId    string // 可选，缺省为name#序号
+	BackEnds  []string
+	IsPub     bool
+	Weight    int                 // 节点权重，供按权重选择节点的测试使用
+	Reconnect *ctx.ReconnectConf  // 可选，后端链接断开后的重连策略
+	Init      func(n *Node) error // 节点启动前执行，一般用于注册Peer
+}
+
+type Cluster struct {
+	reg   *MemoryRegistry
+	nodes []*Node
+	sync.RWMutex
+}
+
+var _seq int64
+
+// 按顺序启动节点，任一节点启动失败时关闭已启动的节点并返回错误
+func Start(specs ...*NodeSpec) (*Cluster, error) {
+	c := new(Cluster)
+	c.reg = NewMemoryRegistry()
+	c.nodes = make([]*Node, 0, len(specs))
+	for _, spec := range specs {
+		if _, err := c.AddNode(spec); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// 启动节点，测试结束时自动关闭
+func Run(tb testing.TB, specs ...*NodeSpec) *Cluster {
+	tb.Helper()
+	c, err := Start(specs...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		if err := c.Close(); err != nil {
+			tb.Log(err)
+		}
+	})
+	return c
+}
+
+func (c *Cluster) AddNode(spec *NodeSpec) (*Node, error) {
+	info := ctx.NewNodeInfo()
+	info.Name = spec.Name
+	info.NodeId = spec.NodeId
+	if info.NodeId == "" {
+		info.NodeId = fmt.Sprintf("%s#%d", spec.Name, atomic.AddInt64(&_seq, 1))
+	}
+	info.IsPub = spec.IsPub
+	info.Weight = spec.Weight
+	if spec.Reconnect != nil {
+		info.Reconnect = *spec.Reconnect
+	}
+	info.BackEnds = append(info.BackEnds, spec.BackEnds...)
+	info.EndPoints = append(info.EndPoints, LoopEndPoint)
+	info.LogLevel = log.WARN
+	info.Metrics = false
+
+	n := newNode(info.NodeId, silvernode.NewNode(&silvernode.SetupParam{
+		NodeInfo:        info,
+		Registry:        c.reg,
+		ShutdownTimeout: int(closeDeadline / time.Millisecond),
+	}))
+	if err := n.boot(spec); err != nil {
+		return nil, err
+	}
+	c.Lock()
+	c.nodes = append(c.nodes, n)
+	c.Unlock()
+	return n, nil
+}
+
+func (c *Cluster) Registry() *MemoryRegistry {
+	return c.reg
+}
+
+func (c *Cluster) Nodes() []*Node {
+	c.RLock()
+	defer c.RUnlock()
+	return append(make([]*Node, 0, len(c.nodes)), c.nodes...)
+}
+
+func (c *Cluster) Node(nodeId string) (*Node, bool) {
+	for _, n := range c.Nodes() {
+		if n.Id() == nodeId {
+			return n, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Cluster) NodesByName(name string) []*Node {
+	ret := make([]*Node, 0, 2)
+	for _, n := range c.Nodes() {
+		if n.Name() == name {
+			ret = append(ret, n)
+		}
+	}
+	return ret
+}
+
+// 等待所有节点与其存活的后端节点建立链接
+func (c *Cluster) WaitLinked(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, n := range c.Nodes() {
+		for _, back := range n.BackEnds() {
+			for _, other := range c.NodesByName(back) {
+				if other == n || other.Stopped() {
+					continue
+				}
+				if err := n.WaitConnect(other.Id(), time.Until(deadline)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// 关闭全部节点
+func (c *Cluster) Close() error {
+	var reterr error = nil
+	for _, n := range c.Nodes() {
+		if err := n.Stop(); err != nil && reterr == nil {
+			reterr = err
+		}
+	}
+	return reterr
+}
+
+type harness struct {
+	Peer peers.Peer
+}
+
+type Node struct {
+	id      string // 节点在Serve时才载入节点信息，Init中亦需使用id
+	node    *silvernode.Node
+	hub     *peers.Hub
+	caller  peers.Peer
+	served  chan error
+	links   map[string]bool
+	history map[string]int
+	closes  map[string]int
+	errs    []error
+	stopped bool
+	sync.Mutex
+}
+
+func newNode(id string, node *silvernode.Node) *Node {
+	n := new(Node)
+	n.id = id
+	n.node = node
+	n.hub = peers.NewHub(node)
+	n.served = make(chan error, 1)
+	n.links = make(map[string]bool)
+	n.history = make(map[string]int)
+	n.closes = make(map[string]int)
+	n.errs = make([]error, 0)
+	return n
+}
+
+func (n *Node) boot(spec *NodeSpec) error {
+	n.hub.Boot()
+	n.node.BindPipeline(&silvernode.Pipeline{
+		OnConnect: n.onConnect,
+		OnClose:   n.onClose,
+		OnError:   n.onError,
+	})
+	caller, err := n.hub.RegisterWithNick(HarnessNick, false, new(harness), nil)
+	if err != nil {
+		return err
+	}
+	n.caller = caller
+	if spec.Init != nil {
+		if err := spec.Init(n); err != nil {
+			return errutil.Extend("测试节点初始化失败:"+spec.Name, err)
+		}
+	}
+	go func() {
+		n.served <- n.node.Serve()
+	}()
+	select {
+	case <-n.node.Ready():
+		return nil
+	case err := <-n.served:
+		if err == nil {
+			err = errutil.New("测试节点意外退出:" + spec.Name)
+		}
+		return err
+	case <-time.After(DefaultWait):
+		return errutil.New("测试节点启动超时:" + spec.Name)
+	}
+}
+
+func (n *Node) onConnect(nodeId string) {
+	n.Lock()
+	defer n.Unlock()
+	n.links[nodeId] = true
+	n.history[nodeId]++
+}
+
+func (n *Node) onClose(nodeId string, err error) {
+	n.Lock()
+	defer n.Unlock()
+	delete(n.links, nodeId)
+	n.closes[nodeId]++
+}
+
+func (n *Node) onError(err error) {
+	n.Lock()
+	n.errs = append(n.errs, err)
+	n.Unlock()
+	n.node.Logger().Log(log.ERROR, err)
+}
+
+func (n *Node) Id() string {
+	return n.id
+}
+
+func (n *Node) Name() string {
+	return ctx.GetNodeNameFromId(n.Id())
+}
+
+func (n *Node) BackEnds() []string {
+	return n.node.NodeInfo().BackEnds
+}
+
+func (n *Node) Node() *silvernode.Node {
+	return n.node
+}
+
+func (n *Node) Hub() *peers.Hub {
+	return n.hub
+}
+
+// 测试节点内置的调用方Peer
+func (n *Node) Caller() peers.Peer {
+	return n.caller
+}
+
+func (n *Node) Invoke(target string, method string, args interface{}, reply interface{}) error {
+	return n.caller.Invoke(target, method, args, reply)
+}
+
+func (n *Node) SendEvent(target string, method string, args interface{}) error {
+	return n.caller.SendEvent(target, method, args)
+}
+
+// 主动关闭到目标节点的链接
+func (n *Node) CloseLink(target string) error {
+	return n.node.Close(target)
+}
+
+// 当前已建立的全部链接
+func (n *Node) Links() []string {
+	n.Lock()
+	defer n.Unlock()
+	ret := make([]string, 0, len(n.links))
+	for id := range n.links {
+		ret = append(ret, id)
+	}
+	return ret
+}
+
+func (n *Node) Connected(target string) bool {
+	n.Lock()
+	defer n.Unlock()
+	return n.links[target]
+}
+
+// 目标链接累计建立及关闭的次数
+func (n *Node) LinkStats(target string) (int, int) {
+	n.Lock()
+	defer n.Unlock()
+	return n.history[target], n.closes[target]
+}
+
+// 经由流水线上报的全部错误
+func (n *Node) Errors() []error {
+	n.Lock()
+	defer n.Unlock()
+	return append(make([]error, 0, len(n.errs)), n.errs...)
+}
+
+func (n *Node) WaitConnect(target string, timeout time.Duration) error {
+	return waitFor(timeout, func() bool {
+		return n.Connected(target)
+	}, "等待链接建立超时:"+n.Id()+" -> "+target)
+}
+
+func (n *Node) WaitClose(target string, timeout time.Duration) error {
+	return waitFor(timeout, func() bool {
+		_, closes := n.LinkStats(target)
+		return closes > 0 && !n.Connected(target)
+	}, "等待链接关闭超时:"+n.Id()+" -> "+target)
+}
+
+func (n *Node) Stopped() bool {
+	n.Lock()
+	defer n.Unlock()
+	return n.stopped
+}
+
+// 优雅关闭节点，并等待Serve返回
+func (n *Node) Stop() error {
+	n.Lock()
+	if n.stopped {
+		n.Unlock()
+		return nil
+	}
+	n.stopped = true
+	n.Unlock()
+	c, cancel := context.WithTimeout(context.Background(), closeDeadline)
+	defer cancel()
+	err := n.node.Shutdown(c)
+	select {
+	case <-n.served:
+	case <-c.Done():
+	}
+	return err
+}
+
+func waitFor(timeout time.Duration, cond func() bool, text string) error {
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return errutil.New(text)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package silvernodetest_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/ctx"
+	"github.com/silvernodes/silvernode-go/silvernodetest"
+)
+
+type Echo struct {
+	events chan string
+}
+
+type EchoReq struct {
+	Text string
+	From string `auto:"node"`
+}
+
+type EchoResp struct {
+	Text string
+}
+
+func (e *Echo) Hello(args *EchoReq, reply *EchoResp) error {
+	reply.Text = "hello " + args.Text + " from " + args.From
+	return nil
+}
+
+func (e *Echo) Notify(args *EchoReq) error {
+	e.events <- args.Text + "|" + args.From
+	return nil
+}
+
+type Secret struct {
+}
+
+func (s *Secret) Peek(args *EchoReq, reply *EchoResp) error {
+	reply.Text = "secret"
+	return nil
+}
+
+func newEcho() *Echo {
+	return &Echo{events: make(chan string, 16)}
+}
+
+// logic节点开放Echo(对外)及Secret(仅限内部)，gate节点以logic为后端
+func startPair(t *testing.T, echo *Echo) (*silvernodetest.Node, *silvernodetest.Node) {
+	t.Helper()
+	c := silvernodetest.Run(t,
+		&silvernodetest.NodeSpec{Name: "logic", IsPub: true, Init: func(n *silvernodetest.Node) error {
+			if _, err := n.Hub().Register(echo, nil); err != nil {
+				return err
+			}
+			_, err := n.Hub().RegisterInner(new(Secret), nil)
+			return err
+		}},
+		&silvernodetest.NodeSpec{Name: "gate", BackEnds: []string{"logic"}},
+	)
+	if err := c.WaitLinked(silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	return c.NodesByName("gate")[0], c.NodesByName("logic")[0]
+}
+
+func TestHandshake(t *testing.T) {
+	gate, logic := startPair(t, newEcho())
+	if !gate.Connected(logic.Id()) {
+		t.Fatalf("gate未链接到logic: %v", gate.Links())
+	}
+	if err := logic.WaitConnect(gate.Id(), silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range logic.Links() {
+		if ctx.IsGuest(id) {
+			t.Fatalf("内部节点被识别为访客: %s", id)
+		}
+	}
+	if opened, closed := gate.LinkStats(logic.Id()); opened != 1 || closed != 0 {
+		t.Fatalf("链接次数不符: opened=%d closed=%d", opened, closed)
+	}
+}
+
+func TestInvokeAndSendEvent(t *testing.T) {
+	echo := newEcho()
+	gate, logic := startPair(t, echo)
+
+	reply := new(EchoResp)
+	if err := gate.Invoke(logic.Id(), "Echo.Hello", &EchoReq{Text: "world"}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello world from " + gate.Id(); reply.Text != want {
+		t.Fatalf("应答不符: got %q, want %q", reply.Text, want)
+	}
+	if err := gate.Invoke(logic.Id(), "Echo.Missing", &EchoReq{}, new(EchoResp)); err == nil {
+		t.Fatal("调用不存在的方法应当返回错误")
+	}
+
+	if err := gate.SendEvent(logic.Id(), "Echo.Notify", &EchoReq{Text: "ping"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-echo.events:
+		if want := "ping|" + gate.Id(); got != want {
+			t.Fatalf("事件不符: got %q, want %q", got, want)
+		}
+	case <-time.After(silvernodetest.DefaultWait):
+		t.Fatal("等待事件超时")
+	}
+}
+
+func TestInvokeInner(t *testing.T) {
+	gate, logic := startPair(t, newEcho())
+	reply := new(EchoResp)
+	if err := gate.Invoke(logic.Id(), "Secret.Peek", &EchoReq{}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.Text != "secret" {
+		t.Fatalf("应答不符: %q", reply.Text)
+	}
+}
+
+func TestGuest(t *testing.T) {
+	gate, logic := startPair(t, newEcho())
+
+	if _, err := gate.ConnectGuest(); err == nil {
+		t.Fatal("未对外开放的节点不应接受访客")
+	}
+
+	guest, err := logic.ConnectGuest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	guestId := waitGuest(t, logic)
+
+	// 访客以JSON编码数据体
+	if err := guest.Send(guestRequest("Client", "Echo", "Hello", 1, &EchoReq{Text: "guest"})); err != nil {
+		t.Fatal(err)
+	}
+	seq, ret, errText, body := guestResponse(t, guest)
+	if seq != 1 || ret != 1 || errText != "" {
+		t.Fatalf("应答头不符: seq=%d ret=%d err=%q", seq, ret, errText)
+	}
+	reply := new(EchoResp)
+	if err := json.Unmarshal(body, reply); err != nil {
+		t.Fatal(err)
+	}
+	if want := "hello guest from " + guestId; reply.Text != want {
+		t.Fatalf("应答不符: got %q, want %q", reply.Text, want)
+	}
+
+	if err := guest.Send(guestRequest("Client", "Secret", "Peek", 2, &EchoReq{})); err != nil {
+		t.Fatal(err)
+	}
+	seq, _, errText, _ = guestResponse(t, guest)
+	if seq != 2 || !strings.Contains(errText, "没有访问权限") {
+		t.Fatalf("访客不应访问内部Peer: seq=%d err=%q", seq, errText)
+	}
+
+	if guest.CloseReason() != nil {
+		t.Fatal("链接未关闭时不应有关闭原因")
+	}
+	if err := guest.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := guest.WaitClose(silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	if guest.CloseReason() == nil {
+		t.Fatal("链接关闭后应可获取关闭原因")
+	}
+	if err := logic.WaitClose(guestId, silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseLinkReconnect(t *testing.T) {
+	gate, logic := startPair(t, newEcho())
+
+	// 由后端断开的链接视为异常断开，gate随即重连
+	if err := logic.CloseLink(gate.Id()); err != nil {
+		t.Fatal(err)
+	}
+	if err := gate.WaitClose(logic.Id(), silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	if err := gate.WaitConnect(logic.Id(), silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	if opened, closed := gate.LinkStats(logic.Id()); opened != 2 || closed != 1 {
+		t.Fatalf("链接次数不符: opened=%d closed=%d", opened, closed)
+	}
+	reply := new(EchoResp)
+	if err := gate.Invoke(logic.Id(), "Echo.Hello", &EchoReq{Text: "again"}, reply); err != nil {
+		t.Fatal(err)
+	}
+
+	// 主动断开的一方不会重连
+	if err := gate.CloseLink(logic.Id()); err != nil {
+		t.Fatal(err)
+	}
+	if err := logic.WaitClose(gate.Id(), silvernodetest.DefaultWait); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 200)
+	if gate.Connected(logic.Id()) {
+		t.Fatal("主动关闭的链接不应重连")
+	}
+}
+
+func waitGuest(t *testing.T, n *silvernodetest.Node) string {
+	t.Helper()
+	deadline := time.Now().Add(silvernodetest.DefaultWait)
+	for time.Now().Before(deadline) {
+		for _, id := range n.Links() {
+			if ctx.IsGuest(id) {
+				return id
+			}
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	t.Fatalf("未识别到访客链接: %v", n.Links())
+	return ""
+}
+
+// 以旧版交互数据格式编码请求，节点可兼容解析
+func guestRequest(from string, to string, fn string, seq int64, args interface{}) []byte {
+	buf := new(bytes.Buffer)
+	writeString(buf, from)
+	writeString(buf, to)
+	writeString(buf, fn)
+	binary.Write(buf, binary.LittleEndian, seq)
+	buf.WriteByte(0)
+	writeString(buf, "")
+	body, _ := json.Marshal(args)
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+func guestResponse(t *testing.T, g *silvernodetest.Guest) (int64, byte, string, []byte) {
+	t.Helper()
+	msg, err := g.Recv(silvernodetest.DefaultWait)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(msg)
+	var mark int32
+	binary.Read(r, binary.LittleEndian, &mark)
+	version, _ := r.ReadByte()
+	if mark >= 0 || version != 1 {
+		t.Fatalf("应答应为V1格式: mark=%d version=%d", mark, version)
+	}
+	readString(r) // From
+	readString(r) // To
+	readString(r) // Func
+	var seq, timeLeft int64
+	var count int32
+	binary.Read(r, binary.LittleEndian, &seq)
+	ret, _ := r.ReadByte()
+	errText := readString(r)
+	binary.Read(r, binary.LittleEndian, &timeLeft)
+	binary.Read(r, binary.LittleEndian, &count)
+	for i := int32(0); i < count*2; i++ {
+		readString(r)
+	}
+	body := make([]byte, r.Len())
+	r.Read(body)
+	return seq, ret, errText, body
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.LittleEndian, int32(len(s)))
+	buf.WriteString(s)
+}
+
+func readString(r *bytes.Reader) string {
+	var size int32
+	binary.Read(r, binary.LittleEndian, &size)
+	if size <= 0 {
+		return ""
+	}
+	data := make([]byte, size)
+	r.Read(data)
+	return string(data)
+}
+
+func TestInitNodeId(t *testing.T) {
+	ids := make(chan string, 1)
+	c := silvernodetest.Run(t, &silvernodetest.NodeSpec{Name: "logic", NodeId: "logic#init", Init: func(n *silvernodetest.Node) error {
+		ids <- n.Id()
+		return nil
+	}})
+	if id := <-ids; id != "logic#init" || c.NodesByName("logic")[0].Node().NodeId() != id {
+		t.Fatalf("Init中的节点id不符: %q", id)
+	}
+}
@@ -0,0 +1,126 @@
+package trace
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/silvernodes/silvernode-go/utils/jsonutil"
+)
+
+// Span导出器，Export在导出协程中按批次调用
+type Exporter interface {
+	Export(spans []*Span) error
+	Shutdown(c context.Context) error
+}
+
+// 导出时使用的Span结构
+type SpanData struct {
+	TraceId    string
+	SpanId     string
+	ParentId   string `json:",omitempty"`
+	Service    string
+	Name       string
+	Kind       Kind
+	StartTime  int64 // 微秒
+	Duration   int64 // 微秒
+	Attributes map[string]string
+	Err        string `json:",omitempty"`
+}
+
+func (s *Span) Data() *SpanData {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data := &SpanData{
+		TraceId:    s.TraceId.String(),
+		SpanId:     s.SpanId.String(),
+		Service:    s.Service,
+		Name:       s.Name,
+		Kind:       s.Kind,
+		StartTime:  s.StartTime.UnixNano() / 1e3,
+		Duration:   s.EndTime.Sub(s.StartTime).Microseconds(),
+		Attributes: make(map[string]string, len(s.Attributes)),
+		Err:        s.Err,
+	}
+	if s.ParentId.IsValid() {
+		data.ParentId = s.ParentId.String()
+	}
+	for k, v := range s.Attributes {
+		data.Attributes[k] = v
+	}
+	return data
+}
+
+// 以json逐行输出Span
+type StdoutExporter struct {
+	writer io.Writer
+	lock   sync.Mutex
+}
+
+func NewStdoutExporter() *StdoutExporter {
+	return NewWriterExporter(os.Stdout)
+}
+
+func NewWriterExporter(writer io.Writer) *StdoutExporter {
+	e := new(StdoutExporter)
+	e.writer = writer
+	return e
+}
+
+func (e *StdoutExporter) Export(spans []*Span) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, s := range spans {
+		data, err := jsonutil.MarshalRaw(s.Data())
+		if err != nil {
+			return err
+		}
+		if _, err := e.writer.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *StdoutExporter) Shutdown(c context.Context) error {
+	return nil
+}
+
+// 将Span保存在内存中，供测试检查
+type MemoryExporter struct {
+	spans []*SpanData
+	lock  sync.RWMutex
+}
+
+func NewMemoryExporter() *MemoryExporter {
+	return new(MemoryExporter)
+}
+
+func (e *MemoryExporter) Export(spans []*Span) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, s := range spans {
+		e.spans = append(e.spans, s.Data())
+	}
+	return nil
+}
+
+func (e *MemoryExporter) Shutdown(c context.Context) error {
+	return nil
+}
+
+// 已导出的Span，调用前可先执行trace.Flush
+func (e *MemoryExporter) Spans() []*SpanData {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	ret := make([]*SpanData, len(e.spans))
+	copy(ret, e.spans)
+	return ret
+}
+
+func (e *MemoryExporter) Reset() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.spans = nil
+}
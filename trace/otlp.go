@@ -0,0 +1,167 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+	"github.com/silvernodes/silvernode-go/utils/jsonutil"
+)
+
+const OTLP_DEFAULT_ENDPOINT string = "http://127.0.0.1:4318/v1/traces"
+
+// 以OTLP/HTTP(json编码)协议导出至OpenTelemetry Collector或兼容的后端(如Jaeger、Tempo)
+type OTLPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+// endpoint为完整的上报地址，缺省为本机Collector；headers可用于携带鉴权信息
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	e := new(OTLPExporter)
+	e.endpoint = endpoint
+	if e.endpoint == "" {
+		e.endpoint = OTLP_DEFAULT_ENDPOINT
+	}
+	e.headers = headers
+	e.client = &http.Client{Timeout: time.Second * 10}
+	return e
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string         `json:"traceId"`
+	SpanId            string         `json:"spanId"`
+	ParentSpanId      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttributes(attrs map[string]string) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: otlpValue{StringValue: attrs[k]}})
+	}
+	return kvs
+}
+
+// 按服务分组为resourceSpans
+func (e *OTLPExporter) build(spans []*Span) *otlpRequest {
+	services := make([]string, 0)
+	groups := make(map[string][]otlpSpan)
+	for _, s := range spans {
+		s.lock.Lock()
+		span := otlpSpan{
+			TraceId:           s.TraceId.String(),
+			SpanId:            s.SpanId.String(),
+			Name:              s.Name,
+			Kind:              int(s.Kind),
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.ParentId.IsValid() {
+			span.ParentSpanId = s.ParentId.String()
+		}
+		if s.Err != "" {
+			span.Status = otlpStatus{Code: 2, Message: s.Err}
+		}
+		service := s.Service
+		s.lock.Unlock()
+		if _, exists := groups[service]; !exists {
+			services = append(services, service)
+		}
+		groups[service] = append(groups[service], span)
+	}
+	req := &otlpRequest{ResourceSpans: make([]otlpResourceSpans, 0, len(services))}
+	for _, service := range services {
+		req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+			Resource: otlpResource{Attributes: otlpAttributes(map[string]string{"service.name": service})},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/silvernodes/silvernode-go"},
+				Spans: groups[service],
+			}},
+		})
+	}
+	return req
+}
+
+func (e *OTLPExporter) Export(spans []*Span) error {
+	body, err := jsonutil.MarshalRaw(e.build(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return errutil.New("OTLP上报失败:" + resp.Status)
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(c context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
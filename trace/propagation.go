@@ -0,0 +1,45 @@
+package trace
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// W3C Trace Context的请求头名称
+const TRACEPARENT string = "traceparent"
+
+// 编码为traceparent，形如00-{traceid}-{spanid}-{flags}
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceId.String() + "-" + sc.SpanId.String() + "-" + flags
+}
+
+// 解析traceparent，格式非法时返回false
+func ParseTraceparent(str string) (SpanContext, bool) {
+	sc := SpanContext{}
+	infos := strings.Split(strings.TrimSpace(str), "-")
+	if len(infos) < 4 || len(infos[0]) != 2 || infos[0] == "ff" {
+		return sc, false
+	}
+	if infos[0] == "00" && len(infos) != 4 {
+		return sc, false
+	}
+	if len(infos[1]) != 32 || len(infos[2]) != 16 || len(infos[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceId[:], []byte(infos[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanId[:], []byte(infos[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(infos[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc, sc.IsValid()
+}
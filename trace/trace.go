@@ -0,0 +1,308 @@
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/silvernodes/silvernode-go/utils/errutil"
+)
+
+type Kind int
+
+// 与OpenTelemetry的SpanKind取值一致
+const (
+	KIND_INTERNAL Kind = 1
+	KIND_SERVER   Kind = 2
+	KIND_CLIENT   Kind = 3
+	KIND_PRODUCER Kind = 4 // 发出事件
+	KIND_CONSUMER Kind = 5 // 处理事件
+)
+
+type TraceId [16]byte
+type SpanId [8]byte
+
+func (t TraceId) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceId) IsValid() bool {
+	return t != TraceId{}
+}
+
+func (s SpanId) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanId) IsValid() bool {
+	return s != SpanId{}
+}
+
+// 跨节点传递的链路信息
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+// 一次调用或处理过程
+type Span struct {
+	TraceId    TraceId
+	SpanId     SpanId
+	ParentId   SpanId // 根Span为空
+	Sampled    bool
+	Service    string // 所属服务，一般为节点名称
+	Name       string
+	Kind       Kind
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]string
+	Err        string // 非空表示以错误结束
+
+	ended bool
+	lock  sync.Mutex
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceId: s.TraceId, SpanId: s.SpanId, Sampled: s.Sampled}
+}
+
+func (s *Span) SetAttribute(k string, v string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Attributes[k] = v
+}
+
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Err = err.Error()
+}
+
+// 结束并提交导出，重复调用无效
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.lock.Unlock()
+	if s.Sampled {
+		_tracer.Load().enqueue(s)
+	}
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// 开启新的Span，父级取自上下文中的Span或远端链路信息；未启用链路追踪时返回nil(Span的方法均可安全调用)
+func Start(c context.Context, service string, name string, kind Kind) (context.Context, *Span) {
+	if !Enabled() {
+		return c, nil
+	}
+	s := new(Span)
+	s.Service = service
+	s.Name = name
+	s.Kind = kind
+	s.Attributes = make(map[string]string)
+	s.StartTime = time.Now()
+	rand.Read(s.SpanId[:])
+	if parent := SpanContextFrom(c); parent.IsValid() {
+		s.TraceId = parent.TraceId
+		s.ParentId = parent.SpanId
+		s.Sampled = parent.Sampled
+	} else {
+		rand.Read(s.TraceId[:])
+		s.Sampled = _tracer.Load().sample(s.TraceId)
+	}
+	return context.WithValue(c, spanKey{}, s), s
+}
+
+func FromContext(c context.Context) *Span {
+	if c == nil {
+		return nil
+	}
+	s, _ := c.Value(spanKey{}).(*Span)
+	return s
+}
+
+// 附加远端传入的链路信息，之后开启的Span将以其为父级
+func WithRemote(c context.Context, sc SpanContext) context.Context {
+	return context.WithValue(c, remoteKey{}, sc)
+}
+
+// 上下文中当前的链路信息，本地Span优先于远端
+func SpanContextFrom(c context.Context) SpanContext {
+	if c == nil {
+		return SpanContext{}
+	}
+	if s := FromContext(c); s != nil {
+		return s.SpanContext()
+	}
+	sc, _ := c.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+type SetupParam struct {
+	Exporters   []Exporter
+	SampleRatio float64 // 根Span的采样比例(0~1)，缺省为1
+	BatchSize   int     // 单次导出的Span数量上限，缺省为256
+	Interval    int     // 定期导出的间隔(毫秒)，缺省为1000
+}
+
+type tracer struct {
+	exporters []Exporter
+	ratio     float64
+	batchSize int
+	interval  time.Duration
+	queue     []*Span
+	flush     chan struct{}
+	quit      chan struct{}
+	done      chan struct{}
+	lock      sync.Mutex
+}
+
+var _tracer atomic.Pointer[tracer] // Setup/Shutdown与各调用协程并发访问
+
+func init() {
+	_tracer.Store(new(tracer))
+}
+
+// 启用链路追踪，重复调用时替换原有设置(已缓存的Span将先行导出)
+func Setup(param *SetupParam) {
+	t := new(tracer)
+	t.exporters = param.Exporters
+	t.ratio = 1
+	if param.SampleRatio > 0 && param.SampleRatio < 1 {
+		t.ratio = param.SampleRatio
+	}
+	t.batchSize = 256
+	if param.BatchSize > 0 {
+		t.batchSize = param.BatchSize
+	}
+	t.interval = time.Second
+	if param.Interval > 0 {
+		t.interval = time.Duration(param.Interval) * time.Millisecond
+	}
+	t.flush = make(chan struct{}, 1)
+	t.quit = make(chan struct{})
+	t.done = make(chan struct{})
+	go t.loop()
+	_tracer.Swap(t).shutdown(context.Background())
+}
+
+func Enabled() bool {
+	return len(_tracer.Load().exporters) > 0
+}
+
+// 立即导出已结束的Span
+func Flush() {
+	_tracer.Load().export()
+}
+
+// 导出剩余的Span并关闭全部导出器
+func Shutdown(c context.Context) error {
+	return _tracer.Swap(new(tracer)).shutdown(c)
+}
+
+func (t *tracer) shutdown(c context.Context) error {
+	if t.quit == nil {
+		return nil
+	}
+	close(t.quit)
+	<-t.done
+	var ret error = nil
+	for _, exporter := range t.exporters {
+		if err := exporter.Shutdown(c); err != nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// 按TraceId决定是否采样，同一链路在各节点的决定一致
+func (t *tracer) sample(id TraceId) bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	var v uint64
+	for _, b := range id[8:] {
+		v = v<<8 | uint64(b)
+	}
+	return float64(v>>11)/float64(1<<53) < t.ratio
+}
+
+func (t *tracer) enqueue(s *Span) {
+	t.lock.Lock()
+	if len(t.exporters) == 0 {
+		t.lock.Unlock()
+		return
+	}
+	t.queue = append(t.queue, s)
+	full := len(t.queue) >= t.batchSize
+	t.lock.Unlock()
+	if full {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *tracer) loop() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.quit:
+			t.export()
+			return
+		case <-ticker.C:
+		case <-t.flush:
+		}
+		t.export()
+	}
+}
+
+func (t *tracer) export() {
+	for {
+		t.lock.Lock()
+		n := len(t.queue)
+		if n > t.batchSize {
+			n = t.batchSize
+		}
+		batch := t.queue[:n]
+		t.queue = t.queue[n:]
+		t.lock.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+		for _, exporter := range t.exporters {
+			if err := exporter.Export(batch); err != nil {
+				errutil.ReportError(errutil.Extend("导出链路追踪数据失败", err))
+			}
+		}
+	}
+}
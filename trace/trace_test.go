@@ -0,0 +1,86 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+type memExporter struct {
+	spans []*Span
+	lock  sync.Mutex
+}
+
+func (m *memExporter) Export(spans []*Span) error {
+	m.lock.Lock()
+	m.spans = append(m.spans, spans...)
+	m.lock.Unlock()
+	return nil
+}
+
+func (m *memExporter) Shutdown(c context.Context) error {
+	return nil
+}
+
+func (m *memExporter) count() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.spans)
+}
+
+// Setup/Shutdown与各协程开启及结束Span并发进行(配合-race检查)
+func TestSetupConcurrent(t *testing.T) {
+	defer Shutdown(context.Background())
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c, parent := Start(context.Background(), "test", "parent", KIND_SERVER)
+				_, child := Start(c, "test", "child", KIND_CLIENT)
+				child.End()
+				parent.End()
+				Flush()
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		Setup(&SetupParam{Exporters: []Exporter{new(memExporter)}})
+		if i%2 == 0 {
+			Shutdown(context.Background())
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestShutdownExports(t *testing.T) {
+	exporter := new(memExporter)
+	Setup(&SetupParam{Exporters: []Exporter{exporter}, Interval: 60000})
+	c, parent := Start(context.Background(), "test", "parent", KIND_SERVER)
+	_, child := Start(c, "test", "child", KIND_CLIENT)
+	if child.TraceId != parent.TraceId || child.ParentId != parent.SpanId {
+		t.Fatalf("子Span未继承父级: %+v", child.SpanContext())
+	}
+	child.End()
+	parent.End()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := exporter.count(); n != 2 {
+		t.Fatalf("关闭时应导出剩余的Span: %d", n)
+	}
+	if Enabled() {
+		t.Fatal("关闭后不应再启用")
+	}
+	if _, s := Start(context.Background(), "test", "after", KIND_INTERNAL); s != nil {
+		t.Fatal("关闭后不应开启Span")
+	}
+}
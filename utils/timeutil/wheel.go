@@ -0,0 +1,130 @@
+package timeutil
+
+import (
+	"sync"
+	"time"
+)
+
+type wheelTask struct {
+	id     int64
+	rounds int
+	fn     func()
+}
+
+// 时间轮，适用于大量短时定时任务(如请求超时)的登记与撤销
+// 到期回调在时间轮自身的协程中依次执行，回调内应避免阻塞
+type TimeWheel struct {
+	interval time.Duration
+	slots    []map[int64]*wheelTask
+	index    map[int64]int // 任务id -> 所在槽位
+	cursor   int
+	seq      int64
+	quit     chan struct{}
+	running  bool
+	sync.Mutex
+}
+
+// interval为刻度精度，slotNum为槽位数，二者之积为一圈的时长
+func NewTimeWheel(interval time.Duration, slotNum int) *TimeWheel {
+	if interval <= 0 {
+		interval = time.Millisecond * 10
+	}
+	if slotNum <= 0 {
+		slotNum = 512
+	}
+	w := new(TimeWheel)
+	w.interval = interval
+	w.slots = make([]map[int64]*wheelTask, slotNum)
+	for i := range w.slots {
+		w.slots[i] = make(map[int64]*wheelTask)
+	}
+	w.index = make(map[int64]int)
+	return w
+}
+
+func (w *TimeWheel) Start() {
+	w.Lock()
+	defer w.Unlock()
+	if w.running {
+		return
+	}
+	w.running = true
+	w.quit = make(chan struct{})
+	go w.loop(w.quit)
+}
+
+// 停止转动，尚未到期的任务保留，重新Start后继续计时
+func (w *TimeWheel) Stop() {
+	w.Lock()
+	defer w.Unlock()
+	if !w.running {
+		return
+	}
+	w.running = false
+	close(w.quit)
+}
+
+// 登记延时任务，返回的id可用于撤销
+func (w *TimeWheel) AfterFunc(delay time.Duration, fn func()) int64 {
+	ticks := int((delay + w.interval - 1) / w.interval)
+	if ticks <= 0 {
+		ticks = 1
+	}
+	w.Lock()
+	defer w.Unlock()
+	w.seq++
+	pos := (w.cursor + ticks) % len(w.slots)
+	w.slots[pos][w.seq] = &wheelTask{
+		id:     w.seq,
+		rounds: (ticks - 1) / len(w.slots),
+		fn:     fn,
+	}
+	w.index[w.seq] = pos
+	return w.seq
+}
+
+// 撤销尚未执行的任务，任务已执行或不存在时返回false
+func (w *TimeWheel) Cancel(id int64) bool {
+	w.Lock()
+	defer w.Unlock()
+	pos, exists := w.index[id]
+	if !exists {
+		return false
+	}
+	delete(w.slots[pos], id)
+	delete(w.index, id)
+	return true
+}
+
+func (w *TimeWheel) loop(quit chan struct{}) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			for _, fn := range w.advance() {
+				fn()
+			}
+		}
+	}
+}
+
+func (w *TimeWheel) advance() []func() {
+	w.Lock()
+	defer w.Unlock()
+	w.cursor = (w.cursor + 1) % len(w.slots)
+	slot := w.slots[w.cursor]
+	expired := make([]func(), 0)
+	for id, task := range slot {
+		if task.rounds > 0 {
+			task.rounds--
+			continue
+		}
+		expired = append(expired, task.fn)
+		delete(slot, id)
+		delete(w.index, id)
+	}
+	return expired
+}